@@ -6,8 +6,20 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"vocabulary/internal/auth"
 	"vocabulary/internal/handlers"
 	"vocabulary/internal/middleware"
+	"vocabulary/internal/migrate"
+	"vocabulary/internal/models"
+	"vocabulary/internal/notify"
+	"vocabulary/internal/password"
+	"vocabulary/internal/purge"
+	"vocabulary/internal/reminder"
+	"vocabulary/internal/wordlist"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
@@ -40,15 +52,73 @@ func main() {
 		if err = db.Ping(); err != nil {
 			log.Fatal("Error pinging the database:", err)
 		}
+
+		// 建立缺少的資料表並套用 scripts/migrations，讓既有的資料庫更新到目前的結構
+		schemaDir := os.Getenv("SCHEMA_DIR")
+		if schemaDir == "" {
+			schemaDir = "scripts"
+		}
+		if err = migrate.Run(db, schemaDir); err != nil {
+			log.Fatal("Error migrating the database:", err)
+		}
 	}
 	// 初始化handlers
 	handlers.Init(db)
+	middleware.Init(db)
+
+	// 將 BOOTSTRAP_ADMIN 指定的使用者設為管理員，之後可在管理介面指派其他角色
+	if username := os.Getenv("BOOTSTRAP_ADMIN"); username != "" && !skipDB {
+		promoted, err := models.PromoteUser(db, username)
+		if err != nil {
+			log.Fatal("Error promoting bootstrap admin:", err)
+		}
+		if promoted {
+			log.Printf("User %s is now an admin", username)
+		}
+	}
+
+	// ADMIN_USER_IDS 是先前以環境變數指定管理員的方式，升級後只在第一次啟動時將其中的使用者設為管理員，
+	// 之後的角色一律由管理介面決定，不會在每次啟動時恢復被移除的管理員
+	if ids := os.Getenv("ADMIN_USER_IDS"); ids != "" && !skipDB {
+		var adminIDs []int64
+		for _, id := range strings.Split(ids, ",") {
+			adminID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+			if err != nil {
+				log.Fatalf("Invalid ADMIN_USER_IDS entry %q", id)
+			}
+			adminIDs = append(adminIDs, adminID)
+		}
+		err := migrate.Once(db, "008_admin_user_ids", func() error {
+			promoted, err := models.PromoteUserIDs(db, adminIDs)
+			if err == nil && promoted > 0 {
+				log.Printf("Promoted %d user(s) from ADMIN_USER_IDS to admin", promoted)
+			}
+			return err
+		})
+		if err != nil {
+			log.Fatal("Error promoting ADMIN_USER_IDS:", err)
+		}
+	}
+
+	// 設定通知管道並啟動每日提醒、到期帳號的刪除與字典快取的維護
+	notify.ConfigureFromEnv()
+	if !skipDB {
+		reminder.Start(db, 5*time.Minute)
+		purge.Start(db, time.Hour)
+		handlers.StartDictionaryCache(time.Minute)
+	}
 
 	// 初始化Gin路由
 	r := gin.Default()
 
+	// 設定 cookie 的 Secure、SameSite 與 Domain，部署在 HTTPS 後方時應設 COOKIE_SECURE=true
+	if err := auth.ConfigureCookiesFromEnv(); err != nil {
+		log.Fatal("Invalid cookie configuration: ", err)
+	}
+
 	// 設置 session middleware
 	store := cookie.NewStore([]byte(os.Getenv("JWT_SECRET")))
+	store.Options(auth.SessionOptions(0))
 	r.Use(sessions.Sessions("vocabulary_session", store))
 
 	// 獲取當前工作目錄
@@ -58,6 +128,24 @@ func main() {
 		log.Fatal("Error getting working directory:", err)
 	}
 
+	// 載入內建的頻率表與 CEFR 單字表
+	wordlistDir := os.Getenv("WORDLIST_DIR")
+	if wordlistDir == "" {
+		wordlistDir = filepath.Join(wd, "data", "wordlists")
+	}
+	if err := wordlist.Load(wordlistDir); err != nil {
+		log.Printf("Warning: failed to load word lists: %v", err)
+	}
+
+	// 載入外洩密碼清單，註冊與變更密碼時會拒絕清單中的密碼
+	breachedFile := os.Getenv("BREACHED_PASSWORDS_FILE")
+	if breachedFile == "" {
+		breachedFile = filepath.Join(wd, "data", "passwords", "breached.txt")
+	}
+	if err := password.LoadBreached(breachedFile); err != nil {
+		log.Printf("Warning: failed to load breached password list: %v", err)
+	}
+
 	// 載入所有HTML模板，包括子目錄
 	templatesDir := filepath.Join(wd, "templates")
 	r.LoadHTMLGlob(filepath.Join(templatesDir, "*/*.html"))
@@ -106,6 +194,8 @@ func testEnvironmentMiddleware() gin.HandlerFunc {
 func setupRoutes(r *gin.Engine) {
 	// 添加測試環境中間件
 	r.Use(testEnvironmentMiddleware())
+	// 所有會改變狀態的請求都需要 CSRF token
+	r.Use(middleware.CSRF())
 	// 首頁重定向到登入頁面
 	r.GET("/", func(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/login")
@@ -113,9 +203,17 @@ func setupRoutes(r *gin.Engine) {
 	// 公開路由
 	r.GET("/login", handlers.ShowLogin)
 	r.POST("/login", handlers.Login)
+	r.POST("/login/2fa", handlers.LoginTwoFactor)
+	r.GET("/auth/oidc/login", handlers.OIDCLogin)
+	r.GET("/auth/oidc/callback", handlers.OIDCCallback)
 	r.GET("/register", handlers.ShowRegister)
 	r.POST("/register", handlers.Register)
 	r.POST("/logout", handlers.Logout)
+	r.POST("/auth/refresh", handlers.RefreshToken)
+	r.GET("/password/forgot", handlers.ShowForgotPassword)
+	r.POST("/password/forgot", handlers.ForgotPassword)
+	r.GET("/password/reset", handlers.ShowResetPassword)
+	r.POST("/password/reset", handlers.ResetPassword)
 
 	// 需要認證的路由
 	authorized := r.Group("/")
@@ -124,19 +222,131 @@ func setupRoutes(r *gin.Engine) {
 		// 新聞相關
 		authorized.GET("/news", handlers.ShowNewsReader)
 		authorized.POST("/news/fetch", handlers.FetchNews)
+		authorized.POST("/news/upload", handlers.UploadDocument)
+		authorized.GET("/news/articles", handlers.ListArticles)
+		authorized.GET("/news/articles/:id", handlers.GetArticle)
+		authorized.GET("/news/articles/:id/chapters/:index", handlers.GetArticleChapter)
 
 		// 單字相關
 		authorized.GET("/vocabulary", handlers.ShowVocabulary)
 		authorized.POST("/vocabulary/lookup", handlers.LookupWord)
 		authorized.POST("/vocabulary/save", handlers.SaveWord)
+		authorized.POST("/vocabulary/prelearn", handlers.PrelearnArticle)
+		authorized.POST("/vocabulary/bulk-save", handlers.BulkSaveWords)
+		authorized.GET("/vocabulary/export", handlers.ExportVocabulary)
+		authorized.POST("/vocabulary/import", handlers.ImportVocabulary)
+		authorized.GET("/vocabulary/export/anki", handlers.ExportAnki)
+		authorized.POST("/vocabulary/import/anki", handlers.ImportAnki)
+		authorized.POST("/vocabulary/import/kindle", handlers.ImportKindle)
 		authorized.DELETE("/vocabulary/:id", handlers.DeleteWord)
 		authorized.GET("/vocabulary/:id", handlers.GetVocabulary)
 		authorized.PUT("/vocabulary/:id", handlers.UpdateVocabulary)
 
+		// 標籤與牌組
+		authorized.GET("/tags", handlers.ListTags)
+		authorized.POST("/tags", handlers.CreateTag)
+		authorized.PUT("/tags/:id", handlers.RenameTag)
+		authorized.DELETE("/tags/:id", handlers.DeleteTag)
+		authorized.GET("/decks", handlers.ListDecks)
+		authorized.POST("/decks", handlers.CreateDeck)
+		authorized.PUT("/decks/:id", handlers.UpdateDeck)
+		authorized.DELETE("/decks/:id", handlers.DeleteDeck)
+
 		// 單字卡測驗
 		authorized.GET("/flashcards", handlers.ShowFlashcards)
 		authorized.GET("/flashcards/test", handlers.StartTest)
 		authorized.POST("/flashcards/result", handlers.SaveTestResult)
+		authorized.GET("/flashcards/quiz", handlers.StartQuiz)
+		authorized.POST("/flashcards/quiz/answer", handlers.AnswerQuiz)
+		authorized.POST("/flashcards/sessions", handlers.CreateReviewSession)
+		authorized.GET("/flashcards/sessions/active", handlers.GetActiveReviewSession)
+		authorized.GET("/flashcards/sessions/:id", handlers.GetReviewSession)
+		authorized.POST("/flashcards/sessions/:id/answer", handlers.AnswerReviewCard)
+		authorized.POST("/flashcards/sessions/:id/finish", handlers.FinishReviewSession)
+		authorized.GET("/flashcards/sessions/:id/summary", handlers.ReviewSessionSummary)
+		authorized.GET("/scheduler", handlers.GetSchedulerSettings)
+		authorized.PUT("/scheduler", handlers.UpdateSchedulerSettings)
+		authorized.POST("/scheduler/optimize", handlers.OptimizeScheduler)
+		authorized.GET("/scheduler/optimize", handlers.GetOptimizeStatus)
+		authorized.GET("/scheduler/words", handlers.GetWordSchedules)
+		authorized.GET("/scheduler/boxes", handlers.GetLeitnerBoxes)
+		authorized.GET("/stats", handlers.ShowStats)
+		authorized.GET("/stats/data", handlers.GetStats)
+		authorized.GET("/goals", handlers.GetGoals)
+		authorized.PUT("/goals", handlers.UpdateGoals)
+		authorized.POST("/goals/reading", handlers.LogReading)
+		authorized.GET("/reminders", handlers.GetReminders)
+		authorized.PUT("/reminders", handlers.UpdateReminders)
+		authorized.POST("/reminders/code", handlers.SendReminderCode)
+		authorized.POST("/reminders/confirm", handlers.ConfirmReminderTarget)
+		authorized.POST("/reminders/test", handlers.SendTestReminder)
+
+		// 公開單字表
+		authorized.GET("/shared-decks", handlers.ShowSharedDecks)
+		authorized.GET("/shared-decks/list", handlers.ListSharedDecks)
+		authorized.GET("/shared-decks/:id", handlers.GetSharedDeck)
+		authorized.POST("/shared-decks/:id/import", handlers.ImportSharedDeck)
+
+		// 帳號備份與搬移
+		authorized.GET("/account", handlers.ShowAccount)
+		authorized.GET("/account/export", handlers.ExportAccount)
+		authorized.POST("/account/import", handlers.ImportAccount)
+		authorized.GET("/account/data-export", handlers.ExportPersonalData)
+		authorized.POST("/account/delete", handlers.RequestAccountDeletion)
+		authorized.GET("/account/sessions", handlers.ListSessions)
+		authorized.DELETE("/account/sessions/:id", handlers.RevokeSession)
+		authorized.POST("/account/sessions/revoke-all", handlers.RevokeAllSessions)
+		authorized.POST("/account/password", handlers.ChangePassword)
+		authorized.PUT("/account/email", handlers.UpdateEmail)
+		authorized.GET("/account/2fa", handlers.GetTwoFactorStatus)
+		authorized.POST("/account/2fa/setup", handlers.SetupTwoFactor)
+		authorized.POST("/account/2fa/enable", handlers.EnableTwoFactor)
+		authorized.POST("/account/2fa/disable", handlers.DisableTwoFactor)
+		authorized.POST("/account/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		authorized.GET("/account/identities", handlers.ListIdentities)
+		authorized.POST("/account/identities/link", handlers.LinkIdentity)
+		authorized.POST("/account/reauthenticate", handlers.ReauthenticateOIDC)
+		authorized.DELETE("/account/identities/:id", handlers.UnlinkIdentity)
+		// 班級：老師建立班級與指派，學生以加入代碼加入並在單字卡頁面練習
+		teacher := middleware.RoleRequired(models.RoleTeacher)
+		authorized.GET("/classes", teacher, handlers.ShowClasses)
+		authorized.GET("/classes/list", teacher, handlers.ListClasses)
+		authorized.POST("/classes", teacher, handlers.CreateClass)
+		authorized.GET("/classes/:id", teacher, handlers.GetClass)
+		authorized.PUT("/classes/:id", teacher, handlers.RenameClass)
+		authorized.DELETE("/classes/:id", teacher, handlers.DeleteClass)
+		authorized.POST("/classes/:id/join-code", teacher, handlers.ResetJoinCode)
+		authorized.DELETE("/classes/:id/students/:user_id", teacher, handlers.RemoveStudent)
+		authorized.POST("/classes/:id/assignments", teacher, handlers.CreateAssignment)
+		authorized.DELETE("/classes/:id/assignments/:assignment_id", teacher, handlers.DeleteAssignment)
+		authorized.GET("/classes/:id/progress", teacher, handlers.GetClassProgress)
+		authorized.POST("/classes/join", handlers.JoinClass)
+		authorized.DELETE("/classes/:id/membership", handlers.LeaveClass)
+		authorized.GET("/flashcards/assignments", handlers.ListMyAssignments)
+		authorized.POST("/flashcards/assignments/:id/import", handlers.ImportAssignment)
+
+		authorized.GET("/account/tokens", handlers.ListAPITokens)
+		authorized.POST("/account/tokens", handlers.CreateAPIToken)
+		authorized.DELETE("/account/tokens/:id", handlers.RevokeAPIToken)
+	}
+
+	// 管理員路由
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthRequired(), middleware.AdminRequired())
+	{
+		admin.GET("", handlers.ShowAdminConsole)
+		admin.GET("/stats", handlers.GetSystemStats)
+		admin.GET("/users", handlers.ListUsers)
+		admin.PUT("/users/:id/role", handlers.SetUserRole)
+		admin.POST("/users/:id/disable", handlers.DisableUser)
+		admin.POST("/users/:id/enable", handlers.EnableUser)
+		admin.POST("/users/:id/reset-password", handlers.AdminResetPassword)
+		admin.GET("/dictionary-cache", handlers.ListDictionaryCache)
+		admin.DELETE("/dictionary-cache", handlers.PurgeDictionaryCache)
+		admin.DELETE("/dictionary-cache/:word", handlers.DeleteDictionaryCacheEntry)
+		admin.POST("/shared-decks", handlers.CreateSharedDeck)
+		admin.PUT("/shared-decks/:id", handlers.UpdateSharedDeck)
+		admin.DELETE("/shared-decks/:id", handlers.DeleteSharedDeck)
 	}
 
 	// 將所有未定義的路由重定向到登入頁面
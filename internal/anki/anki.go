@@ -0,0 +1,430 @@
+// Package anki reads and writes Anki .apkg packages (legacy schema 11 collections).
+package anki
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// FieldSeparator separates note fields inside the notes.flds column
+const FieldSeparator = "\x1f"
+
+// MaxCollectionSize limits how large a collection may be once uncompressed
+const MaxCollectionSize = 200 << 20
+
+var (
+	// ErrNoCollection is returned when a package contains no readable collection
+	ErrNoCollection = errors.New("package does not contain a legacy Anki collection")
+	// ErrNewFormat is returned for packages that only contain the zstd-compressed collection of Anki 2.1.50 and
+	// later; their collection.anki2 is a placeholder asking the user to update Anki
+	ErrNewFormat = errors.New("package uses the collection format of Anki 2.1.50 and later")
+	// ErrCollectionTooLarge is returned when the collection exceeds MaxCollectionSize once uncompressed
+	ErrCollectionTooLarge = errors.New("collection is too large")
+)
+
+// Note is a single Anki note
+type Note struct {
+	Model  string            // 筆記類型名稱
+	Fields map[string]string // 欄位名稱對應到欄位內容（HTML）
+	Tags   []string
+	Deck   string // 巢狀牌組以 "::" 分隔
+}
+
+// NoteType describes the note type written by Export
+type NoteType struct {
+	Name   string
+	Fields []string
+	Front  string // 正面模板，例如 "{{Word}}"
+	Back   string // 背面模板
+	CSS    string
+}
+
+const schema = `
+CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+	ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null,
+	models text not null, decks text not null, dconf text not null, tags text not null);
+CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null,
+	flags integer not null, data text not null);
+CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null,
+	ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null,
+	odue integer not null, odid integer not null, flags integer not null, data text not null);
+CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+	ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// Export writes the notes as an .apkg package using a single note type
+func Export(w io.Writer, noteType NoteType, notes []Note) error {
+	dir, err := os.MkdirTemp("", "apkg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collection.anki2")
+	if err := writeCollection(path, noteType, notes); err != nil {
+		return err
+	}
+
+	collection, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	f, err := zw.Create("collection.anki2")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(collection); err != nil {
+		return err
+	}
+
+	// 沒有媒體檔案，只寫入空的媒體清單
+	f, err = zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte("{}")); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeCollection(path string, noteType NoteType, notes []Note) error {
+	col, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer col.Close()
+
+	if _, err := col.Exec(schema); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	nowMillis := now.UnixMilli()
+	modelID := nowMillis
+
+	// 依名稱配置牌組 ID，1 為 Anki 的預設牌組
+	deckIDs := map[string]int64{"Default": 1}
+	nextDeckID := nowMillis
+	for _, note := range notes {
+		name := note.Deck
+		for name != "" {
+			if _, ok := deckIDs[name]; !ok {
+				nextDeckID++
+				deckIDs[name] = nextDeckID
+			}
+			// 確保上層牌組也存在
+			i := strings.LastIndex(name, "::")
+			if i < 0 {
+				break
+			}
+			name = name[:i]
+		}
+	}
+
+	decks := make(map[string]interface{})
+	for name, id := range deckIDs {
+		decks[strconv.FormatInt(id, 10)] = deckJSON(id, name, now)
+	}
+
+	flds := make([]map[string]interface{}, len(noteType.Fields))
+	for i, name := range noteType.Fields {
+		flds[i] = map[string]interface{}{
+			"name": name, "ord": i, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{},
+		}
+	}
+	model := map[string]interface{}{
+		"id": modelID, "name": noteType.Name, "type": 0, "mod": now.Unix(), "usn": -1, "sortf": 0, "did": 1,
+		"tmpls": []map[string]interface{}{{
+			"name": "Card 1", "ord": 0, "qfmt": noteType.Front, "afmt": noteType.Back, "did": nil, "bqfmt": "", "bafmt": "",
+		}},
+		"flds": flds, "css": noteType.CSS, "tags": []string{}, "vers": []string{},
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+	}
+
+	conf := map[string]interface{}{
+		"nextPos": len(notes) + 1, "estTimes": true, "activeDecks": []int{1}, "sortType": "noteFld", "timeLim": 0,
+		"sortBackwards": false, "addToCur": true, "curDeck": 1, "newBury": true, "newSpread": 0, "dueCounts": true,
+		"curModel": strconv.FormatInt(modelID, 10), "collapseTime": 1200,
+	}
+
+	_, err = col.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Unix(), nowMillis, nowMillis, mustJSON(conf),
+		mustJSON(map[string]interface{}{strconv.FormatInt(modelID, 10): model}),
+		mustJSON(decks), mustJSON(map[string]interface{}{"1": defaultDeckConfig()}))
+	if err != nil {
+		return err
+	}
+
+	tx, err := col.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, note := range notes {
+		values := make([]string, len(noteType.Fields))
+		for j, name := range noteType.Fields {
+			values[j] = note.Fields[name]
+		}
+		sortField := StripHTML(values[0])
+
+		noteID := nowMillis + int64(i)
+		tags := ""
+		if len(note.Tags) > 0 {
+			tags = " " + strings.Join(note.Tags, " ") + " "
+		}
+		_, err := tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			noteID, guid(), modelID, now.Unix(), tags, strings.Join(values, FieldSeparator), sortField, checksum(sortField))
+		if err != nil {
+			return err
+		}
+
+		deckID := int64(1)
+		if note.Deck != "" {
+			deckID = deckIDs[note.Deck]
+		}
+		_, err = tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			noteID, noteID, deckID, now.Unix(), i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Import reads all notes from an .apkg package
+func Import(r io.ReaderAt, size int64) ([]Note, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Anki 2.1.50 以後的 collection.anki21b 以 zstd 壓縮且結構不同，旁邊的 collection.anki2 只是佔位
+	for _, f := range archive.File {
+		if f.Name == "collection.anki21b" {
+			return nil, ErrNewFormat
+		}
+	}
+
+	// 新版 Anki 會同時提供 collection.anki21，舊版僅有 collection.anki2
+	var collection *zip.File
+	for _, name := range []string{"collection.anki21", "collection.anki2"} {
+		for _, f := range archive.File {
+			if f.Name == name {
+				collection = f
+				break
+			}
+		}
+		if collection != nil {
+			break
+		}
+	}
+	if collection == nil {
+		return nil, ErrNoCollection
+	}
+
+	dir, err := os.MkdirTemp("", "apkg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collection.anki2")
+	if err := extract(collection, path); err != nil {
+		return nil, err
+	}
+
+	col, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, err
+	}
+	defer col.Close()
+
+	var modelsJSON, decksJSON string
+	if err := col.QueryRow("SELECT models, decks FROM col").Scan(&modelsJSON, &decksJSON); err != nil {
+		return nil, ErrNoCollection
+	}
+
+	var models map[string]struct {
+		Name string `json:"name"`
+		Flds []struct {
+			Name string `json:"name"`
+			Ord  int    `json:"ord"`
+		} `json:"flds"`
+	}
+	if err := json.Unmarshal([]byte(modelsJSON), &models); err != nil {
+		return nil, err
+	}
+	var decks map[string]struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		return nil, err
+	}
+
+	rows, err := col.Query(`
+		SELECT n.mid, n.tags, n.flds, COALESCE(MIN(c.did), 1)
+		FROM notes n
+		LEFT JOIN cards c ON c.nid = n.id
+		GROUP BY n.id
+		ORDER BY n.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		var modelID, deckID int64
+		var tags, flds string
+		if err := rows.Scan(&modelID, &tags, &flds, &deckID); err != nil {
+			return nil, err
+		}
+
+		model, ok := models[strconv.FormatInt(modelID, 10)]
+		if !ok {
+			continue
+		}
+		values := strings.Split(flds, FieldSeparator)
+		note := Note{Model: model.Name, Fields: make(map[string]string), Tags: strings.Fields(tags)}
+		for _, fld := range model.Flds {
+			if fld.Ord < len(values) {
+				note.Fields[fld.Name] = values[fld.Ord]
+			}
+		}
+		if deck, ok := decks[strconv.FormatInt(deckID, 10)]; ok && deck.Name != "Default" {
+			note.Deck = deck.Name
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
+var (
+	breakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>`)
+	tagPattern   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// StripHTML converts field HTML to plain text, keeping line breaks
+func StripHTML(s string) string {
+	s = breakPattern.ReplaceAllString(s, "\n")
+	s = tagPattern.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// extract writes a zip entry to path; the size in the header can be forged, so the copy is limited as well
+func extract(f *zip.File, path string) error {
+	if f.UncompressedSize64 > MaxCollectionSize {
+		return ErrCollectionTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, MaxCollectionSize+1))
+	if err == nil && n > MaxCollectionSize {
+		err = ErrCollectionTooLarge
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checksum is the first 8 hex digits of the SHA-1 of the sort field, as Anki stores in notes.csum
+func checksum(s string) int64 {
+	sum := sha1.Sum([]byte(s))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+const guidChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+
+func guid() string {
+	var b bytes.Buffer
+	for i := 0; i < 10; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(guidChars))))
+		if err != nil {
+			// crypto/rand 失敗時退回時間戳
+			return hex.EncodeToString([]byte(fmt.Sprint(time.Now().UnixNano())))
+		}
+		b.WriteByte(guidChars[n.Int64()])
+	}
+	return b.String()
+}
+
+func deckJSON(id int64, name string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "desc": "", "mod": now.Unix(), "usn": -1, "collapsed": false, "browserCollapsed": false,
+		"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		"dyn": 0, "conf": 1, "extendNew": 10, "extendRev": 50,
+	}
+}
+
+func defaultDeckConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]interface{}{
+			"delays": []float64{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": true,
+			"separate": true,
+		},
+		"rev": map[string]interface{}{
+			"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "minSpace": 1, "ivlFct": 1, "maxIvl": 36500, "bury": true, "hardFactor": 1.2,
+		},
+		"lapse": map[string]interface{}{
+			"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+		},
+	}
+}
+
+func mustJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,56 @@
+package anki
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	noteType := NoteType{
+		Name:   "Vocabulary",
+		Fields: []string{"Word", "Definition"},
+		Front:  "{{Word}}",
+		Back:   "{{Definition}}",
+	}
+	notes := []Note{
+		{Fields: map[string]string{"Word": "apple", "Definition": "a fruit"}, Tags: []string{"food"}, Deck: "English::Food"},
+		{Fields: map[string]string{"Word": "run", "Definition": "to move fast"}},
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, noteType, notes); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	imported, err := Import(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if len(imported) != len(notes) {
+		t.Fatalf("imported %d notes, want %d", len(imported), len(notes))
+	}
+	byWord := make(map[string]Note)
+	for _, n := range imported {
+		byWord[n.Fields["Word"]] = n
+	}
+	apple, ok := byWord["apple"]
+	if !ok {
+		t.Fatalf("note for apple missing from %+v", imported)
+	}
+	if apple.Fields["Definition"] != "a fruit" {
+		t.Errorf("Definition = %q, want %q", apple.Fields["Definition"], "a fruit")
+	}
+	if apple.Deck != "English::Food" {
+		t.Errorf("Deck = %q, want %q", apple.Deck, "English::Food")
+	}
+	if len(apple.Tags) != 1 || apple.Tags[0] != "food" {
+		t.Errorf("Tags = %v, want [food]", apple.Tags)
+	}
+}
+
+func TestImportRejectsNonPackage(t *testing.T) {
+	data := []byte("not a zip file")
+	if _, err := Import(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Import accepted a file that is not an Anki package")
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"database/sql"
+	"strings"
+	"vocabulary/internal/models"
+)
+
+// API token 可授予的權限
+const (
+	ScopeVocabularyRead  = "vocabulary:read"
+	ScopeVocabularyWrite = "vocabulary:write"
+	ScopeReviewsRead     = "reviews:read"
+	ScopeReviewsWrite    = "reviews:write"
+)
+
+// Scopes lists every scope an API token can be granted
+var Scopes = []string{ScopeVocabularyRead, ScopeVocabularyWrite, ScopeReviewsRead, ScopeReviewsWrite}
+
+// APITokenPrefix marks API tokens so that they are easy to recognise, e.g. by secret scanners
+const APITokenPrefix = "vocab_"
+
+// ValidScope reports whether scope is one of Scopes
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIToken returns a new token and the short prefix shown to the user to tell tokens apart
+func NewAPIToken() (token, prefix string, err error) {
+	secret, err := RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	token = APITokenPrefix + secret
+	return token, token[:len(APITokenPrefix)+6], nil
+}
+
+// BearerToken returns the token from an "Authorization: Bearer" header, or "" if there is none
+func BearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// AuthenticateAPIToken validates an API token and returns claims carrying its scopes
+func AuthenticateAPIToken(db *sql.DB, token string) (*Claims, error) {
+	t, err := models.AuthenticateAPIToken(db, HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return &Claims{UserID: t.UserID, TokenID: t.ID, Scopes: t.Scopes}, nil
+}
+
+// HasScope reports whether the claims allow the scope; sessions from a browser login allow everything
+func (c *Claims) HasScope(scope string) bool {
+	if c.TokenID == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
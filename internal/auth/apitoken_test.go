@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range Scopes {
+		if !ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = false", scope)
+		}
+	}
+	for _, scope := range []string{"", "admin", "vocabulary", "VOCABULARY:READ", "vocabulary:read "} {
+		if ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = true", scope)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims Claims
+		scope  string
+		want   bool
+	}{
+		{"browser session allows everything", Claims{UserID: 1, SessionID: 2}, ScopeVocabularyWrite, true},
+		{"token with the scope", Claims{UserID: 1, TokenID: 3, Scopes: []string{ScopeReviewsRead}}, ScopeReviewsRead, true},
+		{"token without the scope", Claims{UserID: 1, TokenID: 3, Scopes: []string{ScopeReviewsRead}}, ScopeReviewsWrite, false},
+		{"read does not imply write", Claims{UserID: 1, TokenID: 3, Scopes: []string{ScopeVocabularyRead}}, ScopeVocabularyWrite, false},
+		{"token without scopes", Claims{UserID: 1, TokenID: 3}, ScopeVocabularyRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"Bearer vocab_abc", "vocab_abc"},
+		{"bearer vocab_abc", "vocab_abc"},
+		{"Bearer  vocab_abc ", "vocab_abc"},
+		{"Basic dXNlcjpwYXNz", ""},
+		{"Bearer", ""},
+		{"vocab_abc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := BearerToken(tt.header); got != tt.want {
+			t.Errorf("BearerToken(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNewAPIToken(t *testing.T) {
+	token, prefix, err := NewAPIToken()
+	if err != nil {
+		t.Fatalf("NewAPIToken: %v", err)
+	}
+	if !strings.HasPrefix(token, APITokenPrefix) || !strings.HasPrefix(token, prefix) {
+		t.Errorf("token %q does not start with %q and its prefix %q", token, APITokenPrefix, prefix)
+	}
+	if len(prefix) != len(APITokenPrefix)+6 {
+		t.Errorf("prefix %q has length %d", prefix, len(prefix))
+	}
+	if other, _, _ := NewAPIToken(); other == token {
+		t.Error("NewAPIToken returned the same token twice")
+	}
+}
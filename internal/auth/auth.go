@@ -0,0 +1,165 @@
+// Package auth issues the short-lived access tokens and rotating refresh tokens that make up a login session.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// AccessTokenTTL is how long an access token is accepted before it must be refreshed
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a session may stay idle before the user has to sign in again
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	AccessCookie  = "token"
+	RefreshCookie = "refresh_token"
+)
+
+// ErrInvalidToken is returned for access tokens that are malformed, expired or wrongly signed
+var ErrInvalidToken = errors.New("invalid access token")
+
+// Claims identify the user and the session or API token a request is authenticated with
+type Claims struct {
+	UserID    int64
+	SessionID int64 // 0 表示測試環境簽發、不屬於任何工作階段
+	// 以 API token 驗證時才有值
+	TokenID int64
+	Scopes  []string
+}
+
+func secret() []byte {
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// IssueAccessToken signs an HS256 JWT for the user's session valid for ttl
+func IssueAccessToken(userID, sessionID int64, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	if sessionID != 0 {
+		claims["sid"] = sessionID
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret())
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return secret(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	sessionID, _ := claims["sid"].(float64)
+	return &Claims{UserID: int64(userID), SessionID: int64(sessionID)}, nil
+}
+
+// HashToken returns the hex SHA-256 of a token, which is what the database stores
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomToken returns n random bytes encoded as unpadded base64url
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// StartSession records a new session for the signed-in user and sets the access and refresh cookies
+func StartSession(db *sql.DB, c *gin.Context, userID int64) (*Claims, error) {
+	refresh, err := RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := models.CreateSession(db, &models.Session{
+		UserID:      userID,
+		RefreshHash: HashToken(refresh),
+		UserAgent:   c.Request.UserAgent(),
+		IP:          c.ClientIP(),
+		ExpiresAt:   time.Now().Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := IssueAccessToken(userID, sessionID, AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	SetCookies(c, access, refresh)
+	return &Claims{UserID: userID, SessionID: sessionID}, nil
+}
+
+// RefreshSession exchanges the refresh cookie for a new access token and, unless another request just did so,
+// a new refresh token
+func RefreshSession(db *sql.DB, c *gin.Context) (*Claims, error) {
+	refresh, err := c.Cookie(RefreshCookie)
+	if err != nil || refresh == "" {
+		return nil, models.ErrSessionInvalid
+	}
+
+	next, err := RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	session, rotated, err := models.RotateSession(db, HashToken(refresh), HashToken(next), time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := IssueAccessToken(session.UserID, session.ID, AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	if rotated {
+		SetCookies(c, access, next)
+	} else {
+		setCookie(c, AccessCookie, access, RefreshTokenTTL)
+	}
+	return &Claims{UserID: session.UserID, SessionID: session.ID}, nil
+}
+
+// SetCookies stores both tokens in HttpOnly cookies. The access cookie outlives its token so that an
+// expired token can be recognised and refreshed instead of the request looking anonymous.
+func SetCookies(c *gin.Context, access, refresh string) {
+	setCookie(c, AccessCookie, access, RefreshTokenTTL)
+	setCookie(c, RefreshCookie, refresh, RefreshTokenTTL)
+}
+
+// ClearCookies removes both tokens from the browser
+func ClearCookies(c *gin.Context) {
+	SetCookie(c, AccessCookie, "", -1, "/", true)
+	SetCookie(c, RefreshCookie, "", -1, "/", true)
+}
+
+func setCookie(c *gin.Context, name, value string, ttl time.Duration) {
+	SetCookie(c, name, value, int(ttl.Seconds()), "/", true)
+}
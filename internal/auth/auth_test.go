@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestAccessToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	valid, err := IssueAccessToken(42, 7, time.Minute)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	noSession, err := IssueAccessToken(42, 0, time.Minute)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	expired, err := IssueAccessToken(42, 7, -time.Minute)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"user_id": 42,
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("signing unsigned token: %v", err)
+	}
+	noUser, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	otherSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 42,
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("other-secret"))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *Claims
+		wantErr bool
+	}{
+		{"session token", valid, &Claims{UserID: 42, SessionID: 7}, false},
+		{"token without session", noSession, &Claims{UserID: 42}, false},
+		{"expired", expired, nil, true},
+		{"unsigned", unsigned, nil, true},
+		{"missing user", noUser, nil, true},
+		{"signed with another secret", otherSecret, nil, true},
+		{"garbage", "not-a-token", nil, true},
+		{"empty", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAccessToken(tt.token)
+			if tt.wantErr {
+				if err != ErrInvalidToken {
+					t.Errorf("ParseAccessToken error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			if got.UserID != tt.want.UserID || got.SessionID != tt.want.SessionID {
+				t.Errorf("claims = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// echo -n abc | sha256sum
+	const abc = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != abc {
+		t.Errorf("HashToken(abc) = %s, want %s", got, abc)
+	}
+	if HashToken("abc") == HashToken("abd") {
+		t.Error("different tokens have the same hash")
+	}
+}
+
+func TestRandomToken(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token, err := RandomToken(32)
+		if err != nil {
+			t.Fatalf("RandomToken: %v", err)
+		}
+		b, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil || len(b) != 32 {
+			t.Fatalf("RandomToken = %q, want 32 bytes of base64url", token)
+		}
+		if seen[token] {
+			t.Fatalf("RandomToken repeated %q", token)
+		}
+		seen[token] = true
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// CookieConfig holds the attributes applied to every cookie the app sets
+type CookieConfig struct {
+	// Secure 讓瀏覽器只在 HTTPS 連線送出 cookie，部署在 HTTPS 後方時應開啟
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+var cookieConfig = CookieConfig{SameSite: http.SameSiteLaxMode}
+
+// ConfigureCookiesFromEnv reads COOKIE_SECURE (true/false), COOKIE_SAMESITE (lax, strict or none) and COOKIE_DOMAIN.
+// SameSite=strict keeps the login cookies from being sent when returning from an OpenID Connect provider, so
+// lax is the default.
+func ConfigureCookiesFromEnv() error {
+	cfg := CookieConfig{
+		Secure:   os.Getenv("COOKIE_SECURE") == "true",
+		SameSite: http.SameSiteLaxMode,
+		Domain:   os.Getenv("COOKIE_DOMAIN"),
+	}
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "", "lax":
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		if !cfg.Secure {
+			return fmt.Errorf("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+		}
+		cfg.SameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("COOKIE_SAMESITE must be lax, strict or none, got %q", os.Getenv("COOKIE_SAMESITE"))
+	}
+	cookieConfig = cfg
+	return nil
+}
+
+// SetCookie sets a cookie with the configured Secure, SameSite and Domain attributes; a negative maxAge deletes it
+func SetCookie(c *gin.Context, name, value string, maxAge int, path string, httpOnly bool) {
+	c.SetSameSite(cookieConfig.SameSite)
+	c.SetCookie(name, value, maxAge, path, cookieConfig.Domain, cookieConfig.Secure, httpOnly)
+}
+
+// SessionOptions returns the options for the gin session cookie with the configured attributes
+func SessionOptions(maxAge int) sessions.Options {
+	return sessions.Options{
+		Path:     "/",
+		Domain:   cookieConfig.Domain,
+		MaxAge:   maxAge,
+		Secure:   cookieConfig.Secure,
+		HttpOnly: true,
+		SameSite: cookieConfig.SameSite,
+	}
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	token, err := NewCSRFToken()
+	if err != nil {
+		t.Fatalf("NewCSRFToken: %v", err)
+	}
+	nonce, _, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"issued token", token, true},
+		{"empty", "", false},
+		{"nonce only", nonce, false},
+		{"missing nonce", "." + sign("csrf", ""), false},
+		{"unsigned", nonce + ".", false},
+		{"tampered signature", nonce + "." + flipLast(sign("csrf", nonce)), false},
+		{"tampered nonce", flipLast(nonce) + "." + sign("csrf", nonce), false},
+		{"signed for another purpose", nonce + "." + sign("password-reset", nonce), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidCSRFToken(tt.token); got != tt.want {
+				t.Errorf("ValidCSRFToken(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+		})
+	}
+
+	// 換了伺服器金鑰後，舊的 token 不再有效
+	t.Setenv("JWT_SECRET", "another-secret")
+	if ValidCSRFToken(token) {
+		t.Error("token signed with the previous secret is still valid")
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResetToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	token, issued, err := IssueResetToken(42, now)
+	if err != nil {
+		t.Fatalf("IssueResetToken: %v", err)
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte("1:" + strings.SplitN(mustDecode(t, encoded), ":", 2)[1]))
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", token, now, false},
+		{"just before expiry", token, now.Add(PasswordResetTTL - time.Second), false},
+		{"expired", token, now.Add(PasswordResetTTL), true},
+		{"missing signature", encoded, now, true},
+		{"empty", "", now, true},
+		{"tampered signature", encoded + "." + flipLast(signature), now, true},
+		{"payload changed to another user", forged + "." + signature, now, true},
+		{"signed for another purpose", encoded + "." + sign("login-challenge", encoded), now, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResetToken(tt.token, tt.now)
+			if tt.wantErr {
+				if err != ErrInvalidResetToken {
+					t.Errorf("ParseResetToken error = %v, want ErrInvalidResetToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseResetToken: %v", err)
+			}
+			if got.UserID != issued.UserID || got.Nonce != issued.Nonce || !got.ExpiresAt.Equal(issued.ExpiresAt) {
+				t.Errorf("token = %+v, want %+v", got, issued)
+			}
+		})
+	}
+}
+
+func TestResetTokenSecret(t *testing.T) {
+	now := time.Now()
+	t.Setenv("JWT_SECRET", "first-secret")
+	token, _, err := IssueResetToken(42, now)
+	if err != nil {
+		t.Fatalf("IssueResetToken: %v", err)
+	}
+
+	t.Setenv("JWT_SECRET", "second-secret")
+	if _, err := ParseResetToken(token, now); err != ErrInvalidResetToken {
+		t.Errorf("token signed with another secret: error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestResetTokenUnique(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	now := time.Now()
+	first, _, _ := IssueResetToken(42, now)
+	second, _, _ := IssueResetToken(42, now)
+	if first == second {
+		t.Error("two reset tokens issued at the same time are equal")
+	}
+}
+
+func mustDecode(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// flipLast changes the last character of a base64url string
+func flipLast(s string) string {
+	last := "A"
+	if strings.HasSuffix(s, "A") {
+		last = "B"
+	}
+	return s[:len(s)-1] + last
+}
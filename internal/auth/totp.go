@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TOTPPeriod and TOTPDigits are the RFC 6238 defaults understood by every authenticator app
+	TOTPPeriod = 30 * time.Second
+	TOTPDigits = 6
+	// TOTPSkew is how many periods before and after the current one are accepted to allow for clock drift
+	TOTPSkew = 1
+
+	// RecoveryCodeCount is how many single-use recovery codes are issued at a time
+	RecoveryCodeCount = 10
+
+	// LoginChallengeTTL is how long a user has to enter their code after the password was accepted
+	LoginChallengeTTL = 5 * time.Minute
+	ChallengeCookie   = "login_challenge"
+)
+
+// ErrInvalidChallenge is returned for second-step login tokens that are malformed, forged or expired
+var ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160-bit secret encoded as unpadded base32, the form authenticator apps expect
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32NoPad.EncodeToString(b), nil
+}
+
+// TOTPStep returns the number of the time step containing t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode computes the code for a time step as specified by RFC 4226 and RFC 6238 with HMAC-SHA1
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// VerifyTOTP checks a code against the steps around now that come after lastUsedStep, so that a code cannot
+// be replayed, and returns the step it matched. Callers must still record the step atomically, as two
+// requests may verify the same code at once.
+func VerifyTOTP(secret, code string, lastUsedStep int64, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := TOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// provisioning URI that authenticator apps read from a QR code
+func TOTPURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(TOTPDigits))
+	q.Set("period", strconv.Itoa(int(TOTPPeriod/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// NewRecoveryCodes returns n random codes formatted as two groups of five characters
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(base32NoPad.EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the separators and case a user may type so that the code can be hashed
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// IssueLoginChallenge returns a signed token recording that the user passed the password step at now
+func IssueLoginChallenge(userID int64, now time.Time) string {
+	payload := fmt.Sprintf("%d:%d", userID, now.Add(LoginChallengeTTL).Unix())
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + sign("login-challenge", encoded)
+}
+
+// ParseLoginChallenge verifies a login challenge and returns the user it was issued to
+func ParseLoginChallenge(token string, now time.Time) (int64, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign("login-challenge", encoded))) {
+		return 0, ErrInvalidChallenge
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, ErrInvalidChallenge
+	}
+	uid, exp, ok := strings.Cut(string(payload), ":")
+	if !ok {
+		return 0, ErrInvalidChallenge
+	}
+	userID, err := strconv.ParseInt(uid, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidChallenge
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return 0, ErrInvalidChallenge
+	}
+	return userID, nil
+}
+
+// SetChallengeCookie stores a login challenge until the second step is completed
+func SetChallengeCookie(c *gin.Context, token string) {
+	SetCookie(c, ChallengeCookie, token, int(LoginChallengeTTL.Seconds()), "/login", true)
+}
+
+// ClearChallengeCookie removes the login challenge
+func ClearChallengeCookie(c *gin.Context) {
+	SetCookie(c, ChallengeCookie, "", -1, "/login", true)
+}
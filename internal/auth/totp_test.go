@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附錄 B 的 SHA-1 金鑰 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 附錄 B 的測試向量，取 8 位數結果的後 6 位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	// 驗證器應用程式可能以小寫或含補位的形式顯示金鑰
+	if got, _ := TOTPCode(strings.ToLower(rfcSecret)+"====", TOTPStep(time.Unix(59, 0))); got != "287082" {
+		t.Errorf("lowercase padded secret gave %s, want 287082", got)
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastUsed int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, code(current), 0, current, true},
+		{"spaces ignored", rfcSecret, code(current)[:3] + " " + code(current)[3:], 0, current, true},
+		{"previous step within skew", rfcSecret, code(current - 1), 0, current - 1, true},
+		{"next step within skew", rfcSecret, code(current + 1), 0, current + 1, true},
+		{"outside skew", rfcSecret, code(current - 2), 0, 0, false},
+		{"far in the future", rfcSecret, code(current + 2), 0, 0, false},
+		{"wrong code", rfcSecret, "000000", 0, 0, false},
+		{"too short", rfcSecret, code(current)[:5], 0, 0, false},
+		{"too long", rfcSecret, code(current) + "0", 0, 0, false},
+		{"empty", rfcSecret, "", 0, 0, false},
+		{"invalid secret", "not base32!", code(current), 0, 0, false},
+		// 已使用的區段不能再用，即使仍在允許的時間差內
+		{"replayed code", rfcSecret, code(current), current, 0, false},
+		{"earlier step after a later one was used", rfcSecret, code(current - 1), current, 0, false},
+		{"newer step after an earlier one was used", rfcSecret, code(current), current - 1, current, true},
+		{"next step after the current one was used", rfcSecret, code(current + 1), current, current + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(tt.secret, tt.code, tt.lastUsed, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("VerifyTOTP = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	key, err := base32NoPad.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("secret %q does not decode to 20 bytes", secret)
+	}
+	if other, _ := NewTOTPSecret(); other == secret {
+		t.Error("NewTOTPSecret returned the same secret twice")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Vocabulary App", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("invalid URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("URI = %s, want otpauth://totp/...", uri)
+	}
+	if uri.Path != "/Vocabulary App:alice@example.com" {
+		t.Errorf("label = %q", uri.Path)
+	}
+	q := uri.Query()
+	for key, want := range map[string]string{
+		"secret": rfcSecret, "issuer": "Vocabulary App", "algorithm": "SHA1", "digits": "6", "period": "30",
+	} {
+		if q.Get(key) != want {
+			t.Errorf("%s = %q, want %q", key, q.Get(key), want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not match xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q issued twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"abcde-fghij", "abcdefghij"},
+		{"ABCDE-FGHIJ", "abcdefghij"},
+		{" abcde fghij ", "abcdefghij"},
+		{"abcdefghij", "abcdefghij"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLoginChallenge(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	token := IssueLoginChallenge(42, now)
+	encoded, _, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", token, now, false},
+		{"just before expiry", token, now.Add(LoginChallengeTTL - time.Second), false},
+		{"expired", token, now.Add(LoginChallengeTTL), true},
+		{"unsigned", encoded, now, true},
+		{"empty", "", now, true},
+		{"signed for another purpose", encoded + "." + sign("password-reset", encoded), now, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := ParseLoginChallenge(tt.token, tt.now)
+			if tt.wantErr {
+				if err != ErrInvalidChallenge {
+					t.Errorf("ParseLoginChallenge error = %v, want ErrInvalidChallenge", err)
+				}
+				return
+			}
+			if err != nil || userID != 42 {
+				t.Errorf("ParseLoginChallenge = %d, %v, want 42", userID, err)
+			}
+		})
+	}
+}
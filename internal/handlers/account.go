@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+	"vocabulary/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 帳號封存檔的大小上限，封存檔包含文章全文，因此比一般上傳寬鬆
+const maxArchiveSize = 100 << 20
+
+// 申請刪除帳號後的保留期限，期間內重新登入即可取消
+const accountDeletionGracePeriod = 14 * 24 * time.Hour
+
+// ExportAccount 下載使用者所有資料的 JSON 封存檔，可在其他伺服器上還原
+func ExportAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var archive *models.Archive
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		archive = &models.Archive{
+			Version:    models.ArchiveVersion,
+			ExportedAt: time.Now().UTC(),
+			Profile:    models.ArchiveProfile{Username: "test"},
+			Decks:      []models.ArchiveDeck{},
+			Tags:       []models.ArchiveTag{},
+			Articles:   []models.ArchiveArticle{},
+			Vocabularies: []models.ArchiveVocabulary{{
+				ID: 1, Word: "example", Status: "active",
+				Definitions: []models.ArchiveDefinition{
+					{PartOfSpeech: "noun", Definition: "a representative form or pattern"},
+				},
+			}},
+			TestResults: []models.ArchiveTestResult{},
+		}
+	} else {
+		var err error
+		archive, err = models.ExportArchive(db, userID.(int64))
+		if err != nil {
+			log.Println("Error exporting account:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting account"})
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("vocabulary-archive-%s.json", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.JSON(http.StatusOK, archive)
+}
+
+// ImportAccount 將封存檔還原到目前的帳號，帳號中不可已有單字或文章
+// 封存檔可以 multipart 的 file 欄位上傳，或直接作為 JSON 請求本文
+func ImportAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize)
+
+	var archive models.Archive
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer file.Close()
+		if err := json.NewDecoder(file).Decode(&archive); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File is not a valid archive"})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&archive); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Archive file or JSON body is required"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "restored": models.RestoreStats{Vocabularies: len(archive.Vocabularies)}})
+		return
+	}
+
+	stats, err := models.RestoreArchive(db, userID.(int64), &archive)
+	switch {
+	case errors.Is(err, models.ErrArchiveVersion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Archive version %d is not supported", archive.Version)})
+		return
+	case errors.Is(err, models.ErrAccountNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": "Archives can only be restored into an empty account"})
+		return
+	case errors.Is(err, models.ErrDeckCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Archive contains decks nested inside themselves"})
+		return
+	case err != nil:
+		log.Println("Error restoring account:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error restoring archive"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "restored": stats})
+}
+
+// ExportPersonalData 下載伺服器上儲存的所有個人資料，包含封存檔以外的設定、登入紀錄與連結的帳號
+// 密碼與金鑰的雜湊不會匯出
+func ExportPersonalData(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var data *models.PersonalData
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		now := time.Now().UTC()
+		data = &models.PersonalData{
+			Version:    models.PersonalDataVersion,
+			ExportedAt: now,
+			Account:    models.PersonalAccount{ID: userID.(int64), Username: "test", Role: models.RoleUser},
+			Content:    &models.Archive{Version: models.ArchiveVersion, ExportedAt: now, Profile: models.ArchiveProfile{Username: "test"}},
+			Records:    map[string][]map[string]interface{}{},
+		}
+	} else {
+		var err error
+		data, err = models.ExportPersonalData(db, userID.(int64))
+		if err != nil || data == nil {
+			log.Println("Error exporting personal data:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting personal data"})
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("vocabulary-personal-data-%s.json", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.JSON(http.StatusOK, data)
+}
+
+// RequestAccountDeletion 申請刪除帳號：立即登出所有裝置，保留期限過後永久刪除所有資料
+// 保留期限內重新登入即取消刪除。需以目前的密碼或剛在身分提供者重新登入確認身分
+func RequestAccountDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	scheduledAt := time.Now().Add(accountDeletionGracePeriod).UTC().Truncate(time.Second)
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		auth.ClearCookies(c)
+		c.JSON(http.StatusOK, gin.H{"success": true, "deletion_scheduled_at": scheduledAt})
+		return
+	}
+
+	user, ok := reauthenticate(c, userID.(int64), c.PostForm("current_password"))
+	if !ok {
+		return
+	}
+	err := models.ScheduleDeletion(db, user.ID, scheduledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is already scheduled"})
+		return
+	}
+	if err != nil {
+		log.Println("Error scheduling account deletion:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scheduling account deletion"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventAccountDeletionRequested, scheduledAt.Format(time.RFC3339))
+
+	if user.Email != "" {
+		if err := mailDeletionNotice(user, scheduledAt); err != nil && !errors.Is(err, notify.ErrUnknownChannel) {
+			log.Println("Error sending account deletion notice:", err)
+		}
+	}
+
+	auth.ClearCookies(c)
+	c.JSON(http.StatusOK, gin.H{"success": true, "deletion_scheduled_at": scheduledAt})
+}
+
+func mailDeletionNotice(user *models.User, at time.Time) error {
+	return notify.Send("email", user.Email, notify.Message{
+		Subject: "Your Vocabulary account will be deleted",
+		Text: fmt.Sprintf("Hi %s,\n\nYour Vocabulary account and all of its data will be permanently deleted on %s. "+
+			"You have been signed out on every device.\n\n"+
+			"If you change your mind, sign in again before then at %s/login and the deletion will be cancelled.\n",
+			user.Username, at.Format("2 January 2006 15:04 MST"), appBaseURL()),
+	})
+}
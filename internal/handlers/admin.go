@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/models"
+	"vocabulary/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理介面每頁顯示的筆數
+const adminPageSize = 50
+
+// ShowAdminConsole 顯示管理介面
+func ShowAdminConsole(c *gin.Context) {
+	c.HTML(http.StatusOK, "console.html", gin.H{
+		"title":           "Admin",
+		"IsAuthenticated": true,
+		"roles":           models.Roles,
+	})
+}
+
+// adminPage 解析 page 參數，回傳 offset
+func adminPage(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return page, (page - 1) * adminPageSize
+}
+
+// ListUsers 搜尋使用者（管理員）
+func ListUsers(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	page, offset := adminPage(c)
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"users": []gin.H{
+				{"id": 1, "username": os.Getenv("TEST_USER"), "email": "", "role": models.RoleAdmin, "disabled": false,
+					"words": 1, "reviews": 0, "created_at": time.Now(), "last_login_at": nil},
+			},
+			"total":     1,
+			"page":      page,
+			"page_size": adminPageSize,
+		})
+		return
+	}
+
+	users, total, err := models.ListUsers(db, query, adminPageSize, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching users"})
+		return
+	}
+	list := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		list = append(list, gin.H{
+			"id":                    u.ID,
+			"username":              u.Username,
+			"email":                 u.Email,
+			"role":                  u.Role,
+			"disabled":              u.DisabledAt != nil,
+			"disabled_at":           u.DisabledAt,
+			"deletion_scheduled_at": u.DeletionScheduledAt,
+			"words":                 u.Words,
+			"reviews":               u.Reviews,
+			"created_at":            u.CreatedAt,
+			"last_login_at":         u.LastLoginAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users":     list,
+		"total":     total,
+		"page":      page,
+		"page_size": adminPageSize,
+	})
+}
+
+// loadTargetUser 依路徑中的 ID 取得使用者，失敗時已寫入回應
+func loadTargetUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+	user, err := models.GetUserByID(db, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+// isSelf 管理員不能停用自己或變更自己的角色，避免沒有人能管理站台
+func isSelf(c *gin.Context, user *models.User) bool {
+	adminID, _ := c.Get("user_id")
+	if id, ok := adminID.(int64); ok && id == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own account here"})
+		return true
+	}
+	return false
+}
+
+// SetUserRole 變更使用者角色（管理員）
+func SetUserRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !validRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of: " + strings.Join(models.Roles, ", ")})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "role": req.Role})
+		return
+	}
+
+	user, ok := loadTargetUser(c)
+	if !ok || isSelf(c, user) {
+		return
+	}
+	if err := models.SetUserRole(db, user.ID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating role"})
+		return
+	}
+	if user.Role != req.Role {
+		recordAuthEvent(c, user.ID, user.Username, models.EventRoleChanged, user.Role+" -> "+req.Role+adminDetail(c))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "role": req.Role})
+}
+
+func validRole(role string) bool {
+	for _, r := range models.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// adminDetail 在稽核紀錄中註明執行操作的管理員
+func adminDetail(c *gin.Context) string {
+	adminID, _ := c.Get("user_id")
+	if id, ok := adminID.(int64); ok {
+		return " by admin " + strconv.FormatInt(id, 10)
+	}
+	return ""
+}
+
+// DisableUser 停用帳號並登出所有裝置、撤銷 API token（管理員）
+func DisableUser(c *gin.Context) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user, ok := loadTargetUser(c)
+	if !ok || isSelf(c, user) {
+		return
+	}
+	err := models.DisableUser(db, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is already disabled"})
+		return
+	}
+	if err != nil {
+		log.Println("Error disabling user:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error disabling account"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventAccountDisabled, strings.TrimSpace(adminDetail(c)))
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// EnableUser 重新啟用帳號（管理員）
+func EnableUser(c *gin.Context) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user, ok := loadTargetUser(c)
+	if !ok {
+		return
+	}
+	err := models.EnableUser(db, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is not disabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error enabling account"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventAccountEnabled, strings.TrimSpace(adminDetail(c)))
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AdminResetPassword 為使用者產生重設密碼連結（管理員）；有電子郵件時一併寄出，否則由管理員轉交
+func AdminResetPassword(c *gin.Context) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "reset_link": appBaseURL() + "/password/reset?token=test", "emailed": false})
+		return
+	}
+
+	user, ok := loadTargetUser(c)
+	if !ok {
+		return
+	}
+	link, err := issuePasswordReset(user, time.Now())
+	if err != nil {
+		log.Println("Error issuing password reset:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating password reset link"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventAdminPasswordReset, strings.TrimSpace(adminDetail(c)))
+
+	emailed := false
+	if user.Email != "" {
+		err := mailPasswordReset(user, link)
+		switch {
+		case err == nil:
+			emailed = true
+		case !errors.Is(err, notify.ErrUnknownChannel):
+			log.Println("Error sending password reset:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "reset_link": link, "emailed": emailed})
+}
+
+// GetSystemStats 回傳整個站台的使用統計（管理員）
+func GetSystemStats(c *gin.Context) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"users": 1, "disabled_users": 0, "users_by_role": gin.H{models.RoleAdmin: 1},
+			"active_users_7d": 0, "new_users_7d": 0, "vocabularies": 0, "reviews": 0, "reviews_7d": 0,
+			"articles": 0, "shared_decks": 1, "active_sessions": 0, "dictionary_cache": 0,
+		})
+		return
+	}
+
+	s, err := models.GetSystemStats(db, time.Now())
+	if err != nil {
+		log.Println("Error fetching system stats:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users":            s.Users,
+		"disabled_users":   s.DisabledUsers,
+		"users_by_role":    s.UsersByRole,
+		"active_users_7d":  s.ActiveUsers7d,
+		"new_users_7d":     s.NewUsers7d,
+		"vocabularies":     s.Vocabularies,
+		"reviews":          s.Reviews,
+		"reviews_7d":       s.Reviews7d,
+		"articles":         s.Articles,
+		"shared_decks":     s.SharedDecks,
+		"active_sessions":  s.ActiveSessions,
+		"dictionary_cache": s.DictionaryCache,
+	})
+}
+
+// ListDictionaryCache 列出快取的字典查詢結果（管理員）
+func ListDictionaryCache(c *gin.Context) {
+	prefix := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	page, offset := adminPage(c)
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"entries": []gin.H{}, "total": 0, "page": page, "page_size": adminPageSize})
+		return
+	}
+
+	entries, total, err := models.ListDictionaryCache(db, prefix, adminPageSize, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching dictionary cache"})
+		return
+	}
+	list := make([]gin.H, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, gin.H{
+			"word":        e.Word,
+			"definitions": e.Definitions,
+			"not_found":   e.NotFound,
+			"hits":        e.Hits,
+			"fetched_at":  e.FetchedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": list, "total": total, "page": page, "page_size": adminPageSize})
+}
+
+// DeleteDictionaryCacheEntry 移除單一快取，下次查詢時重新向字典 API 取得（管理員）
+func DeleteDictionaryCacheEntry(c *gin.Context) {
+	word := strings.ToLower(strings.TrimSpace(c.Param("word")))
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err := models.DeleteCachedDefinitions(db, word)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Word is not cached"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting cached word"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// PurgeDictionaryCache 清除快取；指定 older_than_days 時只清除較舊的項目（管理員）
+func PurgeDictionaryCache(c *gin.Context) {
+	var before time.Time
+	if daysStr := c.Query("older_than_days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid older_than_days"})
+			return
+		}
+		before = time.Now().AddDate(0, 0, -days)
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "deleted": 0})
+		return
+	}
+
+	deleted, err := models.PurgeDictionaryCache(db, before)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error purging dictionary cache"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "deleted": deleted})
+}
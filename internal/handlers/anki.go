@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/anki"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 匯出時使用的 Anki 筆記類型；同一單字的多個定義以 <br> 分隔並逐一對齊
+// Tags 欄位不顯示在卡片上，保存標籤的原名（Anki 標籤不可包含空白），舊版匯出的檔案沒有這個欄位
+var ankiNoteType = anki.NoteType{
+	Name:   "Vocabulary",
+	Fields: []string{"Word", "PartOfSpeech", "Definition", "Example", "Context", ankiTagsField},
+	Front:  "<div class=\"word\">{{Word}}</div>",
+	Back: "{{FrontSide}}<hr id=answer>" +
+		"<div class=\"pos\">{{PartOfSpeech}}</div><div class=\"definition\">{{Definition}}</div>" +
+		"<div class=\"example\">{{Example}}</div>{{#Context}}<div class=\"context\">{{Context}}</div>{{/Context}}",
+	CSS: ".card { font-family: arial; font-size: 20px; text-align: center; }\n" +
+		".word { font-size: 32px; font-weight: bold; }\n" +
+		".pos, .example, .context { color: #666; font-style: italic; }",
+}
+
+const ankiTagsField = "Tags"
+
+var ankiBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+// ExportAnki 將使用者的單字匯出為 Anki .apkg，可用 deck_id 或 tag 限定範圍
+func ExportAnki(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var deckID int64
+	if deckIDStr := c.Query("deck_id"); deckIDStr != "" {
+		var err error
+		deckID, err = strconv.ParseInt(deckIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID format"})
+			return
+		}
+	}
+	tag := strings.TrimSpace(c.Query("tag"))
+
+	var vocabularies []models.Vocabulary
+	deckPaths := make(map[int64]string)
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		vocabularies = []models.Vocabulary{{
+			Word: "example",
+			Definitions: []models.VocabularyDefinition{
+				{PartOfSpeech: "noun", Definition: "a representative form or pattern", Example: "This is an example of a test word."},
+			},
+		}}
+	} else {
+		var err error
+		vocabularies, err = models.GetByUserID(db, userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+			return
+		}
+		vocabularies, err = filterByScope(vocabularies, userID.(int64), deckID, tag)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+			return
+		}
+		decks, err := models.GetDecksByUserID(db, userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+			return
+		}
+		deckPaths = deckPathsByID(decks)
+	}
+
+	notes := make([]anki.Note, 0, len(vocabularies))
+	for _, v := range vocabularies {
+		notes = append(notes, vocabularyToNote(v, deckPaths[v.DeckID]))
+	}
+
+	var buf bytes.Buffer
+	if err := anki.Export(&buf, ankiNoteType, notes); err != nil {
+		log.Println("Error exporting Anki package:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting vocabulary"})
+		return
+	}
+
+	filename := fmt.Sprintf("vocabulary-%s.apkg", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/octet-stream", buf.Bytes())
+}
+
+// ImportAnki 從 Anki .apkg 匯入單字
+//
+// 表單欄位：
+//   - file: 上傳的 .apkg
+//   - mapping: JSON，其他筆記類型的欄位對應，例如 {"word": "Front", "definition": "Back"}
+//   - duplicates: skip、merge 或 overwrite（預設 skip）
+//   - dry_run: true 時只回傳預覽，不寫入資料庫
+func ImportAnki(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	duplicates := c.DefaultPostForm("duplicates", "skip")
+	if duplicates != "skip" && duplicates != "merge" && duplicates != "overwrite" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicates must be skip, merge or overwrite"})
+		return
+	}
+	dryRun := c.PostForm("dry_run") == "true"
+
+	mapping := map[string]string{"word": "", "part_of_speech": "", "definition": "", "example": "", "context": ""}
+	if mappingJSON := c.PostForm("mapping"); mappingJSON != "" {
+		var custom map[string]string
+		if err := json.Unmarshal([]byte(mappingJSON), &custom); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mapping must be a JSON object of field names"})
+			return
+		}
+		for field, name := range custom {
+			if _, ok := mapping[field]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown field %q in mapping", field)})
+				return
+			}
+			mapping[field] = name
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	notes, err := anki.Import(bytes.NewReader(data), int64(len(data)))
+	if errors.Is(err, anki.ErrNewFormat) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "This package uses the newer Anki format. Export it again with \"Support older Anki versions\" checked",
+		})
+		return
+	}
+	if errors.Is(err, anki.ErrCollectionTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The Anki collection is too large"})
+		return
+	}
+	if err != nil {
+		log.Println("Error reading Anki package:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is not a readable Anki package"})
+		return
+	}
+	if len(notes) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Packages are limited to %d notes", maxImportRows)})
+		return
+	}
+
+	// 依單字分組，同一單字的多個筆記合併為多個定義
+	var words []*importWord
+	index := make(map[string]*importWord)
+	decksByWord := make(map[*importWord]string)
+	contexts := make(map[*importWord]string)
+	rowErrors := []importRowError{}
+	for i, note := range notes {
+		word, definitions, context := noteToVocabulary(note, mapping)
+		if word == "" {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: "Note has no word"})
+			continue
+		}
+
+		key := strings.ToLower(word)
+		w, ok := index[key]
+		if !ok {
+			w = &importWord{Word: word}
+			index[key] = w
+			words = append(words, w)
+			decksByWord[w] = note.Deck
+		}
+		w.Definitions = append(w.Definitions, definitions...)
+		w.Tags = append(w.Tags, noteTags(note)...)
+		w.rows = append(w.rows, i+1)
+		if contexts[w] == "" {
+			contexts[w] = context
+		}
+	}
+
+	if !resolveImport(c, userID.(int64), words, rowErrors, duplicates, dryRun) {
+		return
+	}
+	if !dryRun {
+		decks, err := models.GetDecksByUserID(db, userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+			return
+		}
+
+		rowErrors = saveImport(userID.(int64), words, rowErrors, func(w *importWord) models.Vocabulary {
+			vocabulary := models.Vocabulary{Word: w.Word, Definitions: w.Definitions, Tags: w.Tags, Context: contexts[w]}
+			if path := decksByWord[w]; path != "" {
+				vocabulary.DeckID, decks, err = ensureDeckPath(userID.(int64), decks, path)
+				if err != nil {
+					log.Println("Error creating deck:", path, err)
+				}
+			}
+			return vocabulary
+		})
+	}
+
+	c.JSON(http.StatusOK, importSummary(words, rowErrors, dryRun))
+}
+
+// vocabularyToNote 將單字轉為 Anki 筆記，各定義的詞性、解釋與例句以 <br> 對齊
+func vocabularyToNote(v models.Vocabulary, deckPath string) anki.Note {
+	var partsOfSpeech, definitions, examples []string
+	for _, def := range v.Definitions {
+		partsOfSpeech = append(partsOfSpeech, html.EscapeString(def.PartOfSpeech))
+		definitions = append(definitions, html.EscapeString(def.Definition))
+		examples = append(examples, html.EscapeString(strings.ReplaceAll(def.Example, "&quot;", "\"")))
+	}
+
+	// Anki 標籤不可包含空白
+	tags := make([]string, 0, len(v.Tags))
+	names := make([]string, 0, len(v.Tags))
+	for _, tag := range v.Tags {
+		tags = append(tags, strings.Join(strings.Fields(tag), "_"))
+		names = append(names, html.EscapeString(tag))
+	}
+
+	return anki.Note{
+		Fields: map[string]string{
+			"Word":         html.EscapeString(v.Word),
+			"PartOfSpeech": strings.Join(partsOfSpeech, "<br>"),
+			"Definition":   strings.Join(definitions, "<br>"),
+			"Example":      strings.Join(examples, "<br>"),
+			"Context":      html.EscapeString(v.Context),
+			ankiTagsField:  strings.Join(names, "<br>"),
+		},
+		Tags: tags,
+		Deck: deckPath,
+	}
+}
+
+// noteToVocabulary 取出筆記中的單字、定義與上下文
+// 本站匯出的筆記類型會依 <br> 還原多個定義；其他筆記類型預設取第一、二個欄位作為單字與定義
+func noteToVocabulary(note anki.Note, mapping map[string]string) (string, []models.VocabularyDefinition, string) {
+	field := func(name, fallback string) string {
+		if mapping[name] != "" {
+			return note.Fields[mapping[name]]
+		}
+		return note.Fields[fallback]
+	}
+
+	ownType := true
+	for _, name := range ankiNoteType.Fields {
+		if name == ankiTagsField {
+			continue
+		}
+		if _, ok := note.Fields[name]; !ok {
+			ownType = false
+			break
+		}
+	}
+
+	if !ownType && mapping["word"] == "" {
+		// 其他筆記類型：常見的 Front/Back 欄位
+		mapping = map[string]string{"word": "Front", "definition": "Back"}
+		for k, v := range mapping {
+			if _, ok := note.Fields[v]; !ok {
+				delete(mapping, k)
+			}
+		}
+	}
+
+	word := anki.StripHTML(field("word", "Word"))
+	context := anki.StripHTML(field("context", "Context"))
+
+	if ownType && mapping["definition"] == "" {
+		partsOfSpeech := splitAnkiField(note.Fields["PartOfSpeech"])
+		examples := splitAnkiField(note.Fields["Example"])
+		var definitions []models.VocabularyDefinition
+		for i, text := range splitAnkiField(note.Fields["Definition"]) {
+			def := models.VocabularyDefinition{Definition: text}
+			if i < len(partsOfSpeech) {
+				def.PartOfSpeech = partsOfSpeech[i]
+			}
+			if i < len(examples) {
+				def.Example = strings.ReplaceAll(examples[i], "\"", "&quot;")
+			}
+			definitions = append(definitions, def)
+		}
+		return word, definitions, context
+	}
+
+	definition := anki.StripHTML(field("definition", "Definition"))
+	if definition == "" {
+		return word, nil, context
+	}
+	return word, []models.VocabularyDefinition{{
+		PartOfSpeech: anki.StripHTML(field("part_of_speech", "PartOfSpeech")),
+		Definition:   definition,
+		Example:      strings.ReplaceAll(anki.StripHTML(field("example", "Example")), "\"", "&quot;"),
+	}}, context
+}
+
+// noteTags 優先使用本站匯出時保存的標籤原名，否則使用 Anki 的標籤
+func noteTags(note anki.Note) []string {
+	if names := splitAnkiField(note.Fields[ankiTagsField]); len(names) > 0 {
+		return names
+	}
+	return note.Tags
+}
+
+func splitAnkiField(value string) []string {
+	if value == "" {
+		return nil
+	}
+	parts := ankiBreakPattern.Split(value, -1)
+	for i, part := range parts {
+		parts[i] = anki.StripHTML(part)
+	}
+	return parts
+}
+
+// deckPathsByID 將每個牌組對應到 Anki 的完整路徑，例如 "Parent::Child"
+func deckPathsByID(decks []*models.Deck) map[int64]string {
+	byID := make(map[int64]*models.Deck)
+	for _, d := range decks {
+		byID[d.ID] = d
+	}
+
+	paths := make(map[int64]string)
+	for _, d := range decks {
+		names := []string{d.Name}
+		seen := map[int64]bool{d.ID: true}
+		for parent := byID[d.ParentID]; parent != nil && !seen[parent.ID]; parent = byID[parent.ParentID] {
+			seen[parent.ID] = true
+			names = append([]string{parent.Name}, names...)
+		}
+		paths[d.ID] = strings.Join(names, "::")
+	}
+	return paths
+}
+
+// ensureDeckPath 找出 Anki 牌組路徑對應的牌組，不存在的層級會依序建立
+func ensureDeckPath(userID int64, decks []*models.Deck, path string) (int64, []*models.Deck, error) {
+	var parentID int64
+	for _, name := range strings.Split(path, "::") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		var found *models.Deck
+		for _, d := range decks {
+			if d.ParentID == parentID && strings.EqualFold(d.Name, name) {
+				found = d
+				break
+			}
+		}
+		if found == nil {
+			id, err := models.CreateDeck(db, userID, parentID, name)
+			if err != nil {
+				return 0, decks, err
+			}
+			found = &models.Deck{ID: id, UserID: userID, ParentID: parentID, Name: name}
+			decks = append(decks, found)
+		}
+		parentID = found.ID
+	}
+	return parentID, decks, nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API token 最長有效天數；不填則不會過期
+const maxAPITokenDays = 365
+
+// ListAPITokens 列出使用者的 API token（不含明文）
+func ListAPITokens(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"tokens": []gin.H{}, "scopes": auth.Scopes})
+		return
+	}
+
+	tokens, err := models.GetAPITokens(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching API tokens"})
+		return
+	}
+	result := make([]gin.H, 0, len(tokens))
+	for _, t := range tokens {
+		result = append(result, apiTokenJSON(t))
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": result, "scopes": auth.Scopes})
+}
+
+// CreateAPIToken 建立新的 API token；明文只會在這次回應中出現
+//
+// 表單欄位：name、scopes（可重複或以逗號分隔）、expires_in_days（選填，1–365）
+func CreateAPIToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token name is required"})
+		return
+	}
+
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, value := range c.PostFormArray("scopes") {
+		for _, scope := range strings.Split(value, ",") {
+			scope = strings.TrimSpace(scope)
+			if scope == "" || seen[scope] {
+				continue
+			}
+			if !auth.ValidScope(scope) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope})
+				return
+			}
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one scope is required"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if daysStr := c.PostForm("expires_in_days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 1 || days > maxAPITokenDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be between 1 and 365"})
+			return
+		}
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	token, prefix, err := auth.NewAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+	t := models.APIToken{
+		UserID:    userID.(int64),
+		Name:      name,
+		Prefix:    prefix,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		t.ID = 1
+	} else {
+		t.ID, err = models.CreateAPIToken(db, &t)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving token"})
+			return
+		}
+	}
+
+	result := apiTokenJSON(t)
+	result["token"] = token
+	c.JSON(http.StatusCreated, result)
+}
+
+// RevokeAPIToken 撤銷 API token，之後使用它的請求會被拒絕
+func RevokeAPIToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err = models.RevokeAPIToken(db, userID.(int64), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func apiTokenJSON(t models.APIToken) gin.H {
+	return gin.H{
+		"id":           t.ID,
+		"name":         t.Name,
+		"prefix":       t.Prefix,
+		"scopes":       t.Scopes,
+		"created_at":   t.CreatedAt,
+		"last_used_at": t.LastUsedAt,
+		"expires_at":   t.ExpiresAt,
+	}
+}
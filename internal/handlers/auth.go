@@ -2,14 +2,22 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/mail"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"vocabulary/internal/auth"
 	"vocabulary/internal/models"
+	"vocabulary/internal/oidc"
+	"vocabulary/internal/password"
 
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,12 +25,18 @@ var db *sql.DB
 
 func Init(database *sql.DB) {
 	db = database
+	initLoginLimiters()
+	oidcProvider = oidc.FromEnv()
 }
 
 func ShowLogin(c *gin.Context) {
-	c.HTML(http.StatusOK, "login.html", gin.H{
+	data := gin.H{
 		"title": "Login",
-	})
+	}
+	if oidcProvider != nil {
+		data["oidcName"] = oidcProvider.DisplayName
+	}
+	c.HTML(http.StatusOK, "login.html", data)
 }
 
 func ShowRegister(c *gin.Context) {
@@ -31,23 +45,22 @@ func ShowRegister(c *gin.Context) {
 	})
 }
 
+// 登入失敗時一律回傳相同訊息，不透露帳號是否存在
+const invalidLoginMessage = "Invalid username or password"
+
 func Login(c *gin.Context) {
 	log.Println("🚀 Login function executed!") // 登入函式是否執行
 	username := c.PostForm("username")
 	password := c.PostForm("password")
-	log.Println("📌 Received Login Request - Username:", username, "Password:", password)
+	log.Println("📌 Received Login Request - Username:", username)
 
 	// 開發模式 直接登入
 	if os.Getenv("SKIP_DB") == "true" &&
 		username == os.Getenv("TEST_USER") &&
 		password == os.Getenv("TEST_PASSWORD") {
 
-		// 生成測試 JWT token
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"user_id": 1,
-			"exp":     time.Now().Add(time.Hour * 24).Unix(),
-		})
-		tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+		// 生成測試 JWT token，不建立工作階段
+		tokenString, err := auth.IssueAccessToken(1, 0, time.Hour*24)
 		if err != nil {
 			c.HTML(http.StatusInternalServerError, "login.html", gin.H{
 				"error": "Error generating token",
@@ -55,15 +68,23 @@ func Login(c *gin.Context) {
 			return
 		}
 
-		c.SetCookie("token", tokenString, 3600*24, "/", "", false, true)
+		auth.SetCookie(c, auth.AccessCookie, tokenString, 3600*24, "/", true)
 		c.Redirect(http.StatusFound, "/news")
 		return
 	}
 
-	// 正式模式 檢查用戶名是否存在
+	// 同一 IP 或同一帳號失敗太多次時先暫停嘗試
+	now := time.Now()
+	attempt, wait := startLoginAttempt(c.ClientIP(), loginAccountKey(username), now)
+	if wait > 0 {
+		recordAuthEvent(c, 0, username, models.EventLoginThrottled, "")
+		respondThrottled(c, "login.html", username, wait)
+		return
+	}
+
 	user, err := models.GetUserByUsername(db, username)
 	if err != nil {
-		c.HTML(http.StatusBadRequest, "login.html", gin.H{
+		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
 			"error":    "Error checking username",
 			"username": username,
 		})
@@ -71,47 +92,199 @@ func Login(c *gin.Context) {
 	}
 
 	if user == nil {
-		c.HTML(http.StatusBadRequest, "login.html", gin.H{
-			"error":    "Username does not exist",
-			"username": username,
-		})
+		// 仍比對一次密碼，讓回應時間與帳號存在時相同
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		loginFailed(c, "login.html", 0, username, attempt, "unknown username", now)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
 		// 密碼錯誤
-		log.Println("🔒 Hashed Password from DB:", user.Password)
-		log.Println("🔑 User Input Password:", password)
-		log.Println("❌ Password comparison failed:", err) // 記錄錯誤原因
-		c.HTML(http.StatusBadRequest, "login.html", gin.H{
-			"error":    "Incorrect password",
-			"username": username, // 保留用戶輸入的用戶名
-		})
+		loginFailed(c, "login.html", user.ID, username, attempt, "wrong password", now)
 		return
 	}
 
-	// 生成 JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// 已啟用兩步驟驗證時，密碼正確後還需輸入驗證碼才建立工作階段
+	if startSecondFactor(c, user, now) {
+		return
+	}
+
+	completeLogin(c, user, attempt, "")
+}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+// startSecondFactor 若使用者已啟用兩步驟驗證，改為顯示輸入驗證碼的頁面並回傳 true
+func startSecondFactor(c *gin.Context, user *models.User, now time.Time) bool {
+	twoFactor, err := models.HasTOTPEnabled(db, user.ID)
 	if err != nil {
+		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+			"error":    "Error checking two-factor authentication",
+			"username": user.Username,
+		})
+		return true
+	}
+	if !twoFactor {
+		return false
+	}
+	auth.SetChallengeCookie(c, auth.IssueLoginChallenge(user.ID, now))
+	c.HTML(http.StatusOK, "login_2fa.html", gin.H{
+		"title": "Two-Factor Authentication",
+	})
+	return true
+}
+
+// completeLogin 在所有驗證步驟通過後建立工作階段並簽發 access token 與 refresh token；
+// attempt 為密碼或驗證碼登入時的節流計數，其他登入方式為 nil
+func completeLogin(c *gin.Context, user *models.User, attempt *loginAttempt, detail string) {
+	// 停用的帳號在驗證通過後才拒絕，避免透露帳號狀態給不知道密碼的人
+	if user.DisabledAt != nil {
+		recordAuthEvent(c, user.ID, user.Username, models.EventLoginFailed, "account disabled")
+		auth.ClearChallengeCookie(c)
+		c.HTML(http.StatusForbidden, "login.html", gin.H{
+			"title":    "Login",
+			"error":    "This account has been disabled. Please contact an administrator.",
+			"username": user.Username,
+		})
+		return
+	}
+
+	// 保留期限內重新登入即取消刪除帳號
+	if user.DeletionScheduledAt != nil {
+		cancelled, err := models.CancelDeletion(db, user.ID)
+		if err != nil {
+			log.Println("Error cancelling account deletion:", err)
+			c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+				"title":    "Login",
+				"error":    "Error restoring your account, please try again",
+				"username": user.Username,
+			})
+			return
+		}
+		if cancelled {
+			recordAuthEvent(c, user.ID, user.Username, models.EventAccountDeletionCancelled, "")
+		}
+	}
+
+	if attempt != nil {
+		attempt.succeeded()
+	}
+
+	if _, err := auth.StartSession(db, c, user.ID); err != nil {
+		log.Println("Error starting session:", err)
 		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
 			"error":    "Error generating token",
-			"username": username, // 保留用戶輸入的用戶名
+			"username": user.Username, // 保留用戶輸入的用戶名
 		})
 		return
 	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventLoginSucceeded, detail)
 
-	c.SetCookie("token", tokenString, 3600*24, "/", "", false, true)
 	c.Redirect(http.StatusFound, "/news")
 }
 
+// loginFailed 記錄稽核紀錄與鎖定事件，並在指定頁面回傳統一的錯誤訊息；失敗次數已在開始嘗試時計入
+func loginFailed(c *gin.Context, page string, userID int64, username string, attempt *loginAttempt, reason string, now time.Time) {
+	recordAuthEvent(c, userID, username, models.EventLoginFailed, reason)
+
+	for _, count := range attempt.counted {
+		if count.status.Locked && count.status.Failures == lockoutThreshold(count.limiter) {
+			recordAuthEvent(c, userID, username, models.EventAccountLocked, "locked by "+lockoutSource(count.limiter))
+		}
+	}
+
+	if wait := attempt.wait(now); wait > 0 {
+		respondThrottled(c, page, username, wait)
+		return
+	}
+	message := invalidLoginMessage
+	if page == "login_2fa.html" {
+		message = invalidCodeMessage
+	}
+	c.HTML(http.StatusBadRequest, page, gin.H{
+		"error":    message,
+		"username": username, // 保留用戶輸入的用戶名
+	})
+}
+
+func respondThrottled(c *gin.Context, page, username string, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.HTML(http.StatusTooManyRequests, page, gin.H{
+		"error":    fmt.Sprintf("Too many failed login attempts. Please try again in %s.", formatWait(seconds)),
+		"username": username,
+	})
+}
+
+func formatWait(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+	return fmt.Sprintf("%d minutes", (seconds+59)/60)
+}
+
+func recordAuthEvent(c *gin.Context, userID int64, username, event, detail string) {
+	err := models.RecordAuthEvent(db, &models.AuthEvent{
+		UserID:    userID,
+		Username:  username,
+		Event:     event,
+		Detail:    detail,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		log.Println("Error recording auth event:", err)
+	}
+}
+
 func Register(c *gin.Context) {
-	username := c.PostForm("username")
-	password := c.PostForm("password")
+	username := strings.TrimSpace(c.PostForm("username"))
+	newPassword := c.PostForm("password")
+	email := strings.TrimSpace(c.PostForm("email"))
+
+	if username == "" {
+		c.HTML(http.StatusBadRequest, "register.html", gin.H{
+			"error": "Username is required",
+			"email": email,
+		})
+		return
+	}
+	if err := password.Validate(newPassword, username); err != nil {
+		c.HTML(http.StatusBadRequest, "register.html", gin.H{
+			"error":    err.Error(),
+			"username": username,
+			"email":    email,
+		})
+		return
+	}
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			c.HTML(http.StatusBadRequest, "register.html", gin.H{
+				"error":    "Invalid email address",
+				"username": username,
+				"email":    email,
+			})
+			return
+		}
+		// 只儲存地址本身，寄信時 SMTP 通知只接受不含名稱的地址
+		email = addr.Address
+		existing, err := models.GetUserByEmail(db, email)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "register.html", gin.H{
+				"error":    "Error checking email",
+				"username": username,
+				"email":    email,
+			})
+			return
+		}
+		if existing != nil {
+			c.HTML(http.StatusBadRequest, "register.html", gin.H{
+				"error":    "Email is already used by another account",
+				"username": username,
+				"email":    email,
+			})
+			return
+		}
+	}
 
 	// 檢查用戶名是否已存在
 	existingUser, err := models.GetUserByUsername(db, username)
@@ -132,7 +305,7 @@ func Register(c *gin.Context) {
 	}
 
 	// 加密密碼
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "register.html", gin.H{
 			"error":    "Error processing registration",
@@ -142,7 +315,7 @@ func Register(c *gin.Context) {
 	}
 
 	// 創建用戶
-	if err := models.CreateUser(db, username, string(hashedPassword)); err != nil {
+	if err := models.CreateUser(db, username, string(hashedPassword), email); err != nil {
 		c.HTML(http.StatusInternalServerError, "register.html", gin.H{
 			"error":    "Error creating user",
 			"username": username,
@@ -154,7 +327,28 @@ func Register(c *gin.Context) {
 }
 
 func Logout(c *gin.Context) {
-	// Clear the JWT token cookie
-	c.SetCookie("token", "", -1, "/", "", false, true)
+	// 在伺服器端撤銷目前的工作階段，讓 token 即使外洩也無法再使用
+	if os.Getenv("SKIP_DB") != "true" {
+		if refresh, err := c.Cookie(auth.RefreshCookie); err == nil && refresh != "" {
+			if err := models.RevokeSessionByRefresh(db, auth.HashToken(refresh)); err != nil {
+				log.Println("Error revoking session:", err)
+			}
+		} else if tokenString, err := c.Cookie(auth.AccessCookie); err == nil {
+			if claims, err := auth.ParseAccessToken(tokenString); err == nil && claims.SessionID != 0 {
+				models.RevokeSession(db, claims.UserID, claims.SessionID)
+			}
+		}
+	}
+
+	// 重新驗證身分的紀錄只屬於這次登入
+	session := sessions.Default(c)
+	session.Delete(reauthUserKey)
+	session.Delete(reauthAtKey)
+	if err := session.Save(); err != nil {
+		log.Println("Error saving session:", err)
+	}
+
+	// Clear the JWT token cookies
+	auth.ClearCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
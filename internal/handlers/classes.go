@@ -0,0 +1,729 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 加入代碼不使用容易混淆的字元（0/O、1/I/L）
+const (
+	joinCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	joinCodeLength   = 8
+	// 班級名稱與指派標題的長度上限，與資料表欄位一致
+	maxTitleLength = 100
+
+	invalidDueDateMessage = "Due date must be a date like 2006-01-02"
+)
+
+// ShowClasses 顯示老師的班級管理頁面
+func ShowClasses(c *gin.Context) {
+	c.HTML(http.StatusOK, "classes.html", gin.H{
+		"title":           "Classes",
+		"IsAuthenticated": true,
+	})
+}
+
+func newJoinCode() (string, error) {
+	b := make([]byte, joinCodeLength)
+	max := big.NewInt(int64(len(joinCodeAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = joinCodeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// normalizeJoinCode 忽略大小寫與使用者輸入的空白、連字號
+func normalizeJoinCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// ListClasses 列出老師建立的班級
+func ListClasses(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"classes": []gin.H{
+				{"id": 1, "name": "Test class", "join_code": "TESTCODE", "student_count": 1, "created_at": time.Now()},
+			},
+		})
+		return
+	}
+
+	classes, err := models.GetClassesByTeacher(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching classes"})
+		return
+	}
+	list := make([]gin.H, 0, len(classes))
+	for _, cl := range classes {
+		list = append(list, classJSON(cl))
+	}
+	c.JSON(http.StatusOK, gin.H{"classes": list})
+}
+
+func classJSON(cl models.Class) gin.H {
+	return gin.H{
+		"id":            cl.ID,
+		"name":          cl.Name,
+		"join_code":     cl.JoinCode,
+		"student_count": cl.StudentCount,
+		"created_at":    cl.CreatedAt,
+	}
+}
+
+// CreateClass 建立班級並產生加入代碼
+func CreateClass(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	name, ok := bindClassName(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "id": 1, "join_code": "TESTCODE"})
+		return
+	}
+
+	// 代碼有 31^8 種組合，重複的機率可以忽略；萬一重複，資料庫的 UNIQUE 限制會讓建立失敗
+	code, err := newJoinCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating join code"})
+		return
+	}
+	id, err := models.CreateClass(db, userID.(int64), name, code)
+	if err != nil {
+		log.Println("Error creating class:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating class"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "join_code": code})
+}
+
+// bindClassName 讀取班級名稱，失敗時已寫入回應
+func bindClassName(c *gin.Context) (string, bool) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > maxTitleLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is too long"})
+		return "", false
+	}
+	return name, true
+}
+
+// loadTeacherClass 取得老師自己的班級，失敗時已寫入回應
+func loadTeacherClass(c *gin.Context) (*models.Class, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid class ID"})
+		return nil, false
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		return &models.Class{ID: id, TeacherID: userID.(int64), Name: "Test class", JoinCode: "TESTCODE"}, true
+	}
+
+	class, err := models.GetClass(db, userID.(int64), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching class"})
+		return nil, false
+	}
+	if class == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Class not found"})
+		return nil, false
+	}
+	return class, true
+}
+
+// GetClass 回傳班級的學生與指派
+func GetClass(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"class":       classJSON(*class),
+			"students":    []gin.H{{"id": 2, "username": "student", "joined_at": time.Now()}},
+			"assignments": []gin.H{},
+		})
+		return
+	}
+
+	students, err := models.GetClassStudents(db, class.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching students"})
+		return
+	}
+	assignments, err := models.GetAssignments(db, class.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching assignments"})
+		return
+	}
+
+	studentList := make([]gin.H, 0, len(students))
+	for _, s := range students {
+		studentList = append(studentList, gin.H{
+			"id":        s.UserID,
+			"username":  s.Username,
+			"joined_at": s.JoinedAt,
+		})
+	}
+	assignmentList := make([]gin.H, 0, len(assignments))
+	for _, a := range assignments {
+		assignmentList = append(assignmentList, assignmentJSON(a))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"class":       classJSON(*class),
+		"students":    studentList,
+		"assignments": assignmentList,
+	})
+}
+
+func assignmentJSON(a models.Assignment) gin.H {
+	return gin.H{
+		"id":         a.ID,
+		"class_id":   a.ClassID,
+		"class_name": a.ClassName,
+		"title":      a.Title,
+		"due_at":     a.DueAt,
+		"word_count": a.WordCount,
+		"created_at": a.CreatedAt,
+	}
+}
+
+// RenameClass 變更班級名稱
+func RenameClass(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+	name, ok := bindClassName(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.RenameClass(db, class.TeacherID, class.ID, name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error renaming class"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DeleteClass 刪除班級與其指派；學生已加入的單字會保留
+func DeleteClass(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.DeleteClass(db, class.TeacherID, class.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting class"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ResetJoinCode 產生新的加入代碼，舊代碼立即失效
+func ResetJoinCode(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "join_code": "NEWCODE2"})
+		return
+	}
+
+	code, err := newJoinCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating join code"})
+		return
+	}
+	if err := models.SetJoinCode(db, class.TeacherID, class.ID, code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating join code"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "join_code": code})
+}
+
+// RemoveStudent 將學生移出班級
+func RemoveStudent(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+	studentID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err = models.RemoveStudent(db, class.TeacherID, class.ID, studentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing student"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+type assignmentRequest struct {
+	Title        string `json:"title"`
+	DeckID       int64  `json:"deck_id"`        // 老師自己的牌組（含子牌組）
+	SharedDeckID int64  `json:"shared_deck_id"` // 或公開單字表
+	DueAt        string `json:"due_at"`         // YYYY-MM-DD 或 RFC 3339
+}
+
+// CreateAssignment 將牌組或公開單字表指派給班級；單字會複製一份，之後修改牌組不影響指派
+func CreateAssignment(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+
+	var req assignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if (req.DeckID == 0) == (req.SharedDeckID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Choose either a deck or a word list"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		if _, ok := parseDueDate(req.DueAt, time.UTC); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidDueDateMessage})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "id": 1, "word_count": 1})
+		return
+	}
+
+	prefs, err := (&models.User{ID: class.TeacherID}).GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	dueAt, ok := parseDueDate(req.DueAt, prefs.Location())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidDueDateMessage})
+		return
+	}
+
+	title, words, ok := assignmentWords(c, class.TeacherID, req)
+	if !ok {
+		return
+	}
+	if len(words) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The selected deck has no words"})
+		return
+	}
+	if t := strings.TrimSpace(req.Title); t != "" {
+		title = t
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is too long"})
+		return
+	}
+
+	id, err := models.CreateAssignment(db, class.ID, title, dueAt, words)
+	if err != nil {
+		log.Println("Error creating assignment:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating assignment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "word_count": len(words)})
+}
+
+// parseDueDate 接受日期（當天結束時到期）或完整的時間
+func parseDueDate(s string, loc *time.Location) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if day, err := time.ParseInLocation(models.DayFormat, s, loc); err == nil {
+		return day.AddDate(0, 0, 1).Add(-time.Second), true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// assignmentWords 取得要指派的單字與預設標題，失敗時已寫入回應
+func assignmentWords(c *gin.Context, teacherID int64, req assignmentRequest) (string, []models.SharedDeckWord, bool) {
+	if req.SharedDeckID != 0 {
+		deck, err := models.GetSharedDeck(db, req.SharedDeckID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching word list"})
+			return "", nil, false
+		}
+		if deck == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Word list not found"})
+			return "", nil, false
+		}
+		return deck.Name, deck.Words, true
+	}
+
+	deck, err := models.GetDeck(db, teacherID, req.DeckID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching deck"})
+		return "", nil, false
+	}
+	if deck == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deck not found"})
+		return "", nil, false
+	}
+	vocabularies, err := models.GetByUserID(db, teacherID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+		return "", nil, false
+	}
+	vocabularies, err = filterByScope(vocabularies, teacherID, deck.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+		return "", nil, false
+	}
+
+	words := make([]models.SharedDeckWord, 0, len(vocabularies))
+	for _, v := range vocabularies {
+		words = append(words, models.SharedDeckWord{Word: v.Word, Definitions: v.Definitions})
+	}
+	return deck.Name, words, true
+}
+
+// DeleteAssignment 刪除指派
+func DeleteAssignment(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("assignment_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err = models.DeleteAssignment(db, class.TeacherID, class.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting assignment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetClassProgress 回傳每位學生在指派單字上的進度，依學生的複習紀錄計算；可用 assignment_id 限定單一指派
+func GetClassProgress(c *gin.Context) {
+	class, ok := loadTeacherClass(c)
+	if !ok {
+		return
+	}
+	var assignmentID int64
+	if idStr := c.Query("assignment_id"); idStr != "" {
+		var err error
+		assignmentID, err = strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+			return
+		}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"students": []gin.H{progressJSON(models.AssignmentProgress{
+			UserID: 2, Username: "student", WordsTotal: 1, WordsAdded: 1, WordsLearned: 1, Reviews: 2, Correct: 1,
+		})}})
+		return
+	}
+
+	progress, err := models.GetClassProgress(db, class.ID, assignmentID)
+	if err != nil {
+		log.Println("Error fetching class progress:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching progress"})
+		return
+	}
+	list := make([]gin.H, 0, len(progress))
+	for _, p := range progress {
+		list = append(list, progressJSON(p))
+	}
+	c.JSON(http.StatusOK, gin.H{"students": list})
+}
+
+func progressJSON(p models.AssignmentProgress) gin.H {
+	return gin.H{
+		"id":             p.UserID,
+		"username":       p.Username,
+		"words_total":    p.WordsTotal,
+		"words_added":    p.WordsAdded,
+		"words_learned":  p.WordsLearned,
+		"reviews":        p.Reviews,
+		"correct":        p.Correct,
+		"accuracy":       p.Accuracy(),
+		"last_review_at": p.LastReviewAt,
+	}
+}
+
+// JoinClass 學生以加入代碼加入班級
+func JoinClass(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	code := normalizeJoinCode(c.PostForm("join_code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Join code is required"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "class": gin.H{"id": 1, "name": "Test class", "teacher": "teacher"}})
+		return
+	}
+
+	class, err := models.JoinClass(db, userID.(int64), code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error joining class"})
+		return
+	}
+	if class == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No class has this join code"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"class":   gin.H{"id": class.ID, "name": class.Name, "teacher": class.TeacherName},
+	})
+}
+
+// LeaveClass 學生退出班級
+func LeaveClass(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid class ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err = models.LeaveClass(db, userID.(int64), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not a member of this class"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leaving class"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListMyAssignments 回傳學生加入的班級與指派，以及自己的進度；顯示在單字卡頁面
+func ListMyAssignments(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"classes": []gin.H{}, "assignments": []gin.H{}})
+		return
+	}
+
+	uid := userID.(int64)
+	classes, err := models.GetClassesByStudent(db, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching classes"})
+		return
+	}
+	assignments, err := models.GetStudentAssignments(db, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching assignments"})
+		return
+	}
+	progress, err := models.GetStudentProgress(db, uid)
+	if err != nil {
+		log.Println("Error fetching assignment progress:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching progress"})
+		return
+	}
+
+	classList := make([]gin.H, 0, len(classes))
+	for _, cl := range classes {
+		classList = append(classList, gin.H{"id": cl.ID, "name": cl.Name, "teacher": cl.TeacherName})
+	}
+	now := time.Now()
+	assignmentList := make([]gin.H, 0, len(assignments))
+	for _, a := range assignments {
+		p := progress[a.ID]
+		p.WordsTotal = a.WordCount
+		item := assignmentJSON(a)
+		item["overdue"] = now.After(a.DueAt)
+		item["progress"] = progressJSON(p)
+		assignmentList = append(assignmentList, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"classes": classList, "assignments": assignmentList})
+}
+
+// ImportAssignment 將指派中尚未擁有的單字加入學生的單字庫
+func ImportAssignment(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "imported": 1})
+		return
+	}
+
+	uid := userID.(int64)
+	assignment, ok := loadStudentAssignment(c, uid, id)
+	if !ok {
+		return
+	}
+	known, err := models.GetWordSet(db, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+		return
+	}
+
+	// 在同一個交易中加入所有單字，失敗時不會只匯入一部分
+	var vocabularies []models.Vocabulary
+	for _, w := range assignment.Words {
+		key := strings.ToLower(w.Word)
+		if known[key] {
+			continue
+		}
+		vocabularies = append(vocabularies, models.Vocabulary{Word: w.Word, Definitions: w.Definitions})
+		known[key] = true
+	}
+	if err := models.CreateBatch(db, uid, vocabularies); err != nil {
+		log.Println("Error importing assignment words:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error importing words"})
+		return
+	}
+	imported := len(vocabularies)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "imported": imported})
+}
+
+// loadStudentAssignment 取得學生所屬班級的指派，失敗時已寫入回應
+func loadStudentAssignment(c *gin.Context, userID, id int64) (*models.Assignment, bool) {
+	assignment, err := models.GetStudentAssignment(db, userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching assignment"})
+		return nil, false
+	}
+	if assignment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return nil, false
+	}
+	return assignment, true
+}
+
+// filterByAssignment 只保留指派中的單字
+func filterByAssignment(vocabularies []models.Vocabulary, assignment *models.Assignment) []models.Vocabulary {
+	words := make(map[string]bool, len(assignment.Words))
+	for _, w := range assignment.Words {
+		words[strings.ToLower(w.Word)] = true
+	}
+	var filtered []models.Vocabulary
+	for _, v := range vocabularies {
+		if words[strings.ToLower(v.Word)] {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
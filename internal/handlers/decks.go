@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTags 列出使用者的標籤
+func ListTags(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"tags": []gin.H{{"id": 1, "name": "example", "word_count": 1}},
+		})
+		return
+	}
+
+	tags, err := models.GetTagsByUserID(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tags"})
+		return
+	}
+
+	list := []gin.H{}
+	for _, t := range tags {
+		list = append(list, gin.H{"id": t.ID, "name": t.Name, "word_count": t.WordCount})
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": list})
+}
+
+func CreateTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "id": 1})
+		return
+	}
+
+	id, err := models.CreateTag(db, userID.(int64), models.NormalizeTags([]string{name})[0])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating tag"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id})
+}
+
+func RenameTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.RenameTag(db, userID.(int64), id, models.NormalizeTags([]string{name})[0]); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		if errors.Is(err, models.ErrTagExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error renaming tag"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func DeleteTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.DeleteTag(db, userID.(int64), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting tag"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListDecks 以樹狀結構列出使用者的牌組
+func ListDecks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"decks": []gin.H{{"id": 1, "parent_id": 0, "name": "Example", "word_count": 1, "children": []gin.H{}}},
+		})
+		return
+	}
+
+	decks, err := models.GetDecksByUserID(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decks": deckTreeJSON(models.BuildDeckTree(decks))})
+}
+
+func CreateDeck(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	name, parentID, ok := bindDeckForm(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "id": 1})
+		return
+	}
+
+	if parentID != 0 && !checkDeckOwner(c, userID.(int64), parentID) {
+		return
+	}
+
+	id, err := models.CreateDeck(db, userID.(int64), parentID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating deck"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id})
+}
+
+// UpdateDeck 重新命名牌組或移動到其他牌組之下
+func UpdateDeck(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	name, parentID, ok := bindDeckForm(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if parentID != 0 && !checkDeckOwner(c, userID.(int64), parentID) {
+		return
+	}
+
+	err = models.UpdateDeck(db, userID.(int64), id, parentID, name)
+	switch {
+	case errors.Is(err, models.ErrDeckCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A deck cannot be moved inside itself"})
+		return
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deck not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating deck"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DeleteDeck 刪除牌組及其子牌組，單字本身保留
+func DeleteDeck(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.DeleteDeck(db, userID.(int64), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deck not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting deck"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// checkDeckOwner 確認牌組屬於使用者，失敗時直接回應錯誤
+func checkDeckOwner(c *gin.Context, userID, deckID int64) bool {
+	deck, err := models.GetDeck(db, userID, deckID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching deck"})
+		return false
+	}
+	if deck == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deck not found"})
+		return false
+	}
+	return true
+}
+
+// filterByScope 只保留指定牌組（含子牌組）或標籤中的單字，兩者皆為空時不篩選
+func filterByScope(vocabularies []models.Vocabulary, userID, deckID int64, tag string) ([]models.Vocabulary, error) {
+	if deckID == 0 && tag == "" {
+		return vocabularies, nil
+	}
+
+	var deckIDs map[int64]bool
+	if deckID != 0 {
+		decks, err := models.GetDecksByUserID(db, userID)
+		if err != nil {
+			return nil, err
+		}
+		deckIDs = models.DeckDescendants(decks, deckID)
+	}
+
+	var filtered []models.Vocabulary
+	for _, v := range vocabularies {
+		if deckIDs != nil && !deckIDs[v.DeckID] {
+			continue
+		}
+		if tag != "" && !containsFold(v.Tags, tag) {
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+	return filtered, nil
+}
+
+func bindDeckForm(c *gin.Context) (string, int64, bool) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return "", 0, false
+	}
+
+	var parentID int64
+	if parentIDStr := c.PostForm("parent_id"); parentIDStr != "" {
+		var err error
+		parentID, err = strconv.ParseInt(parentIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID format"})
+			return "", 0, false
+		}
+	}
+	return name, parentID, true
+}
+
+func deckTreeJSON(decks []*models.Deck) []gin.H {
+	list := []gin.H{}
+	for _, d := range decks {
+		list = append(list, gin.H{
+			"id":         d.ID,
+			"parent_id":  d.ParentID,
+			"name":       d.Name,
+			"word_count": d.WordCount,
+			"children":   deckTreeJSON(d.Children),
+		})
+	}
+	return list
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
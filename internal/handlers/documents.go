@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"vocabulary/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gin-gonic/gin"
+)
+
+// 上傳文件的大小上限
+const maxUploadSize = 20 << 20
+
+// EPUB 解壓縮後所有讀取內容的總量上限，避免小檔案解壓成巨大內容
+const maxEPUBContentSize = 100 << 20
+
+var (
+	errUnsupportedDocument = errors.New("unsupported document type")
+	errEmptyDocument       = errors.New("document has no readable text")
+	errDocumentTooLarge    = errors.New("document is too large once uncompressed")
+)
+
+// 純文字檔中的章節標題，例如 "Chapter 1" 或 "CHAPTER IV"
+var chapterHeadingPattern = regexp.MustCompile(`(?i)^(chapter|part|book)\s+[\w.:-]+`)
+
+// UploadDocument 上傳 EPUB、TXT 或 HTML 文件並存入使用者的文章庫
+func UploadDocument(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	title, sourceType, chapters, err := parseDocument(fileHeader.Filename, data)
+	switch {
+	case errors.Is(err, errUnsupportedDocument):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type, please upload EPUB, TXT or HTML"})
+		return
+	case errors.Is(err, errEmptyDocument):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No readable text found in file"})
+		return
+	case errors.Is(err, errDocumentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large once uncompressed"})
+		return
+	case err != nil:
+		log.Println("Error parsing document:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file"})
+		return
+	}
+
+	chapterList := make([]gin.H, len(chapters))
+	for i, chapter := range chapters {
+		chapterList[i] = gin.H{"index": i, "title": chapter.Title}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"article_id": 1,
+			"title":      title,
+			"chapters":   chapterList,
+		})
+		return
+	}
+
+	articleID, err := models.CreateDocument(db, userID.(int64), title, sourceType, chapters)
+	if err != nil {
+		log.Println("Error saving document:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"article_id": articleID,
+		"title":      title,
+		"chapters":   chapterList,
+	})
+}
+
+// ListArticles 列出使用者文章庫中的文章
+func ListArticles(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"articles": []gin.H{}})
+		return
+	}
+
+	articles, err := models.GetArticlesByUserID(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching articles"})
+		return
+	}
+
+	list := []gin.H{}
+	for _, a := range articles {
+		list = append(list, gin.H{
+			"id":          a.ID,
+			"title":       a.Title,
+			"source_type": a.SourceType,
+			"source_url":  a.SourceURL,
+			"created_at":  a.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"articles": list})
+}
+
+// GetArticle 回傳文章資訊；網頁文章附上內容，上傳的文件則附上章節列表
+func GetArticle(c *gin.Context) {
+	article, ok := loadArticle(c)
+	if !ok {
+		return
+	}
+
+	chapters := []gin.H{}
+	for _, chapter := range article.Chapters {
+		chapters = append(chapters, gin.H{"index": chapter.Position, "title": chapter.Title})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          article.ID,
+		"title":       article.Title,
+		"source_type": article.SourceType,
+		"source_url":  article.SourceURL,
+		"content":     article.Content,
+		"chapters":    chapters,
+	})
+}
+
+// GetArticleChapter 回傳單一章節的HTML內容，格式與 FetchNews 相同
+func GetArticleChapter(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chapter index"})
+		return
+	}
+
+	article, ok := loadArticle(c)
+	if !ok {
+		return
+	}
+
+	if index < 0 || index >= len(article.Chapters) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chapter not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Header("X-Article-ID", strconv.FormatInt(article.ID, 10))
+	c.String(http.StatusOK, article.Chapters[index].Content)
+}
+
+// loadArticle 依路徑參數載入使用者的文章，失敗時直接回應錯誤
+func loadArticle(c *gin.Context) (*models.Article, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		return &models.Article{
+			ID:         id,
+			UserID:     1,
+			Title:      "Example",
+			SourceType: "txt",
+			Chapters: []models.ArticleChapter{
+				{ArticleID: id, Title: "Chapter 1", Content: "<p>This is an example of a test word.</p>"},
+			},
+		}, true
+	}
+
+	article, err := models.GetArticleByID(db, userID.(int64), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching article"})
+		return nil, false
+	}
+	if article == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return nil, false
+	}
+
+	return article, true
+}
+
+// checkArticleOwner 確認文章屬於使用者，失敗時直接回應錯誤
+func checkArticleOwner(c *gin.Context, userID, articleID int64) bool {
+	ok, err := models.ArticleExists(db, userID, articleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching article"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return false
+	}
+	return true
+}
+
+// articleParagraphs 取得文章全部段落，上傳的文件會合併所有章節
+func articleParagraphs(article *models.Article) []string {
+	if len(article.Chapters) == 0 {
+		return parseParagraphs(article.Content)
+	}
+
+	var paragraphs []string
+	for _, chapter := range article.Chapters {
+		paragraphs = append(paragraphs, parseParagraphs(chapter.Content)...)
+	}
+	return paragraphs
+}
+
+// parseDocument 依副檔名解析上傳的文件，回傳標題、來源類型與章節
+func parseDocument(filename string, data []byte) (string, string, []models.ArticleChapter, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	title := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	var sourceType string
+	var chapters []models.ArticleChapter
+	switch ext {
+	case ".txt":
+		sourceType = "txt"
+		chapters = parseTextDocument(data)
+	case ".html", ".htm", ".xhtml":
+		sourceType = "html"
+		var docTitle string
+		docTitle, chapters = parseHTMLDocument(data)
+		if docTitle != "" {
+			title = docTitle
+		}
+	case ".epub":
+		sourceType = "epub"
+		docTitle, epubChapters, err := parseEPUB(data)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if docTitle != "" {
+			title = docTitle
+		}
+		chapters = epubChapters
+	default:
+		return "", "", nil, errUnsupportedDocument
+	}
+
+	if len(chapters) == 0 {
+		return "", "", nil, errEmptyDocument
+	}
+	return title, sourceType, chapters, nil
+}
+
+// parseTextDocument 以空白行切分段落，並依章節標題切分章節
+func parseTextDocument(data []byte) []models.ArticleChapter {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var chapters []models.ArticleChapter
+	// 第一個章節標題之前的內容
+	title := "Introduction"
+	var paragraphs []string
+	flush := func() {
+		if len(paragraphs) > 0 {
+			chapters = append(chapters, models.ArticleChapter{
+				Title:   title,
+				Content: renderParagraphs(paragraphs),
+			})
+		}
+		paragraphs = nil
+	}
+
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Fields(block)
+		if len(lines) == 0 {
+			continue
+		}
+		paragraph := strings.Join(lines, " ")
+		if chapterHeadingPattern.MatchString(paragraph) && len(paragraph) < 100 {
+			flush()
+			title = paragraph
+			continue
+		}
+		paragraphs = append(paragraphs, paragraph)
+	}
+	flush()
+
+	return chapters
+}
+
+// parseHTMLDocument 以與 FetchNews 相同的方式擷取段落
+func parseHTMLDocument(data []byte) (string, []models.ArticleChapter) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return "", nil
+	}
+
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	paragraphs := extractParagraphs(doc)
+	if len(paragraphs) == 0 {
+		return title, nil
+	}
+
+	chapterTitle := title
+	if chapterTitle == "" {
+		chapterTitle = "Chapter 1"
+	}
+	return title, []models.ArticleChapter{{Title: chapterTitle, Content: renderParagraphs(paragraphs)}}
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Title    string `xml:"metadata>title"`
+	Manifest []struct {
+		ID   string `xml:"id,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// parseEPUB 依照 spine 的順序讀取每個章節
+func parseEPUB(data []byte) (string, []models.ArticleChapter, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	budget := int64(maxEPUBContentSize)
+
+	var container epubContainer
+	if err := readEPUBXML(files, "META-INF/container.xml", &container, &budget); err != nil {
+		return "", nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return "", nil, errors.New("epub has no rootfile")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := readEPUBXML(files, opfPath, &pkg, &budget); err != nil {
+		return "", nil, err
+	}
+
+	hrefs := make(map[string]string)
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+
+	var chapters []models.ArticleChapter
+	for _, itemRef := range pkg.Spine {
+		href, ok := hrefs[itemRef.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		f, ok := files[path.Join(path.Dir(opfPath), href)]
+		if !ok {
+			continue
+		}
+
+		content, err := readEPUBFile(f, &budget)
+		if err != nil {
+			return "", nil, err
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+		if err != nil {
+			return "", nil, err
+		}
+
+		chapterTitle := strings.TrimSpace(doc.Find("h1, h2, h3").First().Text())
+		if chapterTitle == "" {
+			chapterTitle = strings.TrimSpace(doc.Find("title").First().Text())
+		}
+		if chapterTitle == "" {
+			chapterTitle = fmt.Sprintf("Chapter %d", len(chapters)+1)
+		}
+
+		paragraphs := extractParagraphs(doc)
+		if len(paragraphs) == 0 {
+			continue
+		}
+		chapters = append(chapters, models.ArticleChapter{
+			Title:   chapterTitle,
+			Content: renderParagraphs(paragraphs),
+		})
+	}
+
+	return strings.TrimSpace(pkg.Title), chapters, nil
+}
+
+func readEPUBXML(files map[string]*zip.File, name string, v interface{}, budget *int64) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("epub is missing %s", name)
+	}
+	content, err := readEPUBFile(f, budget)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(content, v)
+}
+
+// readEPUBFile 解壓縮一個檔案並從剩餘額度中扣除；標頭中的大小可能是假的，因此也限制實際讀取的量
+func readEPUBFile(f *zip.File, budget *int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(*budget) {
+		return nil, errDocumentTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > *budget {
+		return nil, errDocumentTooLarge
+	}
+	*budget -= int64(len(content))
+	return content, nil
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"os"
 	"strconv"
@@ -52,6 +54,26 @@ func StartTest(c *gin.Context) {
 		return
 	}
 
+	// 限定牌組或標籤
+	var deckID int64
+	if deckIDStr := c.Query("deck_id"); deckIDStr != "" {
+		deckID, err = strconv.ParseInt(deckIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID format"})
+			return
+		}
+	}
+	vocabularies, err = filterByScope(vocabularies, userID.(int64), deckID, c.Query("tag"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+		return
+	}
+
+	// 可選擇常用單字優先
+	if order := c.Query("order"); order == "frequency" {
+		sortVocabularies(vocabularies, "frequency")
+	}
+
 	if len(vocabularies) == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -68,6 +90,9 @@ func StartTest(c *gin.Context) {
 			"word":        v.Word,
 			"tested":      v.Tested,
 			"Definitions": v.Definitions,
+			"frequency":   v.FrequencyRank,
+			"level":       v.CEFRLevel,
+			"tags":        v.Tags,
 		}
 		words = append(words, word)
 	}
@@ -113,13 +138,15 @@ func SaveTestResult(c *gin.Context) {
 		return
 	}
 
-	// 如果需要，保存測試結果
-	if tested {
-		user := &models.User{ID: userID.(int64)}
-		if err := user.SaveTestResult(db, wordIDStr, true); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving test result"})
+	// 保存測試結果，答錯也要記錄以更新排程
+	user := &models.User{ID: userID.(int64)}
+	if err := user.SaveTestResult(db, wordIDStr, tested); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Vocabulary not found"})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving test result"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+	"vocabulary/internal/notify"
+	"vocabulary/internal/reminder"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxDailyGoal = 1000
+	// 單次回報的閱讀時間上限，避免分頁閒置時灌水；每日總計另以當天已經過的時間為上限
+	maxReadingReport = 60
+
+	// 提醒只寄往以驗證碼確認過的目標；驗證碼與測試提醒都限制寄送頻率，避免被用來寄信給他人
+	reminderCodeTTL      = time.Hour
+	reminderCodeCooldown = 5 * time.Minute
+	testReminderCooldown = 5 * time.Minute
+)
+
+// GetGoals 回傳每日目標、今天的進度與連續達成天數
+func GetGoals(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		p := &models.Preferences{Timezone: "UTC", GoalReviews: 20}
+		today := models.DailyProgress{Day: time.Now().UTC().Format(models.DayFormat), Reviews: 5}
+		c.JSON(http.StatusOK, goalsJSON(p, today, &models.Streak{Current: 3, Longest: 7}))
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+
+	now := time.Now()
+	streak, err := models.GetStreak(db, prefs, now)
+	if err != nil {
+		log.Println("Error fetching streak:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching streak"})
+		return
+	}
+	progress, err := models.GetDailyProgress(db, prefs.UserID, prefs.Location(), now, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, goalsJSON(prefs, *progress[now.In(prefs.Location()).Format(models.DayFormat)], streak))
+}
+
+// UpdateGoals 設定每日目標與時區
+//
+// 表單欄位：timezone（IANA 名稱，如 Asia/Taipei）、goal_new_words、goal_reviews、goal_minutes（0 表示不設定）
+func UpdateGoals(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	timezone := c.PostForm("timezone")
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
+			return
+		}
+	}
+	goals := map[string]*int{}
+	for _, field := range []string{"goal_new_words", "goal_reviews", "goal_minutes"} {
+		value := c.PostForm(field)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxDailyGoal {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Goals must be between 0 and " + strconv.Itoa(maxDailyGoal)})
+			return
+		}
+		goals[field] = &n
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	if timezone != "" {
+		prefs.Timezone = timezone
+	}
+	if n := goals["goal_new_words"]; n != nil {
+		prefs.GoalNewWords = *n
+	}
+	if n := goals["goal_reviews"]; n != nil {
+		prefs.GoalReviews = *n
+	}
+	if n := goals["goal_minutes"]; n != nil {
+		prefs.GoalMinutes = *n
+	}
+
+	if err := user.SavePreferences(db, prefs); err != nil {
+		log.Println("Error saving preferences:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "verified": prefs.ReminderVerified()})
+}
+
+// SendReminderCode 寄送驗證碼到目前的提醒目標，確認使用者能收到該目標的訊息
+func SendReminderCode(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	if prefs.ReminderTarget == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No reminder target is configured"})
+		return
+	}
+	if prefs.ReminderVerified() {
+		c.JSON(http.StatusOK, gin.H{"success": true, "verified": true})
+		return
+	}
+
+	code, err := auth.RandomToken(6)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating confirmation code"})
+		return
+	}
+	now := time.Now()
+	destination := models.ReminderDestination(prefs.ReminderChannel, prefs.ReminderTarget)
+	claimed, err := models.ClaimReminderConfirmation(db, user.ID, destination, auth.HashToken(code), now, now.Add(-reminderCodeCooldown))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating confirmation code"})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "A confirmation code was sent recently, please wait before requesting another"})
+		return
+	}
+
+	err = notify.Send(prefs.ReminderChannel, prefs.ReminderTarget, notify.Message{
+		Subject: "Confirm your Vocabulary reminders",
+		Text: fmt.Sprintf("Enter this code in Vocabulary within %d minutes to receive daily reminders here:\n\n%s\n\n"+
+			"If you did not ask for reminders, you can ignore this message.\n", int(reminderCodeTTL.Minutes()), code),
+		Data: gin.H{"confirmation_code": code},
+	})
+	if err != nil {
+		log.Println("Error sending reminder confirmation:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send confirmation code, please check the reminder target"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "verified": false})
+}
+
+// ConfirmReminderTarget 以驗證碼確認提醒目標
+//
+// 表單欄位：code
+func ConfirmReminderTarget(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	code := strings.TrimSpace(c.PostForm("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Confirmation code is required"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "verified": true})
+		return
+	}
+
+	uid := userID.(int64)
+	confirmed, err := models.ConfirmReminderTarget(db, uid, auth.HashToken(code), time.Now().Add(-reminderCodeTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error confirming reminder target"})
+		return
+	}
+	if !confirmed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation code"})
+		return
+	}
+
+	prefs, err := (&models.User{ID: uid}).GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "verified": prefs.ReminderVerified()})
+}
+
+// LogReading 記錄閱讀時間，由閱讀頁面定期回報
+//
+// 表單欄位：minutes（1–60）
+func LogReading(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	minutes, err := strconv.Atoi(c.PostForm("minutes"))
+	if err != nil || minutes < 1 || minutes > maxReadingReport {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minutes must be between 1 and " + strconv.Itoa(maxReadingReport)})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	// 每日總計不超過當地今天已經過的分鐘數
+	now := time.Now().In(prefs.Location())
+	elapsed := int(now.Sub(models.StartOfDay(now, prefs.Location())).Minutes()) + 1
+	if err := models.AddReadingMinutes(db, user.ID, now.Format(models.DayFormat), minutes, elapsed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving reading time"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetReminders 回傳提醒設定與可用的通知管道
+func GetReminders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, remindersJSON(&models.Preferences{
+			ReminderHour:    models.DefaultReminderHour,
+			ReminderChannel: "email",
+		}))
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, remindersJSON(prefs))
+}
+
+// UpdateReminders 設定每日提醒；新的目標需以 SendReminderCode 寄出的驗證碼確認後才會收到提醒
+//
+// 表單欄位：enabled（true/false）、hour（使用者時區的整點 0–23）、channel（email、webhook）、target（電子郵件或 URL）
+func UpdateReminders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	hour := -1
+	if hourStr := c.PostForm("hour"); hourStr != "" {
+		var err error
+		hour, err = strconv.Atoi(hourStr)
+		if err != nil || hour < 0 || hour > 23 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Hour must be between 0 and 23"})
+			return
+		}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	if enabled := c.PostForm("enabled"); enabled != "" {
+		prefs.ReminderEnabled = enabled == "true"
+	}
+	if hour >= 0 {
+		prefs.ReminderHour = hour
+	}
+	if channel := c.PostForm("channel"); channel != "" {
+		prefs.ReminderChannel = channel
+	}
+	if target, ok := c.GetPostForm("target"); ok {
+		prefs.ReminderTarget = target
+	}
+
+	if prefs.ReminderEnabled || prefs.ReminderTarget != "" {
+		notifier, err := notify.Get(prefs.ReminderChannel)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Notification channel is not available"})
+			return
+		}
+		if err := notifier.Validate(prefs.ReminderTarget); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder target for " + prefs.ReminderChannel})
+			return
+		}
+	}
+
+	if err := user.SavePreferences(db, prefs); err != nil {
+		log.Println("Error saving preferences:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SendTestReminder 立即寄出一封提醒摘要，用於確認設定；只寄往已確認的目標，且每位使用者有寄送間隔限制
+func SendTestReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user := &models.User{ID: userID.(int64)}
+	prefs, err := user.GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching preferences"})
+		return
+	}
+	if prefs.ReminderTarget == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No reminder target is configured"})
+		return
+	}
+	if !prefs.ReminderVerified() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Confirm the reminder target before sending a test reminder"})
+		return
+	}
+
+	now := time.Now()
+	claimed, err := models.ClaimTestReminder(db, user.ID, now, now.Add(-testReminderCooldown))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending reminder"})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "A test reminder was sent recently, please wait before sending another"})
+		return
+	}
+
+	digest, err := reminder.BuildDigest(db, prefs, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building reminder"})
+		return
+	}
+	if err := reminder.Send(prefs, digest); err != nil {
+		log.Println("Error sending test reminder:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send reminder, please check the reminder target"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func goalsJSON(p *models.Preferences, today models.DailyProgress, streak *models.Streak) gin.H {
+	return gin.H{
+		"timezone": p.Timezone,
+		"goals": gin.H{
+			"new_words": p.GoalNewWords,
+			"reviews":   p.GoalReviews,
+			"minutes":   p.GoalMinutes,
+		},
+		"today": gin.H{
+			"date":      today.Day,
+			"new_words": today.NewWords,
+			"reviews":   today.Reviews,
+			"minutes":   today.Minutes,
+		},
+		"goals_met": p.GoalsMet(today),
+		"streak": gin.H{
+			"current":  streak.Current,
+			"longest":  streak.Longest,
+			"last_day": streak.LastDay,
+		},
+	}
+}
+
+func remindersJSON(p *models.Preferences) gin.H {
+	return gin.H{
+		"enabled":          p.ReminderEnabled,
+		"hour":             p.ReminderHour,
+		"channel":          p.ReminderChannel,
+		"target":           p.ReminderTarget,
+		"verified":         p.ReminderVerified(),
+		"channels":         notify.Channels(),
+		"last_reminded_on": p.LastRemindedOn,
+	}
+}
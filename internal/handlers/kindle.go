@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"vocabulary/internal/kindle"
+	"vocabulary/internal/models"
+	"vocabulary/internal/wordlist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 單次從 Kindle 匯入的新單字上限，每個單字都需要查詢字典；超過的單字留待下次匯入
+const maxKindleWords = 500
+
+type kindleWord struct {
+	Word    string `json:"word"`
+	Form    string `json:"form"` // 書中查詢時的字形
+	Context string `json:"context"`
+	Book    string `json:"book"`
+	Action  string `json:"action"` // create, skip, remaining, error
+	lemmas  []string
+}
+
+type kindleWordError struct {
+	Word  string `json:"word"`
+	Error string `json:"error"`
+}
+
+// ImportKindle 從 Kindle 的 vocab.db 匯入查過的單字
+//
+// 表單欄位：
+//   - file: Kindle 的 vocab.db
+//   - lang: 只匯入此語言的查詢（預設 en）
+//   - dry_run: true 時只回傳預覽，不查詢字典也不寫入資料庫
+func ImportKindle(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	lang := c.DefaultPostForm("lang", "en")
+	dryRun := c.PostForm("dry_run") == "true"
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	lookups, err := kindle.Read(file)
+	if err != nil {
+		if errors.Is(err, kindle.ErrNotVocabularyBuilder) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File is not a Kindle vocab.db"})
+			return
+		}
+		log.Println("Error reading Kindle database:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	// 以原形合併同一單字的多次查詢，保留最近一次的句子與書名
+	var words []*kindleWord
+	index := make(map[string]*kindleWord)
+	for _, l := range lookups {
+		if lang != "" && !strings.HasPrefix(l.Language, lang) {
+			continue
+		}
+		stem := l.Stem
+		if stem == "" {
+			stem = l.Word
+		}
+		if wordPattern.FindString(stem) != stem {
+			continue
+		}
+
+		key := strings.ToLower(stem)
+		w, ok := index[key]
+		if !ok {
+			w = &kindleWord{Word: key}
+			index[key] = w
+			words = append(words, w)
+		}
+		w.Form = l.Word
+		w.Context = kindleContext(l)
+		w.Book = l.BookTitle
+		w.lemmas = append(w.lemmas, wordlist.Lemmas(l.Word)...)
+	}
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		for _, w := range words {
+			w.Action = "create"
+		}
+		c.JSON(http.StatusOK, kindleSummary(words, nil, dryRun))
+		return
+	}
+
+	known, err := models.GetWordSet(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing words"})
+		return
+	}
+
+	// 既有單字的所有原形，使 "running" 與 "run" 視為同一個字
+	knownLemmas := make(map[string]bool)
+	for word := range known {
+		for _, lemma := range wordlist.Lemmas(word) {
+			knownLemmas[lemma] = true
+		}
+	}
+
+	var pending []*kindleWord
+	for _, w := range words {
+		w.Action = "create"
+		if knownLemmas[w.Word] {
+			w.Action = "skip"
+			continue
+		}
+		for _, lemma := range w.lemmas {
+			if known[lemma] {
+				w.Action = "skip"
+				break
+			}
+		}
+		if w.Action == "create" {
+			pending = append(pending, w)
+		}
+	}
+
+	// 預覽回報全部的新單字；實際匯入只處理前 maxKindleWords 個，其餘再次匯入時會接續處理
+	if !dryRun && len(pending) > maxKindleWords {
+		for _, w := range pending[maxKindleWords:] {
+			w.Action = "remaining"
+		}
+		pending = pending[:maxKindleWords]
+	}
+
+	wordErrors := []kindleWordError{}
+	if !dryRun && len(pending) > 0 {
+		stems := make([]string, len(pending))
+		for i, w := range pending {
+			stems[i] = w.Word
+		}
+		results := lookupWords(stems, prelearnWorkers)
+
+		var vocabularies []models.Vocabulary
+		for i, w := range pending {
+			definitions, err := results[i].definitions, results[i].err
+			// 原形查不到時改用書中的字形
+			if err != nil && strings.ToLower(w.Form) != w.Word {
+				definitions, err = lookupDictionary(w.Form)
+			}
+			if err != nil {
+				w.Action = "error"
+				message := "Dictionary lookup failed"
+				if errors.Is(err, errWordNotFound) || errors.Is(err, errNoDefinitions) {
+					message = "No definitions found"
+				}
+				wordErrors = append(wordErrors, kindleWordError{Word: w.Word, Error: message})
+				continue
+			}
+			vocabularies = append(vocabularies, models.Vocabulary{
+				Word:        w.Word,
+				Context:     w.Context,
+				Definitions: toVocabularyDefinitions(definitions),
+			})
+		}
+
+		if err := models.CreateBatch(db, userID.(int64), vocabularies); err != nil {
+			log.Println("Error saving Kindle words:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving words"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, kindleSummary(words, wordErrors, dryRun))
+}
+
+// kindleContext 以查詢時的句子與書名作為單字的來源
+func kindleContext(l kindle.Lookup) string {
+	context := l.Usage
+	if l.BookTitle != "" {
+		if context != "" {
+			context += " — "
+		}
+		context += l.BookTitle
+	}
+	return context
+}
+
+func kindleSummary(words []*kindleWord, wordErrors []kindleWordError, dryRun bool) gin.H {
+	counts := map[string]int{"create": 0, "skip": 0, "remaining": 0}
+	for _, w := range words {
+		counts[w.Action]++
+	}
+
+	preview := words
+	if len(preview) > importPreviewSize {
+		preview = preview[:importPreviewSize]
+	}
+	if preview == nil {
+		preview = []*kindleWord{}
+	}
+	if wordErrors == nil {
+		wordErrors = []kindleWordError{}
+	}
+
+	return gin.H{
+		"success":   true,
+		"dry_run":   dryRun,
+		"created":   counts["create"],
+		"skipped":   counts["skip"],
+		"remaining": counts["remaining"],
+		"errors":    wordErrors,
+		"preview":   preview,
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"vocabulary/internal/ratelimit"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 同一 IP 可能有多位使用者（如學校網路），因此比單一帳號寬鬆
+var (
+	loginIPPolicy = ratelimit.Policy{
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    50,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+	loginAccountPolicy = ratelimit.Policy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+)
+
+var ipLimiter, accountLimiter ratelimit.Limiter
+
+// initLoginLimiters 預設使用記憶體計數；多台伺服器部署時設定 LOGIN_LIMITER=database 共用資料庫中的計數
+func initLoginLimiters() {
+	if os.Getenv("LOGIN_LIMITER") == "database" && db != nil {
+		ipLimiter = ratelimit.NewDatabase(db, "ip:", loginIPPolicy)
+		accountLimiter = ratelimit.NewDatabase(db, "user:", loginAccountPolicy)
+		return
+	}
+	ipLimiter = ratelimit.NewMemory(loginIPPolicy)
+	accountLimiter = ratelimit.NewMemory(loginAccountPolicy)
+}
+
+// loginAccountKey 以雜湊後的使用者名稱作為帳號限制器的鍵，長度固定且不保存原始輸入
+func loginAccountKey(username string) string {
+	return ratelimit.HashKey(strings.ToLower(strings.TrimSpace(username)))
+}
+
+// loginAttempt 是一次登入嘗試在 IP 與帳號限制器上的計數結果
+type loginAttempt struct {
+	ipKey, accountKey string
+	counted           []loginCount
+}
+
+type loginCount struct {
+	limiter ratelimit.Limiter
+	key     string
+	status  ratelimit.Status
+}
+
+// startLoginAttempt 先檢查 IP 再檢查帳號；允許嘗試的同時即記為一次失敗，避免同時送出的猜測都通過檢查。
+// 需要等待時回傳等待時間，驗證成功後以 succeeded 撤回計數
+func startLoginAttempt(ipKey, accountKey string, now time.Time) (*loginAttempt, time.Duration) {
+	attempt := &loginAttempt{ipKey: ipKey, accountKey: accountKey}
+	for _, check := range []struct {
+		limiter ratelimit.Limiter
+		key     string
+	}{{ipLimiter, ipKey}, {accountLimiter, accountKey}} {
+		wait, status, err := check.limiter.Attempt(check.key, now)
+		if err != nil {
+			log.Println("Error checking login limiter:", err)
+			continue
+		}
+		if wait > 0 {
+			return nil, wait
+		}
+		attempt.counted = append(attempt.counted, loginCount{check.limiter, check.key, status})
+	}
+	return attempt, 0
+}
+
+// wait 回傳這次失敗後需要等待的時間
+func (a *loginAttempt) wait(now time.Time) time.Duration {
+	var wait time.Duration
+	for _, count := range a.counted {
+		if d := count.status.BlockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// succeeded 撤回這次嘗試的計數，並清除帳號累積的失敗次數
+func (a *loginAttempt) succeeded() {
+	if err := ipLimiter.Succeed(a.ipKey); err != nil {
+		log.Println("Error updating login limiter:", err)
+	}
+	if err := accountLimiter.Reset(a.accountKey); err != nil {
+		log.Println("Error resetting login limiter:", err)
+	}
+}
+
+func lockoutThreshold(l ratelimit.Limiter) int {
+	if l == ipLimiter {
+		return loginIPPolicy.LockoutAfter
+	}
+	return loginAccountPolicy.LockoutAfter
+}
+
+// lockoutSource 說明鎖定來自哪個限制器；帳號的鍵為雜湊值，不寫入稽核紀錄
+func lockoutSource(l ratelimit.Limiter) string {
+	if l == ipLimiter {
+		return "ip"
+	}
+	return "account"
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash 用於帳號不存在時的假比對
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"fmt"
+	"html"
 	"io"
+	"log"
 	"net/http"
+	"os"
 	"strings"
+	"vocabulary/internal/models"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gin-gonic/gin"
@@ -23,50 +28,93 @@ func FetchNews(c *gin.Context) {
 		return
 	}
 
-	// 發送HTTP請求獲取新聞內容
-	resp, err := http.Get(url)
+	title, paragraphs, err := fetchArticle(url)
 	if err != nil {
+		log.Println("Error fetching news:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch news"})
 		return
 	}
+	content := renderParagraphs(paragraphs)
+
+	// 將文章存入使用者的文章庫
+	if os.Getenv("SKIP_DB") != "true" {
+		userID, _ := c.Get("user_id")
+		articleID, err := models.CreateArticle(db, userID.(int64), title, url, content)
+		if err != nil {
+			log.Println("Error saving article:", err)
+		} else {
+			c.Header("X-Article-ID", fmt.Sprint(articleID))
+		}
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.String(http.StatusOK, content)
+}
+
+// fetchArticle 下載網頁並擷取標題與段落
+func fetchArticle(url string) (string, []string, error) {
+	// 發送HTTP請求獲取新聞內容
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", nil, err
+	}
 	defer resp.Body.Close()
 
 	// 讀取響應內容
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response"})
-		return
+		return "", nil, err
 	}
 
 	// 使用goquery解析HTML
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse HTML"})
-		return
+		return "", nil, err
 	}
+
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	return title, extractParagraphs(doc), nil
+}
+
+// extractParagraphs 從HTML文件中擷取正文段落
+func extractParagraphs(doc *goquery.Document) []string {
 	doc.Find("header, footer, nav, aside, script, style, .ad, .comments, .related-articles").Remove()
 	// 提取文章內容（這裡的選擇器需要根據目標網站調整）
-	var content string
+	var paragraphs []string
 	doc.Find("p").Each(func(i int, s *goquery.Selection) {
 		text := strings.TrimSpace(s.Text())
 		if text != "" {
-			content += "<p>" + text + "</p>"
+			paragraphs = append(paragraphs, text)
 		}
 	})
 
 	// If no paragraphs found, try getting the body content
-	if content == "" {
-		content = doc.Find("body").Text()
+	if len(paragraphs) == 0 {
 		// Split content by newlines and create paragraphs
-		paragraphs := strings.Split(content, "\n")
-		content = ""
-		for _, p := range paragraphs {
+		for _, p := range strings.Split(doc.Find("body").Text(), "\n") {
 			if text := strings.TrimSpace(p); text != "" {
-				content += "<p>" + text + "</p>"
+				paragraphs = append(paragraphs, text)
 			}
 		}
 	}
 
-	c.Header("Content-Type", "text/html")
-	c.String(http.StatusOK, content)
+	return paragraphs
+}
+
+// renderParagraphs 將段落轉換為閱讀器使用的HTML
+func renderParagraphs(paragraphs []string) string {
+	var content strings.Builder
+	for _, p := range paragraphs {
+		content.WriteString("<p>" + html.EscapeString(p) + "</p>")
+	}
+	return content.String()
+}
+
+// parseParagraphs 從已儲存的文章內容還原段落
+func parseParagraphs(content string) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil
+	}
+	return extractParagraphs(doc)
 }
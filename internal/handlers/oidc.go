@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+	"vocabulary/internal/oidc"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 設定 OIDC_ISSUER 後才會啟用外部登入
+var oidcProvider *oidc.Provider
+
+// 登入流程中暫存在 session cookie 的欄位
+const (
+	oidcStateKey    = "oidc_state"
+	oidcNonceKey    = "oidc_nonce"
+	oidcVerifierKey = "oidc_verifier"
+	oidcLinkKey     = "oidc_link_user"
+	oidcReauthKey   = "oidc_reauth_user"
+	// 使用者需在這段時間內完成身分提供者的登入
+	oidcFlowTTL = 10 * time.Minute
+)
+
+// OIDCLogin 導向身分提供者登入（authorization code flow + PKCE）
+func OIDCLogin(c *gin.Context) {
+	startOIDCFlow(c, 0, 0)
+}
+
+// ShowAccount 顯示帳號頁面，列出連結的外部帳號
+func ShowAccount(c *gin.Context) {
+	data := gin.H{
+		"title":           "Account",
+		"IsAuthenticated": true,
+	}
+	if oidcProvider != nil {
+		data["oidcName"] = oidcProvider.DisplayName
+	}
+	c.HTML(http.StatusOK, "account.html", data)
+}
+
+// LinkIdentity 將目前的帳號與身分提供者上的帳號連結；以 POST 表單送出，受 CSRF 保護，
+// 避免其他網站誘使使用者把攻擊者的外部帳號連結到自己的帳號
+func LinkIdentity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	startOIDCFlow(c, userID.(int64), 0)
+}
+
+// ReauthenticateOIDC 在身分提供者重新登入以確認是本人，之後短時間內可不輸入密碼變更帳號的安全設定；
+// 沒有密碼的帳號只能以此方式確認身分
+func ReauthenticateOIDC(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	startOIDCFlow(c, 0, userID.(int64))
+}
+
+// startOIDCFlow 開始登入流程；linkUserID 或 reauthUserID 不為 0 時分別為連結帳號或重新驗證該使用者
+func startOIDCFlow(c *gin.Context, linkUserID, reauthUserID int64) {
+	if oidcProvider == nil {
+		c.HTML(http.StatusNotFound, "login.html", gin.H{
+			"title": "Login",
+			"error": oidc.ErrNotConfigured.Error(),
+		})
+		return
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error starting login")
+		return
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error starting login")
+		return
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error starting login")
+		return
+	}
+
+	authURL, err := oidcProvider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Println("Error contacting identity provider:", err)
+		oidcError(c, http.StatusBadGateway, "Could not reach the identity provider, please try again later")
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(oidcStateKey, state)
+	session.Set(oidcNonceKey, nonce)
+	session.Set(oidcVerifierKey, verifier)
+	session.Set(oidcLinkKey, linkUserID)
+	session.Set(oidcReauthKey, reauthUserID)
+	session.Options(auth.SessionOptions(int(oidcFlowTTL.Seconds())))
+	if err := session.Save(); err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error starting login")
+		return
+	}
+
+	// 連結由 POST 表單發起，以 303 讓瀏覽器改用 GET 前往身分提供者
+	c.Redirect(http.StatusSeeOther, authURL)
+}
+
+// OIDCCallback 處理身分提供者導回的授權碼：驗證 state 與 ID token 後登入、自動建立帳號或完成連結
+func OIDCCallback(c *gin.Context) {
+	if oidcProvider == nil {
+		oidcError(c, http.StatusNotFound, oidc.ErrNotConfigured.Error())
+		return
+	}
+
+	// 流程資料只能使用一次
+	session := sessions.Default(c)
+	state, _ := session.Get(oidcStateKey).(string)
+	nonce, _ := session.Get(oidcNonceKey).(string)
+	verifier, _ := session.Get(oidcVerifierKey).(string)
+	linkUserID, _ := session.Get(oidcLinkKey).(int64)
+	reauthUserID, _ := session.Get(oidcReauthKey).(int64)
+	for _, key := range []string{oidcStateKey, oidcNonceKey, oidcVerifierKey, oidcLinkKey, oidcReauthKey} {
+		session.Delete(key)
+	}
+	session.Save()
+
+	if errCode := c.Query("error"); errCode != "" {
+		log.Println("Identity provider returned an error:", errCode, c.Query("error_description"))
+		oidcError(c, http.StatusUnauthorized, "Sign-in was cancelled or denied by the identity provider")
+		return
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		oidcError(c, http.StatusBadRequest, "Your sign-in attempt has expired. Please try again.")
+		return
+	}
+
+	claims, err := oidcProvider.Exchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Println("Error completing OpenID Connect login:", err)
+		oidcError(c, http.StatusUnauthorized, "Could not verify your sign-in with the identity provider")
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		tokenString, err := auth.IssueAccessToken(1, 0, time.Hour*24)
+		if err != nil {
+			oidcError(c, http.StatusInternalServerError, "Error generating token")
+			return
+		}
+		auth.SetCookie(c, auth.AccessCookie, tokenString, 3600*24, "/", true)
+		c.Redirect(http.StatusFound, "/news")
+		return
+	}
+
+	identity, err := models.GetIdentity(db, oidcProvider.Issuer, claims.Subject)
+	if err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error checking linked accounts")
+		return
+	}
+	email := ""
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+
+	if linkUserID != 0 {
+		linkOIDCIdentity(c, linkUserID, identity, claims.Subject, email)
+		return
+	}
+	if reauthUserID != 0 {
+		reauthenticateOIDCIdentity(c, reauthUserID, identity, email)
+		return
+	}
+
+	var user *models.User
+	if identity != nil {
+		if err := models.TouchIdentity(db, identity.ID, email); err != nil {
+			log.Println("Error updating identity:", err)
+		}
+		user, err = models.GetUserByID(db, identity.UserID)
+		if err != nil || user == nil {
+			oidcError(c, http.StatusInternalServerError, "Error fetching user")
+			return
+		}
+	} else {
+		// 第一次登入時自動建立帳號；無法以密碼登入，除非之後透過電子郵件重設密碼
+		user, err = provisionOIDCUser(claims, email)
+		if err != nil {
+			log.Println("Error provisioning user:", err)
+			oidcError(c, http.StatusInternalServerError, "Error creating account")
+			return
+		}
+		recordAuthEvent(c, user.ID, user.Username, models.EventUserProvisioned, oidcProvider.Issuer)
+	}
+
+	// 剛在身分提供者登入，視同已重新驗證身分
+	now := time.Now()
+	if err := markReauthenticated(c, user.ID, now); err != nil {
+		log.Println("Error saving session:", err)
+	}
+
+	if startSecondFactor(c, user, now) {
+		return
+	}
+	completeLogin(c, user, nil, "oidc")
+}
+
+func reauthenticateOIDCIdentity(c *gin.Context, userID int64, identity *models.Identity, email string) {
+	if identity == nil || identity.UserID != userID {
+		oidcError(c, http.StatusForbidden, "Please sign in with the account at the identity provider that is linked to this account")
+		return
+	}
+	if err := models.TouchIdentity(db, identity.ID, email); err != nil {
+		log.Println("Error updating identity:", err)
+	}
+	if err := markReauthenticated(c, userID, time.Now()); err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error saving session")
+		return
+	}
+	recordAuthEvent(c, userID, "", models.EventReauthenticated, oidcProvider.Issuer)
+	c.Redirect(http.StatusFound, "/account")
+}
+
+func linkOIDCIdentity(c *gin.Context, userID int64, identity *models.Identity, subject, email string) {
+	if identity != nil {
+		if identity.UserID != userID {
+			oidcError(c, http.StatusConflict, "This account at the identity provider is already linked to another user")
+			return
+		}
+		c.Redirect(http.StatusFound, "/account")
+		return
+	}
+	if err := models.LinkIdentity(db, userID, oidcProvider.Issuer, subject, email); err != nil {
+		oidcError(c, http.StatusInternalServerError, "Error linking account")
+		return
+	}
+	recordAuthEvent(c, userID, "", models.EventIdentityLinked, oidcProvider.Issuer)
+	c.Redirect(http.StatusFound, "/account")
+}
+
+func provisionOIDCUser(claims *oidc.Claims, email string) (*models.User, error) {
+	username := claims.PreferredUsername
+	if username == "" && email != "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		username = "user"
+	}
+
+	// 隨機密碼只是佔位，沒有人知道明文
+	placeholder, err := auth.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(placeholder), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return models.ProvisionUser(db, username, string(hash), email, oidcProvider.Issuer, claims.Subject)
+}
+
+func oidcError(c *gin.Context, status int, message string) {
+	data := gin.H{
+		"title": "Login",
+		"error": message,
+	}
+	if oidcProvider != nil {
+		data["oidcName"] = oidcProvider.DisplayName
+	}
+	c.HTML(status, "login.html", data)
+}
+
+// ListIdentities 列出與帳號連結的外部身分
+func ListIdentities(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"identities": []gin.H{}})
+		return
+	}
+
+	identities, err := models.GetIdentities(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching linked accounts"})
+		return
+	}
+	result := make([]gin.H, 0, len(identities))
+	for _, i := range identities {
+		result = append(result, gin.H{
+			"id":            i.ID,
+			"issuer":        i.Issuer,
+			"email":         i.Email,
+			"created_at":    i.CreatedAt,
+			"last_login_at": i.LastLoginAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"identities": result})
+}
+
+// UnlinkIdentity 解除外部身分的連結
+func UnlinkIdentity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err = models.UnlinkIdentity(db, userID.(int64), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Linked account not found"})
+		return
+	}
+	if errors.Is(err, models.ErrLastIdentity) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Set a password before unlinking the only account you sign in with"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error unlinking account"})
+		return
+	}
+	recordAuthEvent(c, userID.(int64), "", models.EventIdentityUnlinked, strconv.FormatInt(id, 10))
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
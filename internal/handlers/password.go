@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+	"vocabulary/internal/notify"
+	"vocabulary/internal/password"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 同一帳號兩次寄送重設信之間至少間隔的時間
+const passwordResetCooldown = 5 * time.Minute
+
+// 不論帳號是否存在都回傳相同訊息，避免被用來探測電子郵件
+const forgotPasswordMessage = "If an account with that email exists, we have sent a link to reset its password."
+
+// ShowForgotPassword 顯示忘記密碼頁面
+func ShowForgotPassword(c *gin.Context) {
+	c.HTML(http.StatusOK, "forgot_password.html", gin.H{
+		"title": "Forgot Password",
+	})
+}
+
+// ForgotPassword 寄出重設密碼連結；連結中的 token 有簽章且只能使用一次
+func ForgotPassword(c *gin.Context) {
+	email := strings.TrimSpace(c.PostForm("email"))
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "forgot_password.html", gin.H{
+			"title": "Forgot Password",
+			"error": "Please enter a valid email address",
+			"email": email,
+		})
+		return
+	}
+	// 只以地址本身查詢，"Name <a@b.c>" 與 "a@b.c" 視為相同
+	email = addr.Address
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") != "true" {
+		if err := sendPasswordReset(c, email); err != nil {
+			log.Println("Error sending password reset:", err)
+		}
+	}
+
+	c.HTML(http.StatusOK, "forgot_password.html", gin.H{
+		"title":   "Forgot Password",
+		"message": forgotPasswordMessage,
+	})
+}
+
+func sendPasswordReset(c *gin.Context, email string) error {
+	user, err := models.GetUserByEmail(db, email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	now := time.Now()
+	recent, err := models.HasRecentPasswordReset(db, user.ID, now.Add(-passwordResetCooldown))
+	if err != nil || recent {
+		return err
+	}
+
+	link, err := issuePasswordReset(user, now)
+	if err != nil {
+		return err
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventPasswordResetRequested, "")
+
+	err = mailPasswordReset(user, link)
+	if errors.Is(err, notify.ErrUnknownChannel) {
+		log.Println("Warning: email is not configured, cannot send password reset to user", user.ID)
+		return nil
+	}
+	return err
+}
+
+// issuePasswordReset 簽發重設密碼 token 並回傳重設連結
+func issuePasswordReset(user *models.User, now time.Time) (string, error) {
+	token, reset, err := auth.IssueResetToken(user.ID, now)
+	if err != nil {
+		return "", err
+	}
+	if err := models.CreatePasswordReset(db, user.ID, auth.HashToken(reset.Nonce), reset.ExpiresAt); err != nil {
+		return "", err
+	}
+	return appBaseURL() + "/password/reset?token=" + url.QueryEscape(token), nil
+}
+
+func mailPasswordReset(user *models.User, link string) error {
+	return notify.Send("email", user.Email, notify.Message{
+		Subject: "Reset your Vocabulary password",
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your Vocabulary account. "+
+			"Open the link below within %d minutes to choose a new password:\n\n%s\n\n"+
+			"If this wasn't you, you can ignore this email; your password has not been changed.\n",
+			user.Username, int(auth.PasswordResetTTL.Minutes()), link),
+	})
+}
+
+// appBaseURL 回傳信件中連結使用的網址，可用 APP_BASE_URL 設定
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
+
+// ShowResetPassword 顯示設定新密碼頁面
+func ShowResetPassword(c *gin.Context) {
+	token := c.Query("token")
+	data := gin.H{
+		"title": "Reset Password",
+		"token": token,
+	}
+	if _, err := auth.ParseResetToken(token, time.Now()); err != nil {
+		data["error"] = "This password reset link is invalid or has expired."
+		data["expired"] = true
+	}
+	c.HTML(http.StatusOK, "reset_password.html", data)
+}
+
+// ResetPassword 以重設連結設定新密碼，並登出所有裝置
+func ResetPassword(c *gin.Context) {
+	token := c.PostForm("token")
+	newPassword := c.PostForm("password")
+	fail := func(status int, message string, expired bool) {
+		c.HTML(status, "reset_password.html", gin.H{
+			"title":   "Reset Password",
+			"token":   token,
+			"error":   message,
+			"expired": expired,
+		})
+	}
+
+	reset, err := auth.ParseResetToken(token, time.Now())
+	if err != nil {
+		fail(http.StatusBadRequest, "This password reset link is invalid or has expired.", true)
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		if err := password.Validate(newPassword, ""); err != nil {
+			fail(http.StatusBadRequest, err.Error(), false)
+			return
+		}
+		c.HTML(http.StatusOK, "login.html", gin.H{
+			"title":   "Login",
+			"message": "Your password has been reset. Please log in with your new password.",
+		})
+		return
+	}
+
+	user, err := models.GetUserByID(db, reset.UserID)
+	if err != nil {
+		fail(http.StatusInternalServerError, "Error resetting password", false)
+		return
+	}
+	if user == nil {
+		fail(http.StatusBadRequest, "This password reset link is invalid or has expired.", true)
+		return
+	}
+	if err := password.Validate(newPassword, user.Username); err != nil {
+		fail(http.StatusBadRequest, err.Error(), false)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		fail(http.StatusInternalServerError, "Error resetting password", false)
+		return
+	}
+	err = models.ResetPassword(db, user.ID, auth.HashToken(reset.Nonce), string(hashedPassword))
+	if errors.Is(err, models.ErrResetUsed) {
+		fail(http.StatusBadRequest, "This password reset link has already been used.", true)
+		return
+	}
+	if err != nil {
+		log.Println("Error resetting password:", err)
+		fail(http.StatusInternalServerError, "Error resetting password", false)
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventPasswordReset, "")
+
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"title":    "Login",
+		"message":  "Your password has been reset. Please log in with your new password.",
+		"username": user.Username,
+	})
+}
+
+// ChangePassword 變更密碼，需要提供目前的密碼或剛重新驗證身分；沒有密碼的帳號以此設定密碼。其他裝置會被登出
+func ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	currentPassword := c.PostForm("current_password")
+	newPassword := c.PostForm("new_password")
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		if err := password.Validate(newPassword, os.Getenv("TEST_USER")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user, ok := reauthenticate(c, userID.(int64), currentPassword)
+	if !ok {
+		return
+	}
+	if err := password.Validate(newPassword, user.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if newPassword == currentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current one"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error changing password"})
+		return
+	}
+	if err := user.ChangePassword(db, string(hashedPassword), c.GetInt64("session_id")); err != nil {
+		log.Println("Error changing password:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error changing password"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventPasswordChanged, "")
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// UpdateEmail 設定或移除用於重設密碼的電子郵件，需要提供目前的密碼或剛重新驗證身分
+func UpdateEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	email := strings.TrimSpace(c.PostForm("email"))
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+			return
+		}
+		// 只儲存地址本身，寄信時 SMTP 通知只接受不含名稱的地址
+		email = addr.Address
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "email": email})
+		return
+	}
+
+	user, ok := reauthenticate(c, userID.(int64), c.PostForm("current_password"))
+	if !ok {
+		return
+	}
+	if email != "" {
+		existing, err := models.GetUserByEmail(db, email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating email"})
+			return
+		}
+		if existing != nil && existing.ID != user.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already used by another account"})
+			return
+		}
+	}
+	if err := user.SetEmail(db, email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "email": email})
+}
+
+// 在身分提供者重新登入後，這段時間內不需輸入密碼即可變更帳號的安全設定
+const reauthTTL = 10 * time.Minute
+
+// 重新驗證的使用者與時間，存放在 session cookie
+const (
+	reauthUserKey = "reauth_user"
+	reauthAtKey   = "reauth_at"
+)
+
+// reauthenticate 取得使用者並確認是本人：在 reauthTTL 內於身分提供者重新登入過即可，否則需要目前的密碼；
+// 沒有密碼的帳號（由外部登入自動建立）只能以 /account/reauthenticate 重新登入。失敗時已寫入回應
+func reauthenticate(c *gin.Context, userID int64, current string) (*models.User, bool) {
+	user, err := models.GetUserByID(db, userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+		return nil, false
+	}
+	if recentlyReauthenticated(c, user.ID, time.Now()) {
+		return user, true
+	}
+	if !user.PasswordSet {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":          "Please confirm it's you by signing in again with your identity provider",
+			"reauthenticate": "/account/reauthenticate",
+		})
+		return nil, false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		return nil, false
+	}
+	return user, true
+}
+
+// markReauthenticated 記錄使用者剛在身分提供者重新登入
+func markReauthenticated(c *gin.Context, userID int64, now time.Time) error {
+	session := sessions.Default(c)
+	session.Set(reauthUserKey, userID)
+	session.Set(reauthAtKey, now.Unix())
+	return session.Save()
+}
+
+func recentlyReauthenticated(c *gin.Context, userID int64, now time.Time) bool {
+	session := sessions.Default(c)
+	reauthUser, _ := session.Get(reauthUserKey).(int64)
+	reauthAt, _ := session.Get(reauthAtKey).(int64)
+	return reauthUser == userID && now.Sub(time.Unix(reauthAt, 0)) < reauthTTL
+}
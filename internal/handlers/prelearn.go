@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 單次預習最多回傳的候選單字數量
+	maxPrelearnCandidates = 50
+	// 同時查詢字典的 worker 數量
+	prelearnWorkers = 5
+	// 批次保存最多接受的單字數量
+	maxBulkSaveWords = 200
+)
+
+var (
+	wordPattern     = regexp.MustCompile(`[A-Za-z]+(?:['-][A-Za-z]+)*`)
+	sentencePattern = regexp.MustCompile(`[^.!?]+[.!?]*`)
+)
+
+// 常見功能詞，不列入候選單字
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "any": true, "can": true, "had": true, "her": true,
+	"was": true, "one": true, "our": true, "out": true, "has": true, "his": true,
+	"him": true, "how": true, "its": true, "may": true, "new": true, "now": true,
+	"old": true, "see": true, "two": true, "way": true, "who": true, "did": true,
+	"get": true, "let": true, "say": true, "she": true, "too": true, "use": true,
+	"that": true, "with": true, "have": true, "this": true, "will": true, "your": true,
+	"from": true, "they": true, "been": true, "were": true, "said": true, "each": true,
+	"which": true, "their": true, "there": true, "what": true, "about": true, "would": true,
+	"these": true, "other": true, "into": true, "more": true, "some": true, "could": true,
+	"them": true, "than": true, "then": true, "when": true, "where": true, "while": true,
+	"also": true, "after": true, "before": true, "over": true, "only": true, "just": true,
+	"very": true, "most": true, "such": true, "should": true, "those": true, "being": true,
+	"does": true, "because": true, "through": true, "between": true, "under": true, "again": true,
+	"here": true, "like": true, "many": true, "much": true, "even": true, "well": true,
+}
+
+type wordCandidate struct {
+	Word        string              `json:"word"`
+	Count       int                 `json:"count"`
+	Context     string              `json:"context"`
+	Definitions []map[string]string `json:"definitions,omitempty"`
+}
+
+type lookupResult struct {
+	definitions []map[string]string
+	err         error
+}
+
+// PrelearnArticle 從文章中擷取使用者尚未收藏的單字並查詢定義，回傳待確認的清單
+func PrelearnArticle(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	articleURL := c.PostForm("url")
+	articleIDStr := c.PostForm("article_id")
+	if articleURL == "" && articleIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL or article ID is required"})
+		return
+	}
+
+	limit := maxPrelearnCandidates
+	if limitStr := c.PostForm("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"candidates": []wordCandidate{
+				{
+					Word:    "example",
+					Count:   1,
+					Context: "This is an example of a test word.",
+					Definitions: []map[string]string{
+						{
+							"partOfSpeech": "noun",
+							"definition":   "a representative form or pattern",
+						},
+					},
+				},
+			},
+			"not_found": []string{},
+		})
+		return
+	}
+
+	// 取得文章段落
+	var paragraphs []string
+	if articleIDStr != "" {
+		articleID, err := strconv.ParseInt(articleIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID format"})
+			return
+		}
+		article, err := models.GetArticleByID(db, userID.(int64), articleID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching article"})
+			return
+		}
+		if article == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+			return
+		}
+		paragraphs = articleParagraphs(article)
+	} else {
+		var err error
+		_, paragraphs, err = fetchArticle(articleURL)
+		if err != nil {
+			log.Println("Error fetching article:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch article"})
+			return
+		}
+	}
+
+	known, err := models.GetWordSet(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+		return
+	}
+
+	candidates := extractCandidates(paragraphs, known, limit)
+
+	// 以有限的 worker 同時查詢字典
+	words := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		words[i] = candidate.Word
+	}
+	results := lookupWords(words, prelearnWorkers)
+
+	found := []wordCandidate{}
+	notFound := []string{}
+	for i, candidate := range candidates {
+		if results[i].err != nil {
+			if !errors.Is(results[i].err, errWordNotFound) && !errors.Is(results[i].err, errNoDefinitions) {
+				log.Println("Error looking up word:", candidate.Word, results[i].err)
+			}
+			notFound = append(notFound, candidate.Word)
+			continue
+		}
+		candidate.Definitions = results[i].definitions
+		found = append(found, candidate)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"candidates": found,
+		"not_found":  notFound,
+	})
+}
+
+// BulkSaveWords 在同一個交易中保存使用者確認過的多個單字
+func BulkSaveWords(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var data struct {
+		ArticleID int64 `json:"article_id"`
+		Words     []struct {
+			Word        string              `json:"word"`
+			Context     string              `json:"context"`
+			Definitions []map[string]string `json:"definitions"`
+		} `json:"words"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if len(data.Words) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Words are required"})
+		return
+	}
+	if len(data.Words) > maxBulkSaveWords {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many words"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Words saved successfully (test mode)",
+			"saved":   len(data.Words),
+			"skipped": []string{},
+		})
+		return
+	}
+
+	if data.ArticleID != 0 && !checkArticleOwner(c, userID.(int64), data.ArticleID) {
+		return
+	}
+
+	known, err := models.GetWordSet(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing words"})
+		return
+	}
+
+	// 略過已存在的單字
+	var vocabularies []models.Vocabulary
+	skipped := []string{}
+	for _, w := range data.Words {
+		word := strings.TrimSpace(w.Word)
+		if word == "" || len(w.Definitions) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Word and definitions are required"})
+			return
+		}
+		if known[strings.ToLower(word)] {
+			skipped = append(skipped, word)
+			continue
+		}
+		known[strings.ToLower(word)] = true
+		vocabularies = append(vocabularies, models.Vocabulary{
+			Word:        word,
+			ArticleID:   data.ArticleID,
+			Context:     strings.TrimSpace(w.Context),
+			Definitions: toVocabularyDefinitions(w.Definitions),
+		})
+	}
+
+	if err := models.CreateBatch(db, userID.(int64), vocabularies); err != nil {
+		log.Println("Error saving words:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving words"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Words saved successfully",
+		"saved":   len(vocabularies),
+		"skipped": skipped,
+	})
+}
+
+// extractCandidates 依出現次數排序，回傳不在 known 中的候選單字及其所在句子
+func extractCandidates(paragraphs []string, known map[string]bool, limit int) []wordCandidate {
+	index := make(map[string]int)
+	var candidates []wordCandidate
+
+	for _, paragraph := range paragraphs {
+		for _, sentence := range sentencePattern.FindAllString(paragraph, -1) {
+			sentence = strings.TrimSpace(sentence)
+			for _, token := range wordPattern.FindAllString(sentence, -1) {
+				word := strings.ToLower(token)
+				if len(word) < 3 || stopWords[word] || known[word] {
+					continue
+				}
+				if i, ok := index[word]; ok {
+					candidates[i].Count++
+					continue
+				}
+				index[word] = len(candidates)
+				candidates = append(candidates, wordCandidate{
+					Word:    word,
+					Count:   1,
+					Context: sentence,
+				})
+			}
+		}
+	}
+
+	// 出現次數多的優先，次數相同則保留文章中的順序
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Count > candidates[j].Count
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// lookupWords 以固定數量的 worker 同時查詢字典，結果順序與 words 相同
+func lookupWords(words []string, workers int) []lookupResult {
+	results := make([]lookupResult, len(words))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				definitions, err := lookupDictionary(words[i])
+				results[i] = lookupResult{definitions: definitions, err: err}
+			}
+		}()
+	}
+
+	for i := range words {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
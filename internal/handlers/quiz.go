@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"vocabulary/internal/models"
+	"vocabulary/internal/wordlist"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 每次測驗預設與最多的題數
+	defaultQuizSize = 20
+	maxQuizSize     = 100
+	// 選擇題的選項數量
+	quizChoices = 4
+	// 填空題中取代單字的空格
+	clozeBlank = "_____"
+)
+
+var quizModes = map[string]bool{"choice": true, "typing": true, "cloze": true}
+
+type quizQuestion struct {
+	ID           int64    `json:"id"` // 作答時送回，答案依發出題目時的單字與模式批改
+	WordID       int64    `json:"word_id"`
+	Mode         string   `json:"mode"`
+	Prompt       string   `json:"prompt"`
+	PartOfSpeech string   `json:"part_of_speech"`
+	Definition   string   `json:"definition,omitempty"` // 填空題的提示
+	Options      []string `json:"options,omitempty"`
+	Hint         string   `json:"hint,omitempty"`
+}
+
+// StartQuiz 產生指定模式的測驗題目，答案不會回傳給前端
+//
+// mode=choice 依定義選出單字，干擾選項取自使用者其他相同詞性的單字
+// mode=typing 依定義拼出單字，允許少量拼字錯誤
+// mode=cloze  以例句挖空，填入句中的單字
+func StartQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	mode := c.Query("mode")
+	if !quizModes[mode] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be choice, typing or cloze"})
+		return
+	}
+
+	count := defaultQuizSize
+	if countStr := c.Query("count"); countStr != "" {
+		n, err := strconv.Atoi(countStr)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count"})
+			return
+		}
+		if n < maxQuizSize {
+			count = n
+		} else {
+			count = maxQuizSize
+		}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		vocabularies := []models.Vocabulary{
+			{ID: 1, Word: "example", Definitions: []models.VocabularyDefinition{
+				{PartOfSpeech: "noun", Definition: "a representative form or pattern", Example: "This is an example of a test word."},
+			}},
+			{ID: 2, Word: "sample", Definitions: []models.VocabularyDefinition{
+				{PartOfSpeech: "noun", Definition: "a small part intended to show what the whole is like"},
+			}},
+		}
+		questions := buildQuiz(mode, vocabularies, vocabularies, count)
+		for i := range questions {
+			questions[i].ID = int64(i + 1)
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "mode": mode, "questions": questions})
+		return
+	}
+
+	all, err := models.GetByUserID(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+		return
+	}
+
+	var deckID int64
+	if deckIDStr := c.Query("deck_id"); deckIDStr != "" {
+		deckID, err = strconv.ParseInt(deckIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID format"})
+			return
+		}
+	}
+	scoped, err := filterByScope(all, userID.(int64), deckID, c.Query("tag"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+		return
+	}
+
+	questions := buildQuiz(mode, scoped, all, count)
+	if len(questions) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"error":   "No words can be quizzed in this mode",
+		})
+		return
+	}
+
+	wordIDs := make([]int64, len(questions))
+	for i, q := range questions {
+		wordIDs[i] = q.WordID
+	}
+	ids, err := models.CreateQuizQuestions(db, userID.(int64), mode, wordIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating quiz"})
+		return
+	}
+	for i := range questions {
+		questions[i].ID = ids[i]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "mode": mode, "questions": questions})
+}
+
+// AnswerQuiz 在伺服器端批改答案並記錄結果；每題只能作答一次，作答後才回傳正確答案
+func AnswerQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	questionID, err := strconv.ParseInt(c.PostForm("question_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID format"})
+		return
+	}
+	answer := c.PostForm("answer")
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		v := models.Vocabulary{ID: 1, Word: "example", Definitions: []models.VocabularyDefinition{
+			{Example: "This is an example of a test word."},
+		}}
+		c.JSON(http.StatusOK, gin.H{"success": true, "correct": gradeAnswer("typing", v, answer), "expected": v.Word})
+		return
+	}
+
+	question, err := models.GetQuizQuestion(db, userID.(int64), questionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching question"})
+		return
+	}
+	if question == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found or expired"})
+		return
+	}
+
+	vocabulary := &models.Vocabulary{ID: question.VocabularyID}
+	if err := vocabulary.Get(db); err != nil || vocabulary.UserID != userID.(int64) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Word not found"})
+		return
+	}
+
+	correct := gradeAnswer(question.Mode, *vocabulary, answer)
+	err = models.AnswerQuizQuestion(db, question, correct)
+	if errors.Is(err, models.ErrQuestionAnswered) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Question has already been answered"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving test result"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "correct": correct, "expected": vocabulary.Word})
+}
+
+// buildQuiz 從 scoped 中隨機出題，選擇題的干擾選項從 all 中挑選
+func buildQuiz(mode string, scoped, all []models.Vocabulary, count int) []quizQuestion {
+	// 依詞性整理可作為干擾選項的單字
+	byPartOfSpeech := make(map[string][]string)
+	var allWords []string
+	for _, v := range all {
+		allWords = append(allWords, v.Word)
+		seen := make(map[string]bool)
+		for _, def := range v.Definitions {
+			if !seen[def.PartOfSpeech] {
+				seen[def.PartOfSpeech] = true
+				byPartOfSpeech[def.PartOfSpeech] = append(byPartOfSpeech[def.PartOfSpeech], v.Word)
+			}
+		}
+	}
+
+	order := rand.Perm(len(scoped))
+	questions := []quizQuestion{}
+	for _, i := range order {
+		if len(questions) >= count {
+			break
+		}
+		v := scoped[i]
+		def, ok := pickDefinition(v, mode)
+		if !ok {
+			continue
+		}
+
+		q := quizQuestion{WordID: v.ID, Mode: mode, PartOfSpeech: def.PartOfSpeech}
+		switch mode {
+		case "choice":
+			q.Prompt = def.Definition
+			q.Options = pickOptions(v.Word, byPartOfSpeech[def.PartOfSpeech], allWords)
+			if len(q.Options) < 2 {
+				continue
+			}
+		case "typing":
+			q.Prompt = def.Definition
+			q.Hint = typingHint(v.Word)
+		case "cloze":
+			sentence, _, _ := clozeSentence(def.Example, v.Word)
+			q.Prompt = sentence
+			q.Definition = def.Definition
+		}
+		questions = append(questions, q)
+	}
+	return questions
+}
+
+// pickDefinition 隨機選出適合該模式的定義；填空題需要包含單字的例句
+func pickDefinition(v models.Vocabulary, mode string) (models.VocabularyDefinition, bool) {
+	var candidates []models.VocabularyDefinition
+	for _, def := range v.Definitions {
+		if def.Definition == "" {
+			continue
+		}
+		if mode == "cloze" {
+			if _, _, ok := clozeSentence(def.Example, v.Word); !ok {
+				continue
+			}
+		}
+		candidates = append(candidates, def)
+	}
+	if len(candidates) == 0 {
+		return models.VocabularyDefinition{}, false
+	}
+	return candidates[rand.Intn(len(candidates))], true
+}
+
+// pickOptions 以相同詞性的單字作為干擾選項，不足時再從其他單字補足
+func pickOptions(word string, samePartOfSpeech, allWords []string) []string {
+	options := []string{word}
+	used := map[string]bool{strings.ToLower(word): true}
+	for _, pool := range [][]string{samePartOfSpeech, allWords} {
+		for _, i := range rand.Perm(len(pool)) {
+			if len(options) >= quizChoices {
+				break
+			}
+			if key := strings.ToLower(pool[i]); !used[key] {
+				used[key] = true
+				options = append(options, pool[i])
+			}
+		}
+	}
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	return options
+}
+
+// typingHint 顯示首字母與字數，例如 "e______ (7 letters)"
+func typingHint(word string) string {
+	runes := []rune(strings.TrimSpace(word))
+	if len(runes) == 0 {
+		return ""
+	}
+	return string(runes[0]) + strings.Repeat("_", len(runes)-1) + " (" + strconv.Itoa(len(runes)) + " letters)"
+}
+
+// clozeSentence 將例句中第一個屬於該單字的詞（含變化形）挖空，回傳挖空後的句子與被挖掉的詞
+func clozeSentence(example, word string) (string, string, bool) {
+	sentence := strings.ReplaceAll(example, "&quot;", "\"")
+	target := strings.ToLower(word)
+	for _, loc := range wordPattern.FindAllStringIndex(sentence, -1) {
+		token := sentence[loc[0]:loc[1]]
+		for _, lemma := range wordlist.Lemmas(token) {
+			if lemma == target {
+				return sentence[:loc[0]] + clozeBlank + sentence[loc[1]:], token, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// gradeAnswer 批改答案；拼字與填空允許依單字長度的少量錯誤
+func gradeAnswer(mode string, v models.Vocabulary, answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "" {
+		return false
+	}
+
+	switch mode {
+	case "choice":
+		return answer == strings.ToLower(v.Word)
+	case "typing":
+		return editDistance(answer, strings.ToLower(v.Word)) <= typoTolerance(v.Word)
+	case "cloze":
+		// 接受原形或例句中實際出現的變化形
+		accepted := []string{v.Word}
+		for _, def := range v.Definitions {
+			if _, token, ok := clozeSentence(def.Example, v.Word); ok {
+				accepted = append(accepted, token)
+			}
+		}
+		for _, a := range accepted {
+			if editDistance(answer, strings.ToLower(a)) <= typoTolerance(a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typoTolerance 短字必須完全正確，較長的字允許一到兩個錯字
+func typoTolerance(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 4:
+		return 0
+	case n <= 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance 計算兩個字串的編輯距離，相鄰字母對調算作一次錯誤
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// 保留前兩列以處理字母對調
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"testing"
+	"vocabulary/internal/models"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"apple", "apple", 0},
+		{"apple", "appel", 1}, // 相鄰字母對調算一次
+		{"receive", "recieve", 1},
+		{"apple", "aple", 1},
+		{"apple", "apples", 1},
+		{"apple", "apply", 1},
+		{"kitten", "sitting", 3},
+		{"ab", "ba", 1},
+		{"abc", "ca", 3}, // 對調後不可再編輯同一段
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestTypoTolerance(t *testing.T) {
+	tests := []struct {
+		word string
+		want int
+	}{
+		{"", 0},
+		{"cat", 0},
+		{"tree", 0},
+		{"apple", 1},
+		{"abundant", 1},
+		{"beautiful", 2},
+		{"misunderstanding", 2},
+		// 以字元而非位元組計算
+		{"naïve", 1},
+		{"日本語", 0},
+	}
+	for _, tt := range tests {
+		if got := typoTolerance(tt.word); got != tt.want {
+			t.Errorf("typoTolerance(%q) = %d, want %d", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestClozeSentence(t *testing.T) {
+	tests := []struct {
+		name         string
+		example      string
+		word         string
+		wantSentence string
+		wantToken    string
+		wantOK       bool
+	}{
+		{"base form", "I ate an apple today.", "apple", "I ate an " + clozeBlank + " today.", "apple", true},
+		{"plural", "She studies every night.", "study", "She " + clozeBlank + " every night.", "studies", true},
+		{"past tense with e", "He baked a cake.", "bake", "He " + clozeBlank + " a cake.", "baked", true},
+		{"capitalised", "Walked home, they rested.", "walk", clozeBlank + " home, they rested.", "Walked", true},
+		{"first occurrence only", "Cats chase cats.", "cat", clozeBlank + " chase cats.", "Cats", true},
+		{"escaped quotes", "&quot;Run!&quot; he said.", "run", "\"" + clozeBlank + "!\" he said.", "Run", true},
+		{"word inside another word", "The category was wrong.", "cat", "", "", false},
+		{"missing", "Nothing to see here.", "apple", "", "", false},
+		{"empty example", "", "apple", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentence, token, ok := clozeSentence(tt.example, tt.word)
+			if sentence != tt.wantSentence || token != tt.wantToken || ok != tt.wantOK {
+				t.Errorf("clozeSentence(%q, %q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.example, tt.word, sentence, token, ok, tt.wantSentence, tt.wantToken, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGradeAnswer(t *testing.T) {
+	beautiful := models.Vocabulary{Word: "beautiful"}
+	cat := models.Vocabulary{Word: "cat"}
+	study := models.Vocabulary{
+		Word: "study",
+		Definitions: []models.VocabularyDefinition{
+			{Definition: "to learn", Example: "She studies every night."},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		v      models.Vocabulary
+		answer string
+		want   bool
+	}{
+		{"choice exact", "choice", cat, "cat", true},
+		{"choice ignores case and spaces", "choice", cat, "  CAT ", true},
+		{"choice allows no typos", "choice", beautiful, "beautifull", false},
+		{"empty answer", "typing", cat, "", false},
+		{"blank answer", "cloze", study, "   ", false},
+		{"typing exact", "typing", beautiful, "beautiful", true},
+		{"typing transposition", "typing", beautiful, "beuatiful", true},
+		{"typing two typos on a long word", "typing", beautiful, "beatifull", true},
+		{"typing three typos", "typing", beautiful, "baetifull", false},
+		{"typing short word must be exact", "typing", cat, "cta", false},
+		{"cloze base form", "cloze", study, "study", true},
+		{"cloze inflected form from example", "cloze", study, "studies", true},
+		{"cloze typo in inflected form", "cloze", study, "studeis", true},
+		{"cloze wrong word", "cloze", study, "learn", false},
+		{"unknown mode", "essay", cat, "cat", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gradeAnswer(tt.mode, tt.v, tt.answer); got != tt.want {
+				t.Errorf("gradeAnswer(%q, %q, %q) = %v, want %v", tt.mode, tt.v.Word, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
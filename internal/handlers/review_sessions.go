@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sessionModes = map[string]bool{"flip": true, "choice": true, "typing": true, "cloze": true}
+
+// CreateReviewSession 建立複習工作階段並在伺服器端產生所有卡片
+//
+// 表單欄位：mode（flip、choice、typing、cloze，預設 flip）、size、deck_id、tag、order（frequency 或 due，due 只挑選已到期的單字）
+func CreateReviewSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	mode := c.DefaultPostForm("mode", "flip")
+	if !sessionModes[mode] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be flip, choice, typing or cloze"})
+		return
+	}
+
+	size := defaultQuizSize
+	if sizeStr := c.PostForm("size"); sizeStr != "" {
+		n, err := strconv.Atoi(sizeStr)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size"})
+			return
+		}
+		if n < maxQuizSize {
+			size = n
+		} else {
+			size = maxQuizSize
+		}
+	}
+
+	var deckID int64
+	if deckIDStr := c.PostForm("deck_id"); deckIDStr != "" {
+		var err error
+		deckID, err = strconv.ParseInt(deckIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID format"})
+			return
+		}
+	}
+	tag := c.PostForm("tag")
+	var assignmentID int64
+	if idStr := c.PostForm("assignment_id"); idStr != "" {
+		var err error
+		assignmentID, err = strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+			return
+		}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"session": gin.H{"id": 1, "mode": mode, "status": "active", "total": 1, "answered": 0, "correct": 0},
+			"card":    gin.H{"id": 1, "position": 0, "prompt": "example", "part_of_speech": "noun"},
+		})
+		return
+	}
+
+	if deckID != 0 && !checkDeckOwner(c, userID.(int64), deckID) {
+		return
+	}
+
+	all, err := models.GetByUserID(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+		return
+	}
+	scoped, err := filterByScope(all, userID.(int64), deckID, tag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching decks"})
+		return
+	}
+	// 只練習班級指派的單字
+	if assignmentID != 0 {
+		assignment, ok := loadStudentAssignment(c, userID.(int64), assignmentID)
+		if !ok {
+			return
+		}
+		scoped = filterByAssignment(scoped, assignment)
+	}
+	switch c.PostForm("order") {
+	case "frequency":
+		sortVocabularies(scoped, "frequency")
+	case "due":
+		// 只複習已到期的單字，最早到期的排在前面
+		scoped, err = dueVocabularies(userID.(int64), scoped)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching schedules"})
+			return
+		}
+	}
+
+	var cards []models.ReviewCard
+	if mode == "flip" {
+		for _, v := range scoped {
+			if len(cards) >= size {
+				break
+			}
+			cards = append(cards, models.ReviewCard{VocabularyID: v.ID, Prompt: v.Word})
+		}
+	} else {
+		for _, q := range buildQuiz(mode, scoped, all, size) {
+			cards = append(cards, models.ReviewCard{
+				VocabularyID: q.WordID,
+				Prompt:       q.Prompt,
+				PartOfSpeech: q.PartOfSpeech,
+				Definition:   q.Definition,
+				Hint:         q.Hint,
+				Options:      q.Options,
+			})
+		}
+	}
+	if len(cards) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"error":   "No words can be reviewed in this mode",
+		})
+		return
+	}
+
+	session := &models.ReviewSession{UserID: userID.(int64), Mode: mode, DeckID: deckID, Tag: tag}
+	id, err := models.CreateReviewSession(db, session, cards)
+	if err != nil {
+		log.Println("Error creating review session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating session"})
+		return
+	}
+
+	respondWithSession(c, userID.(int64), id)
+}
+
+// GetActiveReviewSession 回傳尚未完成的工作階段，供重新整理或換裝置後繼續
+func GetActiveReviewSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "session": nil})
+		return
+	}
+
+	session, err := models.GetActiveReviewSession(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching session"})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "session": nil})
+		return
+	}
+
+	respondWithSession(c, userID.(int64), session.ID)
+}
+
+// GetReviewSession 回傳工作階段的進度與下一張卡片
+func GetReviewSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"session": gin.H{"id": id, "mode": "flip", "status": "active", "total": 1, "answered": 0, "correct": 0},
+			"card":    gin.H{"id": 1, "position": 0, "prompt": "example", "part_of_speech": "noun"},
+		})
+		return
+	}
+
+	respondWithSession(c, userID.(int64), id)
+}
+
+// AnswerReviewCard 批改目前卡片的答案；翻卡模式以 correct=true/false 自評
+func AnswerReviewCard(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	cardID, err := strconv.ParseInt(c.PostForm("card_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID format"})
+		return
+	}
+	answer := c.PostForm("answer")
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "correct": c.PostForm("correct") == "true", "expected": "example", "card": nil})
+		return
+	}
+
+	session, ok := loadReviewSession(c, userID.(int64), id)
+	if !ok {
+		return
+	}
+	if session.Status != "active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Session is already completed"})
+		return
+	}
+
+	card, err := models.NextReviewCard(db, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching card"})
+		return
+	}
+	if card == nil || card.ID != cardID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Card is not the current card of this session"})
+		return
+	}
+
+	var correct bool
+	if session.Mode == "flip" {
+		correct = c.PostForm("correct") == "true"
+	} else {
+		vocabulary := &models.Vocabulary{ID: card.VocabularyID}
+		if err := vocabulary.Get(db); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching word"})
+			return
+		}
+		correct = gradeAnswer(session.Mode, *vocabulary, answer)
+	}
+
+	err = models.AnswerReviewCard(db, session, card, answer, correct)
+	if errors.Is(err, models.ErrCardAnswered) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Card has already been answered"})
+		return
+	}
+	if err != nil {
+		log.Println("Error answering review card:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving answer"})
+		return
+	}
+
+	next, err := models.NextReviewCard(db, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching card"})
+		return
+	}
+
+	response := gin.H{"success": true, "correct": correct, "expected": card.Word, "card": nil}
+	if next != nil {
+		response["card"] = reviewCardJSON(*next, session.Mode)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// FinishReviewSession 提前結束工作階段
+func FinishReviewSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.CompleteReviewSession(db, userID.(int64), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Active session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error finishing session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ReviewSessionSummary 回傳工作階段的結果統計與每張卡片的作答
+func ReviewSessionSummary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"session":  gin.H{"id": id, "mode": "flip", "status": "completed", "total": 1, "answered": 1, "correct": 1},
+			"accuracy": 1.0,
+			"cards":    []gin.H{{"word": "example", "answered": true, "correct": true}},
+			"missed":   []string{},
+		})
+		return
+	}
+
+	session, ok := loadReviewSession(c, userID.(int64), id)
+	if !ok {
+		return
+	}
+
+	cards, err := models.GetReviewCards(db, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cards"})
+		return
+	}
+
+	list := []gin.H{}
+	missed := []string{}
+	for _, card := range cards {
+		list = append(list, gin.H{
+			"word":     card.Word,
+			"prompt":   card.Prompt,
+			"answer":   card.Answer,
+			"answered": card.Answered,
+			"correct":  card.Correct,
+		})
+		if card.Answered && !card.Correct {
+			missed = append(missed, card.Word)
+		}
+	}
+
+	accuracy := 0.0
+	if session.Answered > 0 {
+		accuracy = float64(session.Correct) / float64(session.Answered)
+	}
+	summary := gin.H{
+		"success":  true,
+		"session":  reviewSessionJSON(session),
+		"accuracy": accuracy,
+		"cards":    list,
+		"missed":   missed,
+	}
+	if session.Status == "completed" {
+		summary["duration_seconds"] = int(session.CompletedAt.Sub(session.CreatedAt).Seconds())
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// respondWithSession 回傳工作階段與下一張尚未作答的卡片
+func respondWithSession(c *gin.Context, userID, id int64) {
+	session, ok := loadReviewSession(c, userID, id)
+	if !ok {
+		return
+	}
+
+	response := gin.H{"success": true, "session": reviewSessionJSON(session), "card": nil}
+	if session.Status == "active" {
+		card, err := models.NextReviewCard(db, session.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching card"})
+			return
+		}
+		if card != nil {
+			response["card"] = reviewCardJSON(*card, session.Mode)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func loadReviewSession(c *gin.Context, userID, id int64) (*models.ReviewSession, bool) {
+	session, err := models.GetReviewSession(db, userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching session"})
+		return nil, false
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return nil, false
+	}
+	return session, true
+}
+
+func reviewSessionJSON(s *models.ReviewSession) gin.H {
+	return gin.H{
+		"id":         s.ID,
+		"mode":       s.Mode,
+		"deck_id":    s.DeckID,
+		"tag":        s.Tag,
+		"status":     s.Status,
+		"total":      s.Total,
+		"answered":   s.Answered,
+		"correct":    s.Correct,
+		"created_at": s.CreatedAt,
+	}
+}
+
+// reviewCardJSON 轉換為前端格式；翻卡模式附上定義，其他模式不洩漏答案
+func reviewCardJSON(card models.ReviewCard, mode string) gin.H {
+	data := gin.H{
+		"id":             card.ID,
+		"position":       card.Position,
+		"prompt":         card.Prompt,
+		"part_of_speech": card.PartOfSpeech,
+		"definition":     card.Definition,
+		"hint":           card.Hint,
+		"options":        card.Options,
+	}
+	if mode == "flip" {
+		vocabulary := &models.Vocabulary{ID: card.VocabularyID}
+		if err := vocabulary.Get(db); err == nil {
+			data["definitions"] = vocabulary.Definitions
+		}
+	}
+	return data
+}
+
+// dueVocabularies 依排程篩選出到期的單字，並依到期時間排序
+func dueVocabularies(userID int64, vocabularies []models.Vocabulary) ([]models.Vocabulary, error) {
+	schedules, err := models.GetWordSchedules(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.Vocabulary, len(vocabularies))
+	for _, v := range vocabularies {
+		byID[v.ID] = v
+	}
+	now := time.Now()
+	var due []models.Vocabulary
+	for _, ws := range schedules {
+		if v, ok := byID[ws.VocabularyID]; ok && isDue(ws.State, now) {
+			due = append(due, v)
+		}
+	}
+	return due, nil
+}
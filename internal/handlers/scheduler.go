@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vocabulary/internal/models"
+	"vocabulary/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSchedulerSettings 回傳使用者的排程演算法與 FSRS 參數
+func GetSchedulerSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, schedulerSettingsJSON(&models.SchedulerSettings{
+			Algorithm:        scheduler.SM2,
+			RequestRetention: scheduler.DefaultRequestRetention,
+		}))
+		return
+	}
+
+	settings, err := models.GetSchedulerSettings(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching scheduler settings"})
+		return
+	}
+	c.JSON(http.StatusOK, schedulerSettingsJSON(settings))
+}
+
+// UpdateSchedulerSettings 切換演算法或目標記憶率，並以新設定重新計算所有單字的排程
+//
+// 表單欄位：algorithm（sm2、fsrs、leitner）、request_retention（0.7–0.97）、reset_weights=true 還原預設參數、
+// leitner_intervals（以逗號分隔的各盒間隔天數，如 1,2,4,8,16，盒子數量即為項目數）
+func UpdateSchedulerSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	algorithm := c.PostForm("algorithm")
+	if algorithm != "" && !scheduler.Valid(algorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scheduling algorithm"})
+		return
+	}
+	var retention float64
+	if retentionStr := c.PostForm("request_retention"); retentionStr != "" {
+		var err error
+		retention, err = strconv.ParseFloat(retentionStr, 64)
+		if err != nil || retention < 0.7 || retention > 0.97 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Request retention must be between 0.7 and 0.97"})
+			return
+		}
+	}
+	var intervals []float64
+	if intervalsStr := c.PostForm("leitner_intervals"); intervalsStr != "" {
+		for _, part := range strings.Split(intervalsStr, ",") {
+			days, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Leitner interval: " + part})
+				return
+			}
+			intervals = append(intervals, days)
+		}
+		if err := scheduler.ValidateLeitnerIntervals(intervals); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Leitner intervals must list " + strconv.Itoa(scheduler.MinLeitnerBoxes) + " to " +
+					strconv.Itoa(scheduler.MaxLeitnerBoxes) + " whole numbers of days in non-decreasing order",
+			})
+			return
+		}
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	settings, err := models.GetSchedulerSettings(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching scheduler settings"})
+		return
+	}
+	if algorithm != "" {
+		settings.Algorithm = algorithm
+	}
+	if retention != 0 {
+		settings.RequestRetention = retention
+	}
+	if intervals != nil {
+		settings.LeitnerIntervals = intervals
+	}
+	if c.PostForm("reset_weights") == "true" {
+		settings.Weights = nil
+		settings.OptimizedAt = time.Time{}
+	}
+
+	if err := models.SaveSchedulerSettings(db, settings); err != nil {
+		log.Println("Error saving scheduler settings:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving scheduler settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// FSRS 參數最佳化很耗費 CPU，因此在背景執行：每位使用者同時只有一個工作，全站同時執行的數量有上限，
+// 每次開始後需等待冷卻時間才能再執行
+const (
+	optimizeCooldown   = time.Hour
+	optimizeTimeout    = 2 * time.Minute
+	maxOptimizeWorkers = 2
+)
+
+// optimizeJob 記錄使用者最近一次最佳化的狀態
+type optimizeJob struct {
+	Status     string                    `json:"status"` // queued, running, done, failed
+	StartedAt  time.Time                 `json:"started_at"`
+	FinishedAt *time.Time                `json:"finished_at,omitempty"`
+	Result     *scheduler.OptimizeResult `json:"result,omitempty"`
+	Error      string                    `json:"error,omitempty"`
+}
+
+var (
+	optimizeMu      sync.Mutex
+	optimizeJobs    = make(map[int64]*optimizeJob)
+	optimizeWorkers = make(chan struct{}, maxOptimizeWorkers)
+)
+
+// OptimizeScheduler 開始以使用者的複習紀錄擬合 FSRS 參數，完成後自動儲存；以 GetOptimizeStatus 查詢進度
+func OptimizeScheduler(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusAccepted, gin.H{"success": true, "status": "queued"})
+		return
+	}
+
+	settings, err := models.GetSchedulerSettings(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching scheduler settings"})
+		return
+	}
+	now := time.Now()
+
+	optimizeMu.Lock()
+	defer optimizeMu.Unlock()
+
+	job := optimizeJobs[userID.(int64)]
+	if job != nil && (job.Status == "queued" || job.Status == "running") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Optimization is already in progress"})
+		return
+	}
+	// 冷卻時間以本機最近一次開始與資料庫中的完成時間為準，其他執行個體完成的最佳化也會計入
+	last := settings.OptimizedAt
+	if job != nil && job.StartedAt.After(last) {
+		last = job.StartedAt
+	}
+	if wait := last.Add(optimizeCooldown).Sub(now); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Parameters were optimized recently, please try again later"})
+		return
+	}
+
+	job = &optimizeJob{Status: "queued", StartedAt: now}
+	optimizeJobs[userID.(int64)] = job
+	go runOptimizeJob(userID.(int64), job)
+
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "status": job.Status})
+}
+
+// GetOptimizeStatus 回傳使用者最近一次最佳化的狀態與結果
+func GetOptimizeStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	optimizeMu.Lock()
+	defer optimizeMu.Unlock()
+
+	job := optimizeJobs[userID.(int64)]
+	if job == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "none"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func runOptimizeJob(userID int64, job *optimizeJob) {
+	optimizeWorkers <- struct{}{}
+	defer func() { <-optimizeWorkers }()
+
+	setJob := func(update func()) {
+		optimizeMu.Lock()
+		defer optimizeMu.Unlock()
+		update()
+	}
+	setJob(func() { job.Status = "running" })
+
+	ctx, cancel := context.WithTimeout(context.Background(), optimizeTimeout)
+	defer cancel()
+	result, err := optimizeUser(ctx, userID)
+
+	setJob(func() {
+		finished := time.Now()
+		job.FinishedAt = &finished
+		switch {
+		case errors.Is(err, scheduler.ErrNotEnoughReviews):
+			job.Status = "failed"
+			job.Error = "Not enough review history yet; at least " + strconv.Itoa(scheduler.MinOptimizeReviews) +
+				" reviews on different days are needed"
+		case errors.Is(err, context.DeadlineExceeded):
+			job.Status = "failed"
+			job.Error = "Optimization took too long"
+		case err != nil:
+			log.Printf("Error optimizing parameters for user %d: %v", userID, err)
+			job.Status = "failed"
+			job.Error = "Error optimizing parameters"
+		default:
+			job.Status = "done"
+			job.Result = result
+		}
+	})
+}
+
+// optimizeUser 以單字 ID 排序複習紀錄，使相同資料得到相同結果，並以使用者的時區區分日期
+func optimizeUser(ctx context.Context, userID int64) (*scheduler.OptimizeResult, error) {
+	histories, err := models.GetReviewHistories(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	wordIDs := make([]int64, 0, len(histories))
+	for id := range histories {
+		wordIDs = append(wordIDs, id)
+	}
+	sort.Slice(wordIDs, func(i, j int) bool { return wordIDs[i] < wordIDs[j] })
+	grouped := make([][]scheduler.Review, 0, len(wordIDs))
+	for _, id := range wordIDs {
+		grouped = append(grouped, histories[id])
+	}
+
+	prefs, err := (&models.User{ID: userID}).GetPreferences(db)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := scheduler.Optimize(ctx, grouped, prefs.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := models.GetSchedulerSettings(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	settings.Weights = result.Weights
+	settings.OptimizedAt = time.Now()
+	if err := models.SaveSchedulerSettings(db, settings); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetWordSchedules 列出每個單字的下次複習時間與目前預測的記憶率
+func GetWordSchedules(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"algorithm": scheduler.SM2,
+			"words":     []gin.H{{"id": 1, "word": "example", "reviewed": false, "due": true, "retrievability": 0}},
+		})
+		return
+	}
+
+	settings, err := models.GetSchedulerSettings(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching scheduler settings"})
+		return
+	}
+	schedules, err := models.GetWordSchedules(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching schedules"})
+		return
+	}
+
+	s := settings.Scheduler()
+	now := time.Now()
+	words := []gin.H{}
+	for _, ws := range schedules {
+		word := gin.H{
+			"id":       ws.VocabularyID,
+			"word":     ws.Word,
+			"reviewed": !ws.LastReview.IsZero(),
+			"due":      isDue(ws.State, now),
+			"reps":     ws.Reps,
+			"lapses":   ws.Lapses,
+		}
+		if !ws.LastReview.IsZero() {
+			word["last_review"] = ws.LastReview
+			word["due_at"] = ws.Due
+			word["retrievability"] = s.Retrievability(ws.State, now)
+			switch settings.Algorithm {
+			case scheduler.FSRS:
+				word["stability"] = ws.Stability
+				word["difficulty"] = ws.Difficulty
+			case scheduler.Leitner:
+				word["box"] = ws.Box
+				word["interval_days"] = ws.Interval
+			default:
+				word["ease_factor"] = ws.EaseFactor
+				word["interval_days"] = ws.Interval
+			}
+		}
+		words = append(words, word)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"algorithm": settings.Algorithm, "words": words})
+}
+
+// isDue 從未複習或已到期的單字都需要複習
+func isDue(s scheduler.State, now time.Time) bool {
+	return s.LastReview.IsZero() || !s.Due.After(now)
+}
+
+// GetLeitnerBoxes 回傳每個盒子中的單字數量與已到期的數量
+func GetLeitnerBoxes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"new": 1, "boxes": leitnerBoxesJSON(scheduler.DefaultLeitnerIntervals, nil)})
+		return
+	}
+
+	settings, err := models.GetSchedulerSettings(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching scheduler settings"})
+		return
+	}
+	if settings.Algorithm != scheduler.Leitner {
+		c.JSON(http.StatusConflict, gin.H{"error": "The Leitner scheduler is not enabled"})
+		return
+	}
+
+	counts, err := models.GetLeitnerBoxes(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting boxes"})
+		return
+	}
+	newWords := 0
+	for _, b := range counts {
+		if b.Box == 0 {
+			newWords = b.Words
+		}
+	}
+
+	intervals := scheduler.NewLeitner(settings.LeitnerIntervals).Intervals
+	c.JSON(http.StatusOK, gin.H{"new": newWords, "boxes": leitnerBoxesJSON(intervals, counts)})
+}
+
+// leitnerBoxesJSON 列出所有盒子，沒有單字的盒子數量為 0
+func leitnerBoxesJSON(intervals []float64, counts []models.LeitnerBox) []gin.H {
+	boxes := make([]gin.H, len(intervals))
+	for i, days := range intervals {
+		boxes[i] = gin.H{"box": i + 1, "interval_days": days, "words": 0, "due": 0}
+	}
+	for _, b := range counts {
+		if b.Box >= 1 && b.Box <= len(boxes) {
+			boxes[b.Box-1]["words"] = b.Words
+			boxes[b.Box-1]["due"] = b.Due
+		}
+	}
+	return boxes
+}
+
+func schedulerSettingsJSON(s *models.SchedulerSettings) gin.H {
+	weights := s.Weights
+	if weights == nil {
+		weights = scheduler.DefaultWeights
+	}
+	data := gin.H{
+		"algorithm":         s.Algorithm,
+		"algorithms":        scheduler.Algorithms,
+		"request_retention": s.RequestRetention,
+		"weights":           weights,
+		"optimized":         s.Weights != nil,
+		"leitner_intervals": scheduler.NewLeitner(s.LeitnerIntervals).Intervals,
+	}
+	if !s.OptimizedAt.IsZero() {
+		data["optimized_at"] = s.OptimizedAt
+	}
+	return data
+}
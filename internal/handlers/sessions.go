@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshToken 以 refresh token 換發新的 access token，refresh token 同時輪替
+func RefreshToken(c *gin.Context) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	claims, err := auth.RefreshSession(db, c)
+	if errors.Is(err, models.ErrSessionInvalid) || errors.Is(err, models.ErrRefreshReused) {
+		auth.ClearCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired, please log in again"})
+		return
+	}
+	if err != nil {
+		log.Println("Error refreshing session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error refreshing session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"expires_at": time.Now().Add(auth.AccessTokenTTL),
+		"session_id": claims.SessionID,
+	})
+}
+
+// ListSessions 列出目前登入中的裝置
+func ListSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	currentID := c.GetInt64("session_id")
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		now := time.Now()
+		c.JSON(http.StatusOK, gin.H{"sessions": []gin.H{sessionJSON(models.Session{
+			ID: 1, UserAgent: c.Request.UserAgent(), IP: c.ClientIP(), CreatedAt: now, LastUsedAt: now,
+			ExpiresAt: now.Add(auth.RefreshTokenTTL),
+		}, 1)}})
+		return
+	}
+
+	sessions, err := models.GetActiveSessions(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching sessions"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, sessionJSON(s, currentID))
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeSession 登出指定的裝置；撤銷目前的工作階段時一併清除 cookie
+func RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	err = models.RevokeSession(db, userID.(int64), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+		return
+	}
+
+	if id == c.GetInt64("session_id") {
+		auth.ClearCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RevokeAllSessions 登出所有裝置
+//
+// 表單欄位：keep_current=true 保留目前這個裝置的登入
+func RevokeAllSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	keepCurrent := c.PostForm("keep_current") == "true"
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "revoked": 0})
+		return
+	}
+
+	var keepID int64
+	if keepCurrent {
+		keepID = c.GetInt64("session_id")
+	}
+	revoked, err := models.RevokeAllSessions(db, userID.(int64), keepID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking sessions"})
+		return
+	}
+
+	if !keepCurrent {
+		auth.ClearCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "revoked": revoked})
+}
+
+func sessionJSON(s models.Session, currentID int64) gin.H {
+	return gin.H{
+		"id":           s.ID,
+		"user_agent":   s.UserAgent,
+		"ip":           s.IP,
+		"created_at":   s.CreatedAt,
+		"last_used_at": s.LastUsedAt,
+		"expires_at":   s.ExpiresAt,
+		"current":      s.ID == currentID,
+	}
+}
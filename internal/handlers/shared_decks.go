@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sharedDeckRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Words       []struct {
+		Word        string              `json:"word"`
+		Definitions []map[string]string `json:"definitions"`
+	} `json:"words"`
+}
+
+func ShowSharedDecks(c *gin.Context) {
+	c.HTML(http.StatusOK, "shared_decks.html", gin.H{
+		"title":           "Word Lists",
+		"IsAuthenticated": true,
+	})
+}
+
+// ListSharedDecks 列出所有公開的單字表
+func ListSharedDecks(c *gin.Context) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"decks": []gin.H{
+				{"id": 1, "name": "GRE 1000", "description": "Test deck", "word_count": 1},
+			},
+		})
+		return
+	}
+
+	decks, err := models.GetSharedDecks(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching word lists"})
+		return
+	}
+
+	list := []gin.H{}
+	for _, d := range decks {
+		list = append(list, gin.H{
+			"id":          d.ID,
+			"name":        d.Name,
+			"description": d.Description,
+			"word_count":  d.WordCount,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decks": list})
+}
+
+// GetSharedDeck 回傳單字表內容，並標示使用者已擁有的單字
+func GetSharedDeck(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	deck, ok := loadSharedDeck(c)
+	if !ok {
+		return
+	}
+
+	known := map[string]bool{}
+	if os.Getenv("SKIP_DB") != "true" {
+		var err error
+		known, err = models.GetWordSet(db, userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+			return
+		}
+	}
+
+	words := []gin.H{}
+	for _, w := range deck.Words {
+		words = append(words, gin.H{
+			"word":        w.Word,
+			"definitions": w.Definitions,
+			"owned":       known[strings.ToLower(w.Word)],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          deck.ID,
+		"name":        deck.Name,
+		"description": deck.Description,
+		"words":       words,
+	})
+}
+
+// ImportSharedDeck 將單字表中選取的單字加入使用者的詞彙，略過已擁有的單字
+func ImportSharedDeck(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var data struct {
+		Words []string `json:"words"` // 空白表示匯入全部
+	}
+	// 沒有請求本文同樣視為匯入全部
+	if err := c.ShouldBindJSON(&data); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	deck, ok := loadSharedDeck(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"imported": len(deck.Words),
+			"skipped":  []string{},
+		})
+		return
+	}
+
+	selected := make(map[string]bool)
+	for _, w := range data.Words {
+		selected[strings.ToLower(strings.TrimSpace(w))] = true
+	}
+
+	known, err := models.GetWordSet(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
+		return
+	}
+
+	var words []models.Vocabulary
+	skipped := []string{}
+	for _, w := range deck.Words {
+		key := strings.ToLower(w.Word)
+		if len(selected) > 0 && !selected[key] {
+			continue
+		}
+		if known[key] {
+			skipped = append(skipped, w.Word)
+			continue
+		}
+		words = append(words, models.Vocabulary{Word: w.Word, Definitions: w.Definitions})
+		known[key] = true
+	}
+
+	// 全部成功或全部不匯入
+	if err := models.CreateBatch(db, userID.(int64), words); err != nil {
+		log.Println("Error importing words:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error importing words"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"imported": len(words),
+		"skipped":  skipped,
+	})
+}
+
+// CreateSharedDeck 建立公開單字表（管理員）
+func CreateSharedDeck(c *gin.Context) {
+	name, description, words, ok := bindSharedDeck(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "id": 1})
+		return
+	}
+
+	id, err := models.CreateSharedDeck(db, name, description, words)
+	if err != nil {
+		log.Println("Error creating word list:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating word list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id})
+}
+
+// UpdateSharedDeck 取代公開單字表的內容（管理員）
+func UpdateSharedDeck(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	name, description, words, ok := bindSharedDeck(c)
+	if !ok {
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.UpdateSharedDeck(db, id, name, description, words); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Word list not found"})
+			return
+		}
+		log.Println("Error updating word list:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating word list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DeleteSharedDeck 刪除公開單字表（管理員）
+func DeleteSharedDeck(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := models.DeleteSharedDeck(db, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting word list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func loadSharedDeck(c *gin.Context) (*models.SharedDeck, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		return &models.SharedDeck{
+			ID:   id,
+			Name: "GRE 1000",
+			Words: []models.SharedDeckWord{
+				{
+					Word: "example",
+					Definitions: []models.VocabularyDefinition{
+						{PartOfSpeech: "noun", Definition: "a representative form or pattern"},
+					},
+				},
+			},
+		}, true
+	}
+
+	deck, err := models.GetSharedDeck(db, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching word list"})
+		return nil, false
+	}
+	if deck == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Word list not found"})
+		return nil, false
+	}
+	return deck, true
+}
+
+func bindSharedDeck(c *gin.Context) (string, string, []models.SharedDeckWord, bool) {
+	var data sharedDeckRequest
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return "", "", nil, false
+	}
+
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return "", "", nil, false
+	}
+
+	var words []models.SharedDeckWord
+	for _, w := range data.Words {
+		word := strings.TrimSpace(w.Word)
+		if word == "" || len(w.Definitions) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Word and definitions are required"})
+			return "", "", nil, false
+		}
+		words = append(words, models.SharedDeckWord{
+			Word:        word,
+			Definitions: toVocabularyDefinitions(w.Definitions),
+		})
+	}
+
+	return name, strings.TrimSpace(data.Description), words, true
+}
@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 匯入時可對應的欄位
+var importFields = []string{"word", "part_of_speech", "definition", "example", "tags"}
+
+// 未提供對應時，依標題名稱自動判斷欄位
+var importFieldAliases = map[string]string{
+	"word":           "word",
+	"term":           "word",
+	"vocabulary":     "word",
+	"part_of_speech": "part_of_speech",
+	"part of speech": "part_of_speech",
+	"partofspeech":   "part_of_speech",
+	"pos":            "part_of_speech",
+	"definition":     "definition",
+	"definitions":    "definition",
+	"meaning":        "definition",
+	"example":        "example",
+	"examples":       "example",
+	"sentence":       "example",
+	"tags":           "tags",
+	"tag":            "tags",
+}
+
+const (
+	// 攤平格式中同一格內多個值的分隔符號
+	flatSeparator = " | "
+	// 匯入檔案的列數上限
+	maxImportRows = 10000
+	// dry run 預覽回傳的單字數量
+	importPreviewSize = 50
+)
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type importWord struct {
+	Word        string                        `json:"word"`
+	Definitions []models.VocabularyDefinition `json:"definitions"`
+	Tags        []string                      `json:"tags"`
+	Action      string                        `json:"action"` // create, skip, merge, overwrite
+	rows        []int
+}
+
+// ExportVocabulary 以 CSV 或 TSV 串流輸出使用者的單字
+// layout=rows 每個定義一列；layout=flat 每個單字一列，多個定義以 " | " 分隔
+func ExportVocabulary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "tsv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or tsv"})
+		return
+	}
+	layout := c.DefaultQuery("layout", "rows")
+	if layout != "rows" && layout != "flat" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Layout must be rows or flat"})
+		return
+	}
+
+	contentType := "text/csv"
+	if format == "tsv" {
+		contentType = "text/tab-separated-values"
+	}
+	c.Header("Content-Type", contentType+"; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="vocabulary-%s.%s"`, time.Now().Format("20060102"), format))
+
+	w := csv.NewWriter(c.Writer)
+	if format == "tsv" {
+		w.Comma = '\t'
+	}
+	w.Write([]string{"word", "part_of_speech", "definition", "example", "tags", "level", "frequency_rank", "created_at"})
+
+	writeWord := func(v models.Vocabulary) error {
+		tags := strings.Join(v.Tags, ",")
+		rank := ""
+		if v.FrequencyRank > 0 {
+			rank = strconv.Itoa(v.FrequencyRank)
+		}
+		created := v.CreatedAt.Format(time.RFC3339)
+
+		if layout == "flat" {
+			var partsOfSpeech, definitions, examples []string
+			for _, def := range v.Definitions {
+				partsOfSpeech = append(partsOfSpeech, def.PartOfSpeech)
+				definitions = append(definitions, def.Definition)
+				examples = append(examples, strings.ReplaceAll(def.Example, "&quot;", "\""))
+			}
+			w.Write([]string{v.Word, strings.Join(partsOfSpeech, flatSeparator), strings.Join(definitions, flatSeparator),
+				strings.Join(examples, flatSeparator), tags, v.CEFRLevel, rank, created})
+		} else {
+			if len(v.Definitions) == 0 {
+				w.Write([]string{v.Word, "", "", "", tags, v.CEFRLevel, rank, created})
+			}
+			for _, def := range v.Definitions {
+				w.Write([]string{v.Word, def.PartOfSpeech, def.Definition, strings.ReplaceAll(def.Example, "&quot;", "\""),
+					tags, v.CEFRLevel, rank, created})
+			}
+		}
+
+		// 每個單字寫完即送出，避免整份檔案暫存在記憶體中
+		w.Flush()
+		return w.Error()
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		writeWord(models.Vocabulary{
+			Word: "example",
+			Definitions: []models.VocabularyDefinition{
+				{PartOfSpeech: "noun", Definition: "a representative form or pattern", Example: "This is an example of a test word."},
+			},
+		})
+		return
+	}
+
+	if err := models.EachVocabulary(db, userID.(int64), writeWord); err != nil {
+		// 標頭已送出，只能記錄錯誤
+		log.Println("Error exporting vocabulary:", err)
+	}
+}
+
+// ImportVocabulary 從 CSV 或 TSV 匯入單字
+//
+// 表單欄位：
+//   - file: 上傳的檔案
+//   - format: csv 或 tsv，未提供時依副檔名判斷
+//   - mapping: JSON，欄位名稱對應到欄位索引或標題，例如 {"word": 0, "definition": "Meaning"}
+//   - has_header: 第一列是否為標題（預設 true）
+//   - duplicates: skip、merge 或 overwrite（預設 skip）
+//   - layout: rows 或 flat（預設 rows），與匯出的格式相同
+//   - separator: 同一格內多個定義的分隔符號，layout=flat 時預設為 " | "
+//   - dry_run: true 時只回傳預覽，不寫入資料庫
+func ImportVocabulary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		format = "csv"
+		if ext := strings.ToLower(filepath.Ext(fileHeader.Filename)); ext == ".tsv" || ext == ".tab" {
+			format = "tsv"
+		}
+	}
+	if format != "csv" && format != "tsv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or tsv"})
+		return
+	}
+
+	duplicates := c.DefaultPostForm("duplicates", "skip")
+	if duplicates != "skip" && duplicates != "merge" && duplicates != "overwrite" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicates must be skip, merge or overwrite"})
+		return
+	}
+	hasHeader := c.DefaultPostForm("has_header", "true") == "true"
+	dryRun := c.PostForm("dry_run") == "true"
+	layout := c.DefaultPostForm("layout", "rows")
+	if layout != "rows" && layout != "flat" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Layout must be rows or flat"})
+		return
+	}
+	separator := c.PostForm("separator")
+	if separator == "" && layout == "flat" {
+		separator = flatSeparator
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	if format == "tsv" {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+
+	var header []string
+	if hasHeader {
+		header, err = r.Read()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read header row"})
+			return
+		}
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+	}
+
+	columns, err := resolveColumns(c.PostForm("mapping"), header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 讀取並依單字分組，同一單字的多列合併為多個定義
+	var words []*importWord
+	index := make(map[string]*importWord)
+	rowErrors := []importRowError{}
+	rowNumber := 1
+	if hasHeader {
+		rowNumber = 2
+	}
+	for ; ; rowNumber++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrors = append(rowErrors, importRowError{Row: rowNumber, Error: parseErr.Err.Error()})
+				continue
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		if rowNumber > maxImportRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Files are limited to %d rows", maxImportRows)})
+			return
+		}
+
+		word, definitions, tags, err := parseImportRow(record, columns, separator)
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: rowNumber, Error: err.Error()})
+			continue
+		}
+		if word == "" {
+			continue // 空白列
+		}
+
+		key := strings.ToLower(word)
+		w, ok := index[key]
+		if !ok {
+			w = &importWord{Word: word}
+			index[key] = w
+			words = append(words, w)
+		}
+		w.Definitions = append(w.Definitions, definitions...)
+		w.Tags = append(w.Tags, tags...)
+		w.rows = append(w.rows, rowNumber)
+	}
+
+	if !resolveImport(c, userID.(int64), words, rowErrors, duplicates, dryRun) {
+		return
+	}
+	if !dryRun {
+		rowErrors = saveImport(userID.(int64), words, rowErrors, func(w *importWord) models.Vocabulary {
+			return models.Vocabulary{Word: w.Word, Definitions: w.Definitions, Tags: w.Tags}
+		})
+	}
+
+	c.JSON(http.StatusOK, importSummary(words, rowErrors, dryRun))
+}
+
+// resolveColumns 將欄位對應轉換為欄位索引，未對應的欄位為 -1
+func resolveColumns(mappingJSON string, header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for _, field := range importFields {
+		columns[field] = -1
+	}
+
+	headerIndex := func(name string) int {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if mappingJSON != "" {
+		var mapping map[string]interface{}
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			return nil, errors.New("Invalid mapping format")
+		}
+		for field, value := range mapping {
+			if _, ok := columns[field]; !ok {
+				return nil, fmt.Errorf("Unknown field %q in mapping", field)
+			}
+			switch v := value.(type) {
+			case float64:
+				columns[field] = int(v)
+			case string:
+				if columns[field] = headerIndex(v); columns[field] < 0 {
+					return nil, fmt.Errorf("Column %q not found in header", v)
+				}
+			default:
+				return nil, fmt.Errorf("Invalid mapping for %q", field)
+			}
+		}
+	} else if header != nil {
+		for i, h := range header {
+			if field, ok := importFieldAliases[strings.ToLower(strings.TrimSpace(h))]; ok && columns[field] < 0 {
+				columns[field] = i
+			}
+		}
+	} else {
+		// 沒有標題也沒有對應時依預設順序
+		for i, field := range importFields {
+			columns[field] = i
+		}
+	}
+
+	if columns["word"] < 0 {
+		return nil, errors.New("Word column is required")
+	}
+	if columns["definition"] < 0 {
+		return nil, errors.New("Definition column is required")
+	}
+	return columns, nil
+}
+
+// parseImportRow 解析一列資料，separator 不為空時將同一格拆成多個定義
+func parseImportRow(record []string, columns map[string]int, separator string) (string, []models.VocabularyDefinition, []string, error) {
+	cell := func(field string) string {
+		i := columns[field]
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	word := cell("word")
+	if word == "" {
+		for _, value := range record {
+			if strings.TrimSpace(value) != "" {
+				return "", nil, nil, errors.New("word is empty")
+			}
+		}
+		return "", nil, nil, nil
+	}
+	if len([]rune(word)) > 100 {
+		return "", nil, nil, errors.New("word is longer than 100 characters")
+	}
+
+	split := func(value string) []string {
+		if separator == "" {
+			return []string{value}
+		}
+		parts := strings.Split(value, separator)
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts
+	}
+
+	partsOfSpeech := split(cell("part_of_speech"))
+	definitions := split(cell("definition"))
+	examples := split(cell("example"))
+
+	var raw []map[string]string
+	for i, definition := range definitions {
+		if definition == "" {
+			continue
+		}
+		def := map[string]string{"definition": definition}
+		if i < len(partsOfSpeech) {
+			def["partOfSpeech"] = partsOfSpeech[i]
+		}
+		if i < len(examples) {
+			def["example"] = examples[i]
+		}
+		raw = append(raw, def)
+	}
+	if len(raw) == 0 {
+		return "", nil, nil, errors.New("definition is empty")
+	}
+
+	var tags []string
+	if value := cell("tags"); value != "" {
+		tags = strings.Split(value, ",")
+	}
+
+	return word, toVocabularyDefinitions(raw), tags, nil
+}
+
+// resolveImport 依重複處理方式決定每個單字的動作，合併時併入既有的定義與標籤
+// 回傳 false 時已寫入回應
+func resolveImport(c *gin.Context, userID int64, words []*importWord, rowErrors []importRowError, duplicates string, dryRun bool) bool {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		for _, w := range words {
+			w.Action = "create"
+		}
+		c.JSON(http.StatusOK, importSummary(words, rowErrors, dryRun))
+		return false
+	}
+
+	known, err := models.GetWordSet(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing words"})
+		return false
+	}
+
+	for _, w := range words {
+		w.Tags = models.NormalizeTags(w.Tags)
+		if len(w.Definitions) > maxDefinitions {
+			w.Definitions = w.Definitions[:maxDefinitions]
+		}
+
+		if !known[strings.ToLower(w.Word)] {
+			w.Action = "create"
+			continue
+		}
+		w.Action = duplicates
+		if duplicates == "merge" {
+			existing, err := models.GetByWord(db, userID, w.Word)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing words"})
+				return false
+			}
+			if existing != nil {
+				w.Definitions = mergeDefinitions(existing.Definitions, w.Definitions)
+				w.Tags = models.NormalizeTags(append(existing.Tags, w.Tags...))
+			}
+		}
+	}
+	return true
+}
+
+// saveImport 寫入未略過的單字，儲存失敗的單字標記為 error 並加入列錯誤
+func saveImport(userID int64, words []*importWord, rowErrors []importRowError, toVocabulary func(w *importWord) models.Vocabulary) []importRowError {
+	for _, w := range words {
+		if w.Action == "skip" {
+			continue
+		}
+		if err := models.CreateVocabulary(db, userID, toVocabulary(w)); err != nil {
+			log.Println("Error importing word:", w.Word, err)
+			w.Action = "error"
+			rowErrors = append(rowErrors, importRowError{Row: w.rows[0], Error: "Error saving word"})
+		}
+	}
+	return rowErrors
+}
+
+// mergeDefinitions 將新的定義加在既有定義之後，略過重複的定義
+func mergeDefinitions(existing, incoming []models.VocabularyDefinition) []models.VocabularyDefinition {
+	merged := append([]models.VocabularyDefinition{}, existing...)
+	seen := make(map[string]bool)
+	for _, def := range existing {
+		seen[strings.ToLower(def.PartOfSpeech+"\x00"+def.Definition)] = true
+	}
+	for _, def := range incoming {
+		key := strings.ToLower(def.PartOfSpeech + "\x00" + def.Definition)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, def)
+	}
+	if len(merged) > maxDefinitions {
+		merged = merged[:maxDefinitions]
+	}
+	return merged
+}
+
+func importSummary(words []*importWord, rowErrors []importRowError, dryRun bool) gin.H {
+	counts := map[string]int{"create": 0, "skip": 0, "merge": 0, "overwrite": 0}
+	for _, w := range words {
+		if w.Action != "error" {
+			counts[w.Action]++
+		}
+	}
+
+	preview := words
+	if len(preview) > importPreviewSize {
+		preview = preview[:importPreviewSize]
+	}
+	if preview == nil {
+		preview = []*importWord{}
+	}
+
+	return gin.H{
+		"success":     true,
+		"dry_run":     dryRun,
+		"created":     counts["create"],
+		"skipped":     counts["skip"],
+		"merged":      counts["merge"],
+		"overwritten": counts["overwrite"],
+		"errors":      rowErrors,
+		"preview":     preview,
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+	forecastDays     = 30
+	// 至少複習過幾次才列入最難單字
+	hardestMinReviews = 3
+	hardestLimit      = 10
+)
+
+// ShowStats 顯示學習統計頁面
+func ShowStats(c *gin.Context) {
+	c.HTML(http.StatusOK, "stats.html", gin.H{
+		"title":           "Statistics",
+		"IsAuthenticated": true,
+	})
+}
+
+// GetStats 回傳學習統計：每日新增與複習數、正確率、保留率曲線、連續天數、熟練度分布、最難單字與未來到期預測
+//
+// 查詢參數：days（統計最近幾天，預設 30，最多 365）
+func GetStats(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	days := defaultStatsDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		n, err := strconv.Atoi(daysStr)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+			return
+		}
+		if n < maxStatsDays {
+			days = n
+		} else {
+			days = maxStatsDays
+		}
+	}
+
+	now := time.Now()
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		today := models.StartOfDay(now, time.UTC)
+		since := today.AddDate(0, 0, -(days - 1))
+		c.JSON(http.StatusOK, statsJSON(since, today, days,
+			[]models.DailyCount{{Date: today.Format(models.DayFormat), Count: 3}},
+			[]models.DailyCount{{Date: today.Format(models.DayFormat), Count: 10, Correct: 8}},
+			[]models.RetentionPoint{{Days: 1, Reviews: 10, Correct: 9}},
+			1, &models.MasteryLevels{New: 1},
+			[]models.WordAccuracy{{VocabularyID: 1, Word: "example", Reviews: 4, Correct: 1}},
+			[]models.DailyCount{{Date: today.Format(models.DayFormat), Count: 5}},
+		))
+		return
+	}
+
+	uid := userID.(int64)
+	// 依使用者時區劃分日期，與每日目標及連續天數一致
+	prefs, err := (&models.User{ID: uid}).GetPreferences(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	loc := prefs.Location()
+	today := models.StartOfDay(now, loc)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	added, err := models.GetWordsAddedPerDay(db, uid, since, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	reviews, err := models.GetReviewsPerDay(db, uid, since, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	retention, err := models.GetRetentionCurve(db, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	streak, err := models.GetReviewStreak(db, uid, now, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	mastery, err := models.GetMasteryLevels(db, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	hardest, err := models.GetHardestWords(db, uid, hardestMinReviews, hardestLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+	forecast, err := models.GetDueForecast(db, uid, now, today.AddDate(0, 0, forecastDays), loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, statsJSON(since, today, days, added, reviews, retention,
+		streak, mastery, hardest, forecast))
+}
+
+func statsJSON(since, today time.Time, days int, added, reviews []models.DailyCount, retention []models.RetentionPoint,
+	streak int, mastery *models.MasteryLevels, hardest []models.WordAccuracy, forecast []models.DailyCount) gin.H {
+	// 補齊沒有資料的日期，方便前端直接繪圖
+	addedByDate := make(map[string]int)
+	for _, d := range added {
+		addedByDate[d.Date] = d.Count
+	}
+	reviewsByDate := make(map[string]models.DailyCount)
+	for _, d := range reviews {
+		reviewsByDate[d.Date] = d
+	}
+	daily := make([]gin.H, 0, days)
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format(models.DayFormat)
+		r := reviewsByDate[date]
+		entry := gin.H{"date": date, "added": addedByDate[date], "reviews": r.Count, "correct": r.Correct}
+		if r.Count > 0 {
+			entry["accuracy"] = float64(r.Correct) / float64(r.Count)
+		}
+		daily = append(daily, entry)
+	}
+
+	curve := make([]gin.H, 0, len(retention))
+	for _, p := range retention {
+		curve = append(curve, gin.H{"days": p.Days, "reviews": p.Reviews, "retention": float64(p.Correct) / float64(p.Reviews)})
+	}
+
+	hard := make([]gin.H, 0, len(hardest))
+	for _, w := range hardest {
+		hard = append(hard, gin.H{
+			"id":       w.VocabularyID,
+			"word":     w.Word,
+			"reviews":  w.Reviews,
+			"correct":  w.Correct,
+			"accuracy": float64(w.Correct) / float64(w.Reviews),
+		})
+	}
+
+	upcoming := make([]gin.H, 0, len(forecast))
+	for _, d := range forecast {
+		upcoming = append(upcoming, gin.H{"date": d.Date, "due": d.Count})
+	}
+
+	return gin.H{
+		"days":      days,
+		"daily":     daily,
+		"retention": curve,
+		"streak":    streak,
+		"mastery": gin.H{
+			"new":      mastery.New,
+			"learning": mastery.Learning,
+			"young":    mastery.Young,
+			"mature":   mastery.Mature,
+		},
+		"hardest":  hard,
+		"forecast": upcoming,
+	}
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+const invalidCodeMessage = "Invalid verification code"
+
+// LoginTwoFactor 登入第二步：驗證 TOTP 驗證碼或備用碼後才建立工作階段
+func LoginTwoFactor(c *gin.Context) {
+	now := time.Now()
+	token, _ := c.Cookie(auth.ChallengeCookie)
+	userID, err := auth.ParseLoginChallenge(token, now)
+	if err != nil {
+		auth.ClearChallengeCookie(c)
+		c.HTML(http.StatusUnauthorized, "login.html", gin.H{
+			"title": "Login",
+			"error": "Your login attempt has expired. Please log in again.",
+		})
+		return
+	}
+
+	user, err := models.GetUserByID(db, userID)
+	if err != nil || user == nil {
+		c.HTML(http.StatusInternalServerError, "login_2fa.html", gin.H{
+			"title": "Two-Factor Authentication",
+			"error": "Error checking verification code",
+		})
+		return
+	}
+
+	// 驗證碼錯誤與密碼錯誤共用同一組節流計數
+	attempt, wait := startLoginAttempt(c.ClientIP(), loginAccountKey(user.Username), now)
+	if wait > 0 {
+		recordAuthEvent(c, user.ID, user.Username, models.EventLoginThrottled, "two-factor")
+		respondThrottled(c, "login_2fa.html", user.Username, wait)
+		return
+	}
+
+	method, ok, err := verifySecondFactor(user.ID, c.PostForm("code"), now)
+	if err != nil {
+		log.Println("Error verifying second factor:", err)
+		c.HTML(http.StatusInternalServerError, "login_2fa.html", gin.H{
+			"title": "Two-Factor Authentication",
+			"error": "Error checking verification code",
+		})
+		return
+	}
+	if !ok {
+		loginFailed(c, "login_2fa.html", user.ID, user.Username, attempt, "wrong two-factor code", now)
+		return
+	}
+	if method == "recovery code" {
+		recordAuthEvent(c, user.ID, user.Username, models.EventRecoveryCodeUsed, "")
+	}
+
+	auth.ClearChallengeCookie(c)
+	completeLogin(c, user, attempt, "two-factor: "+method)
+}
+
+// verifySecondFactor 接受 6 位數的 TOTP 驗證碼或尚未使用的備用碼，回傳使用的方式
+func verifySecondFactor(userID int64, code string, now time.Time) (string, bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", false, nil
+	}
+
+	if isDigits(code) {
+		totp, err := models.GetTOTP(db, userID)
+		if err != nil || totp == nil || !totp.Enabled {
+			return "", false, err
+		}
+		step, ok := auth.VerifyTOTP(totp.Secret, code, totp.LastUsedStep, now)
+		if !ok {
+			return "", false, nil
+		}
+		// 同一組驗證碼只能使用一次
+		fresh, err := models.UseTOTPStep(db, userID, step)
+		return "totp", fresh, err
+	}
+
+	used, err := models.UseRecoveryCode(db, userID, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	return "recovery code", used, err
+}
+
+func isDigits(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// GetTwoFactorStatus 回傳兩步驟驗證是否啟用與剩餘的備用碼數量
+func GetTwoFactorStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "recovery_codes_remaining": 0})
+		return
+	}
+
+	totp, err := models.GetTOTP(db, userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching two-factor settings"})
+		return
+	}
+	remaining := 0
+	if totp != nil && totp.Enabled {
+		remaining, err = models.CountRecoveryCodes(db, userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching two-factor settings"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  totp != nil && totp.Enabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor 產生新的 TOTP 金鑰並回傳供驗證器 App 掃描的 QR code，需再以 EnableTwoFactor 確認
+func SetupTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating secret"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, twoFactorSetupJSON(os.Getenv("TEST_USER"), secret))
+		return
+	}
+
+	user, ok := reauthenticate(c, userID.(int64), c.PostForm("current_password"))
+	if !ok {
+		return
+	}
+	err = models.SavePendingTOTP(db, user.ID, secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, twoFactorSetupJSON(user.Username, secret))
+}
+
+func twoFactorSetupJSON(username, secret string) gin.H {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Vocabulary"
+	}
+	uri := auth.TOTPURI(issuer, username, secret)
+	result := gin.H{
+		"secret":           secret,
+		"provisioning_uri": uri,
+	}
+	if png, err := qrcode.Encode(uri, qrcode.Medium, 256); err == nil {
+		result["qr_code"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	}
+	return result
+}
+
+// EnableTwoFactor 以第一組驗證碼確認金鑰後啟用兩步驟驗證，並回傳只會顯示一次的備用碼
+func EnableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	code := strings.TrimSpace(c.PostForm("code"))
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating recovery codes"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "recovery_codes": codes})
+		return
+	}
+
+	uid := userID.(int64)
+	totp, err := models.GetTOTP(db, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching two-factor settings"})
+		return
+	}
+	if totp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+	if totp.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	step, ok := auth.VerifyTOTP(totp.Secret, code, totp.LastUsedStep, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidCodeMessage})
+		return
+	}
+
+	if err := models.EnableTOTP(db, uid, step, hashes); err != nil {
+		log.Println("Error enabling two-factor authentication:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error enabling two-factor authentication"})
+		return
+	}
+	recordAuthEvent(c, uid, "", models.EventTwoFactorEnabled, "")
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "recovery_codes": codes})
+}
+
+// DisableTwoFactor 停用兩步驟驗證，需要目前的密碼（或剛重新驗證身分）與一組驗證碼或備用碼
+func DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	user, ok := reauthenticate(c, userID.(int64), c.PostForm("current_password"))
+	if !ok {
+		return
+	}
+	if _, ok, err := verifySecondFactor(user.ID, c.PostForm("code"), time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking verification code"})
+		return
+	} else if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidCodeMessage})
+		return
+	}
+
+	if err := models.DisableTOTP(db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error disabling two-factor authentication"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventTwoFactorDisabled, "")
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RegenerateRecoveryCodes 作廢舊的備用碼並產生新的一組，需要目前的密碼或剛重新驗證身分
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating recovery codes"})
+		return
+	}
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+		return
+	}
+
+	user, ok := reauthenticate(c, userID.(int64), c.PostForm("current_password"))
+	if !ok {
+		return
+	}
+	enabled, err := models.HasTOTPEnabled(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching two-factor settings"})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if err := models.ReplaceRecoveryCodes(db, user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving recovery codes"})
+		return
+	}
+	recordAuthEvent(c, user.ID, user.Username, models.EventRecoveryCodesReissued, "")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// newRecoveryCodes 產生備用碼與對應的雜湊；資料庫只儲存雜湊
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.NewRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashToken(auth.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
@@ -2,18 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"vocabulary/internal/models"
+	"vocabulary/internal/wordlist"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 每個單字最多保存的定義數量
+const maxDefinitions = 5
+
 type DictionaryResponse struct {
 	Word       string `json:"word"`
 	Definition string `json:"definition"`
@@ -49,6 +59,11 @@ func ShowVocabulary(c *gin.Context) {
 					},
 				},
 			},
+			"levels":    wordlist.Levels,
+			"wordLists": wordlist.ListNames(),
+			"level":     "",
+			"list":      "",
+			"sort":      "",
 		})
 		return
 	}
@@ -59,11 +74,24 @@ func ShowVocabulary(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching vocabularies"})
 		return
 	}
+
+	// 依等級、單字表篩選並排序
+	level := c.Query("level")
+	list := c.Query("list")
+	sortBy := c.Query("sort")
+	vocabularies = filterVocabularies(vocabularies, level, list)
+	sortVocabularies(vocabularies, sortBy)
+
 	log.Println("Rendering vocabulary page") // 應該在 console 看到這行
 	c.HTML(http.StatusOK, "list.html", gin.H{
 		"title":           "My Vocabulary",
 		"IsAuthenticated": true,
 		"vocabularies":    vocabularies,
+		"levels":          wordlist.Levels,
+		"wordLists":       wordlist.ListNames(),
+		"level":           level,
+		"list":            list,
+		"sort":            sortBy,
 	})
 }
 
@@ -119,26 +147,154 @@ func LookupWord(c *gin.Context) {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"word":        existingWord.Word,
-			"definitions": definitions,
-			"exists":      true,
-			"tested":      existingWord.Tested,
+			"word":           existingWord.Word,
+			"definitions":    definitions,
+			"exists":         true,
+			"tested":         existingWord.Tested,
+			"frequency_rank": existingWord.FrequencyRank,
+			"cefr_level":     existingWord.CEFRLevel,
 		})
 		return
 	}
 
 	// 如果單字不存在，則查詢 Dictionary API
-	url := fmt.Sprintf("https://api.dictionaryapi.dev/api/v2/entries/en/%s", url.QueryEscape(word))
-	resp, err := http.Get(url)
-	if err != nil {
+	definitions, err := lookupDictionary(word)
+	switch {
+	case errors.Is(err, errWordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Word not found"})
+		return
+	case errors.Is(err, errNoDefinitions):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No definitions found"})
+		return
+	case err != nil:
+		log.Println("Error looking up word:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lookup word"})
 		return
 	}
+
+	entry := wordlist.Lookup(word)
+	c.JSON(http.StatusOK, gin.H{
+		"word":           word,
+		"definitions":    definitions,
+		"exists":         false,
+		"frequency_rank": entry.Rank,
+		"cefr_level":     entry.Level,
+	})
+}
+
+var (
+	errWordNotFound  = errors.New("word not found")
+	errNoDefinitions = errors.New("no definitions found")
+)
+
+var dictionaryClient = &http.Client{Timeout: 10 * time.Second}
+
+// 字典快取的有效期；查無此字的結果較快過期，以便字典日後補上
+const (
+	dictionaryCacheTTL         = 30 * 24 * time.Hour
+	dictionaryNotFoundCacheTTL = 24 * time.Hour
+	// 快取最多保留的單字數，超過時刪除最少使用的項目
+	dictionaryCacheMaxEntries = 50000
+)
+
+// dictionaryKeyPattern 只有看起來像英文單字或片語的查詢才會寫入快取，避免任意輸入塞滿快取表
+var dictionaryKeyPattern = regexp.MustCompile(`^[a-z]+(?:[ '-][a-z]+)*$`)
+
+// 快取命中次數先累計在記憶體中，由 StartDictionaryCache 定期批次寫入
+var (
+	dictionaryHitsMu sync.Mutex
+	dictionaryHits   = map[string]int{}
+)
+
+// StartDictionaryCache 每隔 interval 寫入累計的命中次數，並刪除過期與超出上限的快取
+func StartDictionaryCache(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			maintainDictionaryCache(now)
+		}
+	}()
+}
+
+func maintainDictionaryCache(now time.Time) {
+	dictionaryHitsMu.Lock()
+	hits := dictionaryHits
+	dictionaryHits = map[string]int{}
+	dictionaryHitsMu.Unlock()
+
+	if err := models.AddDictionaryHits(db, hits); err != nil {
+		log.Println("Error saving dictionary cache hits:", err)
+	}
+	if _, err := models.ExpireDictionaryCache(db, now.Add(-dictionaryCacheTTL), now.Add(-dictionaryNotFoundCacheTTL)); err != nil {
+		log.Println("Error expiring dictionary cache:", err)
+	}
+	if _, err := models.TrimDictionaryCache(db, dictionaryCacheMaxEntries); err != nil {
+		log.Println("Error trimming dictionary cache:", err)
+	}
+}
+
+func countDictionaryHit(key string) {
+	dictionaryHitsMu.Lock()
+	dictionaryHits[key]++
+	dictionaryHitsMu.Unlock()
+}
+
+// lookupDictionary 查詢單字定義，優先使用資料庫中的快取
+func lookupDictionary(word string) ([]map[string]string, error) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		return fetchDictionary(word)
+	}
+
+	key := strings.ToLower(strings.TrimSpace(word))
+	if len(key) > 100 || !dictionaryKeyPattern.MatchString(key) {
+		return fetchDictionary(word)
+	}
+
+	now := time.Now()
+	cached, err := models.GetCachedDefinitions(db, key, now.Add(-dictionaryCacheTTL))
+	if err != nil {
+		log.Println("Error reading dictionary cache:", err)
+	}
+	if cached != nil {
+		if !cached.NotFound {
+			countDictionaryHit(key)
+			return cached.Definitions, nil
+		}
+		if cached.FetchedAt.After(now.Add(-dictionaryNotFoundCacheTTL)) {
+			countDictionaryHit(key)
+			return nil, errWordNotFound
+		}
+	}
+
+	definitions, err := fetchDictionary(word)
+	switch {
+	case err == nil:
+		err = models.CacheDefinitions(db, key, definitions, false)
+		if err != nil {
+			log.Println("Error writing dictionary cache:", err)
+		}
+		return definitions, nil
+	case errors.Is(err, errWordNotFound) || errors.Is(err, errNoDefinitions):
+		if cacheErr := models.CacheDefinitions(db, key, nil, true); cacheErr != nil {
+			log.Println("Error writing dictionary cache:", cacheErr)
+		}
+	}
+	return nil, err
+}
+
+// fetchDictionary 透過 Dictionary API 查詢單字定義
+func fetchDictionary(word string) ([]map[string]string, error) {
+	url := fmt.Sprintf("https://api.dictionaryapi.dev/api/v2/entries/en/%s", url.QueryEscape(word))
+	resp, err := dictionaryClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == 404 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Word not found"})
-		return
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errWordNotFound
 	}
 
 	var result []struct {
@@ -152,13 +308,11 @@ func LookupWord(c *gin.Context) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse dictionary response"})
-		return
+		return nil, fmt.Errorf("failed to parse dictionary response: %w", err)
 	}
 
 	if len(result) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No definitions found"})
-		return
+		return nil, errNoDefinitions
 	}
 
 	// 整理定義
@@ -178,11 +332,97 @@ func LookupWord(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"word":        word,
-		"definitions": definitions,
-		"exists":      false,
-	})
+	return definitions, nil
+}
+
+// filterVocabularies 保留符合 CEFR 等級與單字表的單字，空字串表示不篩選
+func filterVocabularies(vocabularies []models.Vocabulary, level, list string) []models.Vocabulary {
+	if level == "" && list == "" {
+		return vocabularies
+	}
+
+	var filtered []models.Vocabulary
+	for _, v := range vocabularies {
+		if level != "" && v.CEFRLevel != level {
+			continue
+		}
+		if list != "" && !containsString(v.WordLists, list) {
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+	return filtered
+}
+
+// sortVocabularies 依 frequency（常用優先）、level（簡單優先）或 word 排序，其他值維持新增時間順序
+func sortVocabularies(vocabularies []models.Vocabulary, by string) {
+	switch by {
+	case "frequency":
+		sort.SliceStable(vocabularies, func(i, j int) bool {
+			return rankOrder(vocabularies[i].FrequencyRank) < rankOrder(vocabularies[j].FrequencyRank)
+		})
+	case "level":
+		sort.SliceStable(vocabularies, func(i, j int) bool {
+			return levelOrder(vocabularies[i].CEFRLevel) < levelOrder(vocabularies[j].CEFRLevel)
+		})
+	case "word":
+		sort.SliceStable(vocabularies, func(i, j int) bool {
+			return strings.ToLower(vocabularies[i].Word) < strings.ToLower(vocabularies[j].Word)
+		})
+	}
+}
+
+// 不在頻率表中的單字排在最後
+func rankOrder(rank int) int {
+	if rank == 0 {
+		return math.MaxInt32
+	}
+	return rank
+}
+
+// 未知等級的單字排在最後
+func levelOrder(level string) int {
+	if i := wordlist.LevelIndex(level); i >= 0 {
+		return i
+	}
+	return len(wordlist.Levels)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// toVocabularyDefinitions 將API格式的定義轉換為資料庫模型，最多保留 maxDefinitions 個
+func toVocabularyDefinitions(definitions []map[string]string) []models.VocabularyDefinition {
+	// 限制定義數量最多為 5 個
+	if len(definitions) > maxDefinitions {
+		definitions = definitions[:maxDefinitions]
+	}
+
+	var vocabDefinitions []models.VocabularyDefinition
+	for _, def := range definitions {
+		// 處理例句中的引號
+		example := def["example"]
+		if example != "" {
+			// 移除開頭和結尾的引號（如果有的話）
+			example = strings.Trim(example, "\"")
+			// 將內部引號轉換為 HTML 實體
+			example = strings.ReplaceAll(example, "\"", "&quot;")
+		}
+
+		vocabDef := models.VocabularyDefinition{
+			PartOfSpeech: def["partOfSpeech"],
+			Definition:   def["definition"],
+			Example:      example,
+		}
+		vocabDefinitions = append(vocabDefinitions, vocabDef)
+	}
+	return vocabDefinitions
 }
 
 func SaveWord(c *gin.Context) {
@@ -223,11 +463,6 @@ func SaveWord(c *gin.Context) {
 		return
 	}
 
-	// 限制定義數量最多為 5 個
-	if len(definitions) > 5 {
-		definitions = definitions[:5]
-	}
-
 	// 檢查單字是否已存在
 	existingWord, err := models.GetByWord(db, userID.(int64), word)
 	if err != nil {
@@ -243,28 +478,47 @@ func SaveWord(c *gin.Context) {
 		return
 	}
 
-	// 轉換定義格式
-	var vocabDefinitions []models.VocabularyDefinition
-	for _, def := range definitions {
-		// 處理例句中的引號
-		example := def["example"]
-		if example != "" {
-			// 移除開頭和結尾的引號（如果有的話）
-			example = strings.Trim(example, "\"")
-			// 將內部引號轉換為 HTML 實體
-			example = strings.ReplaceAll(example, "\"", "&quot;")
+	// 來源文章與句子（選填）
+	var articleID int64
+	if articleIDStr := c.PostForm("article_id"); articleIDStr != "" {
+		articleID, err = strconv.ParseInt(articleIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID format"})
+			return
+		}
+		if !checkArticleOwner(c, userID.(int64), articleID) {
+			return
 		}
+	}
+	context := strings.TrimSpace(c.PostForm("context"))
 
-		vocabDef := models.VocabularyDefinition{
-			PartOfSpeech: def["partOfSpeech"],
-			Definition:   def["definition"],
-			Example:      example,
+	// 牌組與標籤（選填）
+	var deckID int64
+	if deckIDStr := c.PostForm("deck_id"); deckIDStr != "" {
+		deckID, err = strconv.ParseInt(deckIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID format"})
+			return
+		}
+		if !checkDeckOwner(c, userID.(int64), deckID) {
+			return
 		}
-		vocabDefinitions = append(vocabDefinitions, vocabDef)
 	}
+	tags := models.NormalizeTags(strings.Split(c.PostForm("tags"), ","))
+
+	// 轉換定義格式
+	vocabDefinitions := toVocabularyDefinitions(definitions)
 
 	// 保存單字和定義
-	if err := models.Create(db, userID.(int64), word, vocabDefinitions); err != nil {
+	vocabulary := models.Vocabulary{
+		Word:        word,
+		ArticleID:   articleID,
+		Context:     context,
+		DeckID:      deckID,
+		Tags:        tags,
+		Definitions: vocabDefinitions,
+	}
+	if err := models.CreateVocabulary(db, userID.(int64), vocabulary); err != nil {
 		log.Println("Error saving word:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving word"})
 		return
@@ -372,6 +626,8 @@ func GetVocabulary(c *gin.Context) {
 		"id":          vocabulary.ID,
 		"word":        vocabulary.Word,
 		"definitions": definitions,
+		"tags":        vocabulary.Tags,
+		"deck_id":     vocabulary.DeckID,
 	})
 }
 
@@ -400,6 +656,8 @@ func UpdateVocabulary(c *gin.Context) {
 	var data struct {
 		Word        string                   `json:"word"`
 		Definitions []map[string]interface{} `json:"definitions"`
+		Tags        []string                 `json:"tags"`
+		DeckID      *int64                   `json:"deck_id"`
 	}
 
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -420,6 +678,10 @@ func UpdateVocabulary(c *gin.Context) {
 		return
 	}
 
+	if data.DeckID != nil && *data.DeckID != 0 && !checkDeckOwner(c, userID.(int64), *data.DeckID) {
+		return
+	}
+
 	// 更新單字信息
 	vocabulary.Word = data.Word
 
@@ -452,6 +714,20 @@ func UpdateVocabulary(c *gin.Context) {
 		return
 	}
 
+	// 更新標籤與牌組（有提供時）
+	if data.Tags != nil {
+		if err := vocabulary.SetTags(db, data.Tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tags"})
+			return
+		}
+	}
+	if data.DeckID != nil {
+		if err := vocabulary.SetDeck(db, *data.DeckID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating deck"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Word updated successfully",
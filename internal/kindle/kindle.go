@@ -0,0 +1,87 @@
+// Package kindle reads lookups from a Kindle Vocabulary Builder database (vocab.db).
+package kindle
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrNotVocabularyBuilder is returned when the file is not a Vocabulary Builder database
+var ErrNotVocabularyBuilder = errors.New("file is not a Kindle vocab.db")
+
+// Lookup is one word looked up on the Kindle
+type Lookup struct {
+	Word      string // 查詢時書中的字形
+	Stem      string // Kindle 提供的原形
+	Language  string
+	Usage     string // 查詢時所在的句子
+	BookTitle string
+	Authors   string
+	LookedUp  time.Time
+}
+
+// Read copies the uploaded database to a temporary file and returns its lookups, oldest first
+func Read(r io.Reader) ([]Lookup, error) {
+	dir, err := os.MkdirTemp("", "kindle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "vocab.db")
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	vocab, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, err
+	}
+	defer vocab.Close()
+
+	rows, err := vocab.Query(`
+		SELECT COALESCE(w.word, ''), COALESCE(w.stem, ''), COALESCE(w.lang, ''), COALESCE(l.usage, ''),
+			COALESCE(b.title, ''), COALESCE(b.authors, ''), COALESCE(l.timestamp, 0)
+		FROM LOOKUPS l
+		JOIN WORDS w ON w.id = l.word_key
+		LEFT JOIN BOOK_INFO b ON b.id = l.book_key
+		ORDER BY l.timestamp
+	`)
+	if err != nil {
+		// 缺少資料表時 SQLite 會回報錯誤，檔案不是 SQLite 時亦同
+		return nil, ErrNotVocabularyBuilder
+	}
+	defer rows.Close()
+
+	var lookups []Lookup
+	for rows.Next() {
+		var l Lookup
+		var timestamp int64
+		if err := rows.Scan(&l.Word, &l.Stem, &l.Language, &l.Usage, &l.BookTitle, &l.Authors, &timestamp); err != nil {
+			return nil, err
+		}
+		l.Word = strings.TrimSpace(l.Word)
+		l.Stem = strings.TrimSpace(l.Stem)
+		l.Usage = strings.TrimSpace(l.Usage)
+		// Kindle 以毫秒記錄查詢時間
+		l.LookedUp = time.UnixMilli(timestamp)
+		lookups = append(lookups, l)
+	}
+
+	return lookups, rows.Err()
+}
@@ -0,0 +1,87 @@
+package kindle
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildVocabDB creates a minimal Vocabulary Builder database and returns its contents
+func buildVocabDB(t *testing.T) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vocab.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	statements := []string{
+		`CREATE TABLE WORDS (id TEXT PRIMARY KEY, word TEXT, stem TEXT, lang TEXT)`,
+		`CREATE TABLE BOOK_INFO (id TEXT PRIMARY KEY, title TEXT, authors TEXT)`,
+		`CREATE TABLE LOOKUPS (id TEXT PRIMARY KEY, word_key TEXT, book_key TEXT, usage TEXT, timestamp INTEGER)`,
+		`INSERT INTO WORDS VALUES ('en:running', 'running', 'run', 'en'), ('en:quaint', 'quaint', 'quaint', 'en')`,
+		`INSERT INTO BOOK_INFO VALUES ('b1', 'Emma', 'Jane Austen')`,
+		`INSERT INTO LOOKUPS VALUES
+			('l2', 'en:quaint', 'b1', ' A quaint old house. ', 1700000002000),
+			('l1', 'en:running', NULL, 'She was running late.', 1700000001000)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRead(t *testing.T) {
+	lookups, err := Read(bytes.NewReader(buildVocabDB(t)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(lookups) != 2 {
+		t.Fatalf("got %d lookups, want 2", len(lookups))
+	}
+
+	// 依查詢時間排序，最早的在前
+	first, second := lookups[0], lookups[1]
+	if first.Word != "running" || first.Stem != "run" || first.BookTitle != "" {
+		t.Errorf("first lookup = %+v", first)
+	}
+	if !first.LookedUp.Equal(time.UnixMilli(1700000001000)) {
+		t.Errorf("LookedUp = %v", first.LookedUp)
+	}
+	if second.Usage != "A quaint old house." || second.BookTitle != "Emma" || second.Authors != "Jane Austen" {
+		t.Errorf("second lookup = %+v", second)
+	}
+}
+
+func TestReadRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not sqlite", []byte("plain text, not a database")},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(tt.data))
+			if !errors.Is(err, ErrNotVocabularyBuilder) {
+				t.Errorf("Read error = %v, want ErrNotVocabularyBuilder", err)
+			}
+		})
+	}
+}
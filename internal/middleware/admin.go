@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"vocabulary/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleRequired 僅允許具有指定角色之一的使用者（管理員擁有所有角色），需放在 AuthRequired 之後
+func RoleRequired(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			forbidden(c)
+			return
+		}
+
+		// 檢查是否為測試環境
+		if os.Getenv("SKIP_DB") == "true" {
+			c.Set("user_role", models.RoleAdmin)
+			c.Next()
+			return
+		}
+
+		// 每次都讀取資料庫，角色變更或停用帳號後立即生效
+		user, err := models.GetUserByID(db, userID.(int64))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking permissions"})
+			c.Abort()
+			return
+		}
+		if user == nil || user.DisabledAt != nil || !user.HasRole(roles...) {
+			forbidden(c)
+			return
+		}
+		c.Set("user_role", user.Role)
+		c.Next()
+	}
+}
+
+// AdminRequired 僅允許管理員，需放在 AuthRequired 之後
+func AdminRequired() gin.HandlerFunc {
+	return RoleRequired(models.RoleAdmin)
+}
+
+func forbidden(c *gin.Context) {
+	if isAPIRequest(c.Request.URL.Path) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	} else {
+		c.String(http.StatusForbidden, "Forbidden")
+	}
+	c.Abort()
+}
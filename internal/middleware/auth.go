@@ -1,55 +1,168 @@
 package middleware
 
 import (
+	"database/sql"
 	"net/http"
 	"os"
 	"strings"
+	"vocabulary/internal/auth"
+	"vocabulary/internal/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
+var db *sql.DB
+
+// Init 設定檢查工作階段是否已撤銷所需的資料庫連線
+func Init(database *sql.DB) {
+	db = database
+}
+
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("token")
-		if err != nil {
-			handleUnauthorized(c)
+		// 帶有 Authorization: Bearer 的請求只以 API token 驗證，不會退回使用 cookie
+		if token := auth.BearerToken(c.GetHeader("Authorization")); token != "" {
+			authenticateAPIToken(c, token)
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := authenticate(c)
+		if err != nil {
 			handleUnauthorized(c)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			handleUnauthorized(c)
-			return
-		}
+		c.Set("user_id", claims.UserID)
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	}
+}
 
-		// Convert float64 to int64
-		userIDFloat, ok := claims["user_id"].(float64)
-		if !ok {
-			handleUnauthorized(c)
-			return
-		}
-		userID := int64(userIDFloat)
+// authenticateAPIToken 驗證 API token，並確認其權限涵蓋目前的路由
+func authenticateAPIToken(c *gin.Context, token string) {
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API tokens are not available in the test environment"})
+		return
+	}
 
-		c.Set("user_id", userID)
-		c.Next()
+	claims, err := auth.AuthenticateAPIToken(db, token)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+		return
+	}
+
+	scope, ok := tokenScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an API token"})
+		return
+	}
+	if !claims.HasScope(scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API token is missing the " + scope + " scope"})
+		return
 	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("api_token_id", claims.TokenID)
+	c.Next()
+}
+
+// API token 可以呼叫的路由與所需的權限；未列出的路由（例如帳號設定）只接受登入的瀏覽器
+var tokenScopes = map[string]string{
+	"POST /vocabulary/lookup":        auth.ScopeVocabularyRead,
+	"GET /vocabulary/export":         auth.ScopeVocabularyRead,
+	"GET /vocabulary/export/anki":    auth.ScopeVocabularyRead,
+	"GET /vocabulary/:id":            auth.ScopeVocabularyRead,
+	"GET /tags":                      auth.ScopeVocabularyRead,
+	"GET /decks":                     auth.ScopeVocabularyRead,
+	"POST /vocabulary/save":          auth.ScopeVocabularyWrite,
+	"POST /vocabulary/bulk-save":     auth.ScopeVocabularyWrite,
+	"POST /vocabulary/import":        auth.ScopeVocabularyWrite,
+	"POST /vocabulary/import/anki":   auth.ScopeVocabularyWrite,
+	"POST /vocabulary/import/kindle": auth.ScopeVocabularyWrite,
+	"PUT /vocabulary/:id":            auth.ScopeVocabularyWrite,
+	"DELETE /vocabulary/:id":         auth.ScopeVocabularyWrite,
+	"POST /tags":                     auth.ScopeVocabularyWrite,
+	"PUT /tags/:id":                  auth.ScopeVocabularyWrite,
+	"DELETE /tags/:id":               auth.ScopeVocabularyWrite,
+	"POST /decks":                    auth.ScopeVocabularyWrite,
+	"PUT /decks/:id":                 auth.ScopeVocabularyWrite,
+	"DELETE /decks/:id":              auth.ScopeVocabularyWrite,
+
+	"GET /flashcards/test":                 auth.ScopeReviewsRead,
+	"GET /flashcards/quiz":                 auth.ScopeReviewsRead,
+	"GET /flashcards/sessions/active":      auth.ScopeReviewsRead,
+	"GET /flashcards/sessions/:id":         auth.ScopeReviewsRead,
+	"GET /flashcards/sessions/:id/summary": auth.ScopeReviewsRead,
+	"GET /flashcards/assignments":          auth.ScopeReviewsRead,
+	"GET /scheduler/words":                 auth.ScopeReviewsRead,
+	"GET /scheduler/boxes":                 auth.ScopeReviewsRead,
+	"GET /stats/data":                      auth.ScopeReviewsRead,
+	"GET /goals":                           auth.ScopeReviewsRead,
+	"POST /flashcards/result":              auth.ScopeReviewsWrite,
+	"POST /flashcards/quiz/answer":         auth.ScopeReviewsWrite,
+	"POST /flashcards/sessions":            auth.ScopeReviewsWrite,
+	"POST /flashcards/sessions/:id/answer": auth.ScopeReviewsWrite,
+	"POST /flashcards/sessions/:id/finish": auth.ScopeReviewsWrite,
+	"POST /goals/reading":                  auth.ScopeReviewsWrite,
+}
+
+// authenticate 驗證 access token 並確認其工作階段未被撤銷；token 過期時以 refresh token 換發
+func authenticate(c *gin.Context) (*auth.Claims, error) {
+	tokenString, _ := c.Cookie(auth.AccessCookie)
+	claims, err := auth.ParseAccessToken(tokenString)
+
+	// 檢查是否為測試環境
+	if os.Getenv("SKIP_DB") == "true" {
+		return claims, err
+	}
+
+	if err != nil {
+		return auth.RefreshSession(db, c)
+	}
+	if claims.SessionID == 0 {
+		return nil, auth.ErrInvalidToken
+	}
+	active, err := models.IsSessionActive(db, claims.UserID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		auth.ClearCookies(c)
+		return nil, models.ErrSessionInvalid
+	}
+	return claims, nil
+}
+
+// 回傳 JSON 而非重新導向的路徑前綴
+var apiPrefixes = []string{
+	"/vocabulary/",
+	"/news/",
+	"/flashcards/",
+	"/shared-decks/",
+	"/admin/",
+	"/account/",
+	"/classes/",
+	"/tags",
+	"/decks",
+	"/scheduler",
+	"/stats/",
+	"/goals",
+	"/reminders",
+}
+
+func isAPIRequest(path string) bool {
+	for _, prefix := range apiPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 func handleUnauthorized(c *gin.Context) {
 	// 檢查是否為 API 請求
-	if strings.HasPrefix(c.Request.URL.Path, "/vocabulary/") ||
-		strings.HasPrefix(c.Request.URL.Path, "/news/") ||
-		strings.HasPrefix(c.Request.URL.Path, "/flashcards/") {
+	if isAPIRequest(c.Request.URL.Path) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 	} else {
 		c.Redirect(http.StatusFound, "/login")
@@ -59,18 +172,14 @@ func handleUnauthorized(c *gin.Context) {
 // 檢查使用者是否登入
 func IsAuthenticated() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("token")
+		tokenString, err := c.Cookie(auth.AccessCookie)
 		if err != nil {
 			c.Set("authenticated", false)
 			c.Next()
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
+		if _, err := auth.ParseAccessToken(tokenString); err != nil {
 			c.Set("authenticated", false)
 			c.Next()
 			return
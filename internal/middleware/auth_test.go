@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+	"vocabulary/internal/auth"
+)
+
+func TestTokenScopes(t *testing.T) {
+	methods := map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+	for route, scope := range tokenScopes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || !methods[method] || !strings.HasPrefix(path, "/") {
+			t.Errorf("route %q is not of the form \"METHOD /path\"", route)
+		}
+		if !auth.ValidScope(scope) {
+			t.Errorf("route %q requires unknown scope %q", route, scope)
+		}
+		// 讀取權限不能用來修改資料
+		if method != "GET" && strings.HasSuffix(scope, ":read") && route != "POST /vocabulary/lookup" {
+			t.Errorf("route %q changes data but only requires %q", route, scope)
+		}
+	}
+}
+
+func TestTokenScopesExcludeAccountRoutes(t *testing.T) {
+	// 帳號設定、管理與 token 本身只接受登入的瀏覽器，外洩的 token 無法用來擴大權限
+	forbidden := []string{"/account", "/admin", "/logout", "/classes"}
+	for route := range tokenScopes {
+		_, path, _ := strings.Cut(route, " ")
+		for _, prefix := range forbidden {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				t.Errorf("route %q must not be available to API tokens", route)
+			}
+		}
+	}
+}
+
+func TestTokenScopesLookup(t *testing.T) {
+	tests := []struct {
+		route     string
+		wantScope string
+		wantOK    bool
+	}{
+		{"GET /vocabulary/:id", auth.ScopeVocabularyRead, true},
+		{"DELETE /vocabulary/:id", auth.ScopeVocabularyWrite, true},
+		{"GET /stats/data", auth.ScopeReviewsRead, true},
+		{"POST /flashcards/sessions/:id/answer", auth.ScopeReviewsWrite, true},
+		{"POST /account/tokens", "", false},
+		{"POST /account/password", "", false},
+		{"GET /admin/users", "", false},
+		// 以實際的 URL 而非路由樣式查詢時不會比對成功
+		{"GET /vocabulary/1", "", false},
+	}
+
+	for _, tt := range tests {
+		scope, ok := tokenScopes[tt.route]
+		if scope != tt.wantScope || ok != tt.wantOK {
+			t.Errorf("tokenScopes[%q] = %q, %v, want %q, %v", tt.route, scope, ok, tt.wantScope, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"vocabulary/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csrfCookieMaxAge 讓 token 與登入工作階段一樣長
+var csrfCookieMaxAge = int(auth.RefreshTokenTTL.Seconds())
+
+// CSRF protects state-changing requests with a signed double-submit token: every response carries the token in
+// a cookie that scripts can read, and POST, PUT, PATCH and DELETE requests must echo it in the X-CSRF-Token
+// header or the csrf_token form field. Another site can make the browser send the cookie but cannot read it.
+// Requests authenticated with an API token do not use cookies and are exempt.
+func CSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, _ := c.Cookie(auth.CSRFCookie)
+		valid := auth.ValidCSRFToken(token)
+		if !valid {
+			var err error
+			token, err = auth.NewCSRFToken()
+			if err != nil {
+				log.Println("Error generating CSRF token:", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			auth.SetCookie(c, auth.CSRFCookie, token, csrfCookieMaxAge, "/", false)
+		}
+		c.Set("csrf_token", token)
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if auth.BearerToken(c.GetHeader("Authorization")) != "" {
+			c.Next()
+			return
+		}
+
+		submitted := c.GetHeader(auth.CSRFHeader)
+		if submitted == "" {
+			submitted = c.PostForm(auth.CSRFField)
+		}
+		if !valid || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			if isAPIRequest(c.Request.URL.Path) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid or missing CSRF token"})
+			} else {
+				c.String(http.StatusForbidden, "Invalid or missing CSRF token. Please reload the page and try again.")
+				c.Abort()
+			}
+			return
+		}
+		c.Next()
+	}
+}
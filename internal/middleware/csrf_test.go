@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"vocabulary/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func csrfTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CSRF())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	r.GET("/news", ok)
+	r.POST("/news/fetch", ok)
+	r.POST("/login", ok)
+	return r
+}
+
+func TestCSRF(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	r := csrfTestRouter()
+
+	token, err := auth.NewCSRFToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := auth.NewCSRFToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := "forged.signature"
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		cookie     string
+		header     string
+		form       string
+		bearer     bool
+		wantStatus int
+	}{
+		{"safe method without token", http.MethodGet, "/news", "", "", "", false, http.StatusOK},
+		{"header matches cookie", http.MethodPost, "/news/fetch", token, token, "", false, http.StatusOK},
+		{"form field matches cookie", http.MethodPost, "/login", token, "", token, false, http.StatusOK},
+		{"no cookie", http.MethodPost, "/news/fetch", "", token, "", false, http.StatusForbidden},
+		{"nothing submitted", http.MethodPost, "/news/fetch", token, "", "", false, http.StatusForbidden},
+		{"different valid token submitted", http.MethodPost, "/news/fetch", token, other, "", false, http.StatusForbidden},
+		// 其他網站或子網域種下的 cookie 沒有伺服器簽章
+		{"unsigned cookie echoed back", http.MethodPost, "/news/fetch", forged, forged, "", false, http.StatusForbidden},
+		{"API token requests are exempt", http.MethodPost, "/news/fetch", "", "", "", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{auth.CSRFField: {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: auth.CSRFCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(auth.CSRFHeader, tt.header)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer vocab_test")
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesCookie(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	r := csrfTestRouter()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news", nil))
+	var issued *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == auth.CSRFCookie {
+			issued = cookie
+		}
+	}
+	if issued == nil {
+		t.Fatal("no CSRF cookie issued")
+	}
+	if !auth.ValidCSRFToken(issued.Value) {
+		t.Errorf("issued token %q is not valid", issued.Value)
+	}
+	if issued.HttpOnly {
+		t.Error("CSRF cookie must be readable by scripts")
+	}
+
+	// 已有有效的 token 時不重新簽發
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.AddCookie(issued)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == auth.CSRFCookie {
+			t.Errorf("token reissued although the existing one is valid")
+		}
+	}
+}
+
+func TestCSRFRejection(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	r := csrfTestRouter()
+
+	// API 路徑回傳 JSON，頁面表單回傳文字說明
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/news/fetch", nil))
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+		t.Errorf("API rejection body = %q, want a JSON error", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	if !strings.Contains(w.Body.String(), "reload the page") {
+		t.Errorf("page rejection body = %q", w.Body.String())
+	}
+}
@@ -0,0 +1,135 @@
+// Package migrate brings the database up to the current schema when the server starts.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 重複執行 ALTER 時 MySQL 回傳的錯誤，代表變更已經套用過
+var alreadyApplied = map[uint16]bool{
+	1050: true, // ER_TABLE_EXISTS_ERROR
+	1060: true, // ER_DUP_FIELDNAME
+	1061: true, // ER_DUP_KEYNAME
+	1091: true, // ER_CANT_DROP_FIELD_OR_KEY
+}
+
+// Run applies the schema in dir to the database:
+//   - init.sql is run on every start; all of its statements are CREATE TABLE IF NOT EXISTS, so tables added
+//     since the database was created are created and existing ones are left alone
+//   - migrations/*.sql alter tables that already existed, in file name order, and are recorded in
+//     schema_migrations so that each runs once. Statements whose change is already present, for example a
+//     column that init.sql created, are skipped, so a migration may safely run against a new database
+func Run(db *sql.DB, dir string) error {
+	if err := runFile(db, filepath.Join(dir, "init.sql")); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version VARCHAR(255) PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "migrations", "*.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		version := strings.TrimSuffix(filepath.Base(file), ".sql")
+		var applied int
+		err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+		if err := runFile(db, file); err != nil {
+			return err
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
+			return err
+		}
+		log.Println("Applied migration", version)
+	}
+	return nil
+}
+
+// Once runs fn unless version is already recorded in schema_migrations, then records it; it is for one-time
+// data changes that need settings from the environment and so cannot be written as a migration file. Run
+// must have created schema_migrations first
+func Once(db *sql.DB, version string, fn func() error) error {
+	var applied int
+	err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&applied)
+	if err != nil || applied > 0 {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
+		return err
+	}
+	log.Println("Applied migration", version)
+	return nil
+}
+
+// runFile executes the statements of a SQL file one at a time
+func runFile(db *sql.DB, file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range statements(string(content)) {
+		// 資料庫由連線字串決定，忽略 init.sql 中給 docker 初始化用的 CREATE DATABASE 與 USE
+		upper := strings.ToUpper(stmt)
+		if strings.HasPrefix(upper, "CREATE DATABASE") || strings.HasPrefix(upper, "USE ") {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && alreadyApplied[mysqlErr.Number] {
+				continue
+			}
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+	}
+	return nil
+}
+
+// statements splits a SQL file on semicolons at the end of a line, dropping -- comment lines
+func statements(content string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			stmts = append(stmts, stmt)
+			current.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+	"vocabulary/internal/ratelimit"
+)
+
+// ScheduleDeletion marks the account for permanent deletion at the given time and signs the user out everywhere.
+// It returns sql.ErrNoRows if a deletion is already scheduled.
+func ScheduleDeletion(db *sql.DB, userID int64, at time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET deletion_scheduled_at = ? WHERE id = ? AND deletion_scheduled_at IS NULL
+	`, at, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if err := revokeAccess(tx, userID, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancelDeletion keeps an account whose deletion was scheduled and reports whether there was one to cancel
+func CancelDeletion(db *sql.DB, userID int64) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE users SET deletion_scheduled_at = NULL WHERE id = ? AND deletion_scheduled_at IS NOT NULL
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetDueDeletions returns the users whose deletion grace period has ended
+func GetDueDeletions(db *sql.DB, now time.Time) ([]int64, error) {
+	rows, err := db.Query(`
+		SELECT id FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ? ORDER BY id
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// userDataDeletes removes everything a user owns, children before parents because several foreign keys to
+// users and vocabularies have no ON DELETE CASCADE. Each statement takes the user's ID as its only argument.
+var userDataDeletes = []string{
+	"DELETE FROM test_results WHERE user_id = ?",
+	"DELETE FROM quiz_questions WHERE user_id = ?",
+	"DELETE FROM review_sessions WHERE user_id = ?",
+	"DELETE FROM vocabulary_schedules WHERE user_id = ?",
+	"DELETE FROM vocabularies WHERE user_id = ?",
+	"DELETE FROM tags WHERE user_id = ?",
+	"DELETE FROM decks WHERE user_id = ?",
+	"DELETE FROM articles WHERE user_id = ?",
+	"DELETE FROM scheduler_settings WHERE user_id = ?",
+	"DELETE FROM user_preferences WHERE user_id = ?",
+	"DELETE FROM daily_activity WHERE user_id = ?",
+	"DELETE FROM user_streaks WHERE user_id = ?",
+	"DELETE FROM user_sessions WHERE user_id = ?",
+	"DELETE FROM password_resets WHERE user_id = ?",
+	"DELETE FROM user_totp WHERE user_id = ?",
+	"DELETE FROM user_recovery_codes WHERE user_id = ?",
+	"DELETE FROM user_identities WHERE user_id = ?",
+	"DELETE FROM api_tokens WHERE user_id = ?",
+	"DELETE FROM class_members WHERE user_id = ?",
+	// 老師的班級連同指派一起刪除，學生已加入的單字會保留
+	"DELETE FROM classes WHERE teacher_id = ?",
+}
+
+// DeleteAccount permanently deletes a user whose deletion is due, in a single transaction. The user's
+// entries in the audit log are kept for security statistics but stripped of the user ID, name, IP and
+// browser. It returns false without deleting anything if the deletion was cancelled in the meantime.
+func DeleteAccount(db *sql.DB, userID int64, now time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 鎖定使用者，避免與同時進行的登入（取消刪除）衝突
+	var username string
+	err = tx.QueryRow(`
+		SELECT username FROM users
+		WHERE id = ? AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?
+		FOR UPDATE
+	`, userID, now).Scan(&username)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, query := range userDataDeletes {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return false, err
+		}
+	}
+
+	// 以使用者名稱記錄的失敗登入也要匿名化
+	_, err = tx.Exec(`
+		UPDATE auth_audit_log SET user_id = NULL, username = '', ip = '', user_agent = ''
+		WHERE user_id = ? OR username = ?
+	`, userID, username)
+	if err != nil {
+		return false, err
+	}
+	// 與 handlers 中帳號登入限制器的鍵相同："user:" 加上小寫使用者名稱的雜湊
+	accountKey := "user:" + ratelimit.HashKey(strings.ToLower(strings.TrimSpace(username)))
+	if _, err := tx.Exec("DELETE FROM login_throttle WHERE throttle_key = ?", accountKey); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", userID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO auth_audit_log (event, detail, created_at) VALUES (?, ?, ?)
+	`, EventAccountDeleted, "user "+strconv.FormatInt(userID, 10), now)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// UserSummary is a row in the admin user list
+type UserSummary struct {
+	User
+	Words       int
+	Reviews     int
+	LastLoginAt *time.Time
+}
+
+// ListUsers returns users whose username or email contains query, newest first, and the total number of matches
+func ListUsers(db *sql.DB, query string, limit, offset int) ([]UserSummary, int, error) {
+	like := "%" + query + "%"
+	var total int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE username LIKE ? OR email LIKE ?
+	`, like, like).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT u.id, u.username, COALESCE(u.email, ''), u.role, u.disabled_at, u.deletion_scheduled_at, u.created_at,
+			(SELECT COUNT(*) FROM vocabularies v WHERE v.user_id = u.id AND v.status = 'active'),
+			(SELECT COUNT(*) FROM test_results t WHERE t.user_id = u.id),
+			(SELECT MAX(s.created_at) FROM user_sessions s WHERE s.user_id = u.id)
+		FROM users u
+		WHERE u.username LIKE ? OR u.email LIKE ?
+		ORDER BY u.created_at DESC, u.id DESC
+		LIMIT ? OFFSET ?
+	`, like, like, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var u UserSummary
+		var disabledAt, deletionScheduledAt, lastLogin sql.NullTime
+		err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &disabledAt, &deletionScheduledAt, &u.CreatedAt,
+			&u.Words, &u.Reviews, &lastLogin)
+		if err != nil {
+			return nil, 0, err
+		}
+		if disabledAt.Valid {
+			u.DisabledAt = &disabledAt.Time
+		}
+		if deletionScheduledAt.Valid {
+			u.DeletionScheduledAt = &deletionScheduledAt.Time
+		}
+		if lastLogin.Valid {
+			u.LastLoginAt = &lastLogin.Time
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+// SetUserRole changes a user's role
+func SetUserRole(db *sql.DB, userID int64, role string) error {
+	result, err := db.Exec("UPDATE users SET role = ? WHERE id = ?", role, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// 角色沒有變化時 MySQL 回報 0 筆，需確認使用者是否存在
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PromoteUser gives the user with the given username the admin role; it is used to bootstrap the first admin
+func PromoteUser(db *sql.DB, username string) (bool, error) {
+	result, err := db.Exec("UPDATE users SET role = ? WHERE username = ? AND role <> ?", RoleAdmin, username, RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// PromoteUserIDs gives the users with the given IDs the admin role and returns how many were promoted; it
+// carries over admins listed in the former ADMIN_USER_IDS setting once, on the first start after upgrading
+func PromoteUserIDs(db *sql.DB, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := []interface{}{RoleAdmin}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, RoleAdmin)
+	result, err := db.Exec(
+		"UPDATE users SET role = ? WHERE id IN (?"+strings.Repeat(", ?", len(ids)-1)+") AND role <> ?",
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DisableUser blocks a user from signing in and revokes their sessions and API tokens
+func DisableUser(db *sql.DB, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec("UPDATE users SET disabled_at = ? WHERE id = ? AND disabled_at IS NULL", now, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if err := revokeAccess(tx, userID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeAccess signs the user out of every session and revokes their API tokens
+func revokeAccess(tx *sql.Tx, userID int64, now time.Time) error {
+	if _, err := tx.Exec("UPDATE user_sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID)
+	return err
+}
+
+// EnableUser lets a disabled user sign in again
+func EnableUser(db *sql.DB, userID int64) error {
+	result, err := db.Exec("UPDATE users SET disabled_at = NULL WHERE id = ? AND disabled_at IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SystemStats are instance-wide counts for the admin console
+type SystemStats struct {
+	Users           int
+	DisabledUsers   int
+	UsersByRole     map[string]int
+	ActiveUsers7d   int // 最近 7 天有複習紀錄的使用者
+	NewUsers7d      int
+	Vocabularies    int
+	Reviews         int
+	Reviews7d       int
+	Articles        int
+	SharedDecks     int
+	ActiveSessions  int
+	DictionaryCache int
+}
+
+// GetSystemStats counts users, content and activity across the whole instance
+func GetSystemStats(db *sql.DB, now time.Time) (*SystemStats, error) {
+	s := &SystemStats{UsersByRole: make(map[string]int)}
+	weekAgo := now.AddDate(0, 0, -7)
+	err := db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE disabled_at IS NOT NULL),
+			(SELECT COUNT(DISTINCT user_id) FROM test_results WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM users WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM vocabularies WHERE status = 'active'),
+			(SELECT COUNT(*) FROM test_results),
+			(SELECT COUNT(*) FROM test_results WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM articles),
+			(SELECT COUNT(*) FROM shared_decks),
+			(SELECT COUNT(*) FROM user_sessions WHERE revoked_at IS NULL AND expires_at > ?),
+			(SELECT COUNT(*) FROM dictionary_cache)
+	`, weekAgo, weekAgo, weekAgo, now).Scan(&s.Users, &s.DisabledUsers, &s.ActiveUsers7d, &s.NewUsers7d,
+		&s.Vocabularies, &s.Reviews, &s.Reviews7d, &s.Articles, &s.SharedDecks, &s.ActiveSessions, &s.DictionaryCache)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT role, COUNT(*) FROM users GROUP BY role")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var role string
+		var n int
+		if err := rows.Scan(&role, &n); err != nil {
+			return nil, err
+		}
+		s.UsersByRole[role] = n
+	}
+	return s, rows.Err()
+}
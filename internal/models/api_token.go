@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrAPITokenInvalid is returned for API tokens that are unknown, expired or revoked
+var ErrAPITokenInvalid = errors.New("API token is invalid or has been revoked")
+
+// APITokenTouchInterval limits how often a token's last-used time is written
+const APITokenTouchInterval = time.Minute
+
+// APIToken is a personal access token for scripts; only the SHA-256 hash of the secret is stored
+type APIToken struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string // 明文開頭幾個字元，方便使用者辨認
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	ExpiresAt  *time.Time
+}
+
+const apiTokenColumns = `id, user_id, name, prefix, token_hash, scopes, created_at, last_used_at, expires_at`
+
+// CreateAPIToken stores a new token and returns its ID
+func CreateAPIToken(db *sql.DB, t *APIToken) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO api_tokens (user_id, name, prefix, token_hash, scopes, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, t.UserID, truncate(t.Name, 100), t.Prefix, t.TokenHash, strings.Join(t.Scopes, ","), time.Now(), t.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetAPITokens returns the user's tokens that are neither revoked nor expired, newest first
+func GetAPITokens(db *sql.DB, userID int64) ([]APIToken, error) {
+	rows, err := db.Query(`
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// AuthenticateAPIToken returns the active token with the given hash and records that it was used
+func AuthenticateAPIToken(db *sql.DB, tokenHash string) (*APIToken, error) {
+	now := time.Now()
+	t, err := scanAPIToken(db.QueryRow(`
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+	`, tokenHash, now))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPITokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= APITokenTouchInterval {
+		if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, t.ID); err != nil {
+			return nil, err
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
+
+// RevokeAPIToken revokes one of the user's tokens
+func RevokeAPIToken(db *sql.DB, userID, id int64) error {
+	result, err := db.Exec(`
+		UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	t := &APIToken{}
+	var scopes string
+	var lastUsed, expires sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &t.TokenHash, &scopes, &t.CreatedAt, &lastUsed, &expires)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	return t, nil
+}
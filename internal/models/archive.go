@@ -0,0 +1,471 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ArchiveVersion is the format version written by ExportArchive; bump it when the layout changes
+const ArchiveVersion = 1
+
+var (
+	// ErrArchiveVersion is returned when restoring an archive written by a newer version
+	ErrArchiveVersion = errors.New("unsupported archive version")
+	// ErrAccountNotEmpty is returned when restoring into an account that already has data
+	ErrAccountNotEmpty = errors.New("account already has vocabulary or articles")
+)
+
+// Archive is a portable copy of everything a user owns. IDs are those of the exporting
+// server and are only used to link records inside the archive; RestoreArchive assigns new ones.
+type Archive struct {
+	Version      int                 `json:"version"`
+	ExportedAt   time.Time           `json:"exported_at"`
+	Profile      ArchiveProfile      `json:"profile"`
+	Decks        []ArchiveDeck       `json:"decks"`
+	Tags         []ArchiveTag        `json:"tags"`
+	Articles     []ArchiveArticle    `json:"articles"`
+	Vocabularies []ArchiveVocabulary `json:"vocabularies"`
+	TestResults  []ArchiveTestResult `json:"test_results"`
+}
+
+type ArchiveProfile struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ArchiveDeck struct {
+	ID        int64     `json:"id"`
+	ParentID  int64     `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ArchiveTag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ArchiveArticle struct {
+	ID         int64                   `json:"id"`
+	Title      string                  `json:"title"`
+	SourceType string                  `json:"source_type"`
+	SourceURL  string                  `json:"source_url"`
+	Content    string                  `json:"content"`
+	CreatedAt  time.Time               `json:"created_at"`
+	Chapters   []ArchiveArticleChapter `json:"chapters,omitempty"`
+}
+
+type ArchiveArticleChapter struct {
+	Position int    `json:"position"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+}
+
+type ArchiveVocabulary struct {
+	ID          int64               `json:"id"`
+	Word        string              `json:"word"`
+	Status      string              `json:"status"`
+	Tested      bool                `json:"tested"`
+	ArticleID   int64               `json:"article_id,omitempty"`
+	Context     string              `json:"context,omitempty"`
+	DeckID      int64               `json:"deck_id,omitempty"`
+	TagIDs      []int64             `json:"tag_ids,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Definitions []ArchiveDefinition `json:"definitions"`
+}
+
+type ArchiveDefinition struct {
+	PartOfSpeech string    `json:"part_of_speech"`
+	Definition   string    `json:"definition"`
+	Example      string    `json:"example"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ArchiveTestResult struct {
+	WordID    int64     `json:"word_id"`
+	Correct   bool      `json:"correct"`
+	Mode      string    `json:"mode,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreStats counts the records created by RestoreArchive
+type RestoreStats struct {
+	Decks        int `json:"decks"`
+	Tags         int `json:"tags"`
+	Articles     int `json:"articles"`
+	Vocabularies int `json:"vocabularies"`
+	TestResults  int `json:"test_results"`
+}
+
+// ExportArchive collects all of a user's data, including removed words, into an Archive
+func ExportArchive(db *sql.DB, userID int64) (*Archive, error) {
+	a := &Archive{
+		Version:      ArchiveVersion,
+		ExportedAt:   time.Now().UTC(),
+		Decks:        []ArchiveDeck{},
+		Tags:         []ArchiveTag{},
+		Articles:     []ArchiveArticle{},
+		Vocabularies: []ArchiveVocabulary{},
+		TestResults:  []ArchiveTestResult{},
+	}
+
+	err := db.QueryRow("SELECT username, created_at FROM users WHERE id = ?", userID).
+		Scan(&a.Profile.Username, &a.Profile.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(db, `
+		SELECT id, COALESCE(parent_id, 0), name, created_at FROM decks WHERE user_id = ? ORDER BY id
+	`, userID, func(rows *sql.Rows) error {
+		var d ArchiveDeck
+		if err := rows.Scan(&d.ID, &d.ParentID, &d.Name, &d.CreatedAt); err != nil {
+			return err
+		}
+		a.Decks = append(a.Decks, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(db, "SELECT id, name, created_at FROM tags WHERE user_id = ? ORDER BY id", userID, func(rows *sql.Rows) error {
+		var t ArchiveTag
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+			return err
+		}
+		a.Tags = append(a.Tags, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	articles := make(map[int64]int)
+	err = eachRow(db, `
+		SELECT id, title, source_type, source_url, content, created_at FROM articles WHERE user_id = ? ORDER BY id
+	`, userID, func(rows *sql.Rows) error {
+		var ar ArchiveArticle
+		if err := rows.Scan(&ar.ID, &ar.Title, &ar.SourceType, &ar.SourceURL, &ar.Content, &ar.CreatedAt); err != nil {
+			return err
+		}
+		articles[ar.ID] = len(a.Articles)
+		a.Articles = append(a.Articles, ar)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(db, `
+		SELECT c.article_id, c.position, c.title, c.content
+		FROM article_chapters c
+		JOIN articles a ON a.id = c.article_id
+		WHERE a.user_id = ?
+		ORDER BY c.article_id, c.position
+	`, userID, func(rows *sql.Rows) error {
+		var articleID int64
+		var ch ArchiveArticleChapter
+		if err := rows.Scan(&articleID, &ch.Position, &ch.Title, &ch.Content); err != nil {
+			return err
+		}
+		i := articles[articleID]
+		a.Articles[i].Chapters = append(a.Articles[i].Chapters, ch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vocabularies := make(map[int64]int)
+	err = eachRow(db, `
+		SELECT id, word, status, tested, COALESCE(article_id, 0), COALESCE(context, ''), COALESCE(deck_id, 0), created_at
+		FROM vocabularies
+		WHERE user_id = ?
+		ORDER BY id
+	`, userID, func(rows *sql.Rows) error {
+		v := ArchiveVocabulary{Definitions: []ArchiveDefinition{}}
+		if err := rows.Scan(&v.ID, &v.Word, &v.Status, &v.Tested, &v.ArticleID, &v.Context, &v.DeckID, &v.CreatedAt); err != nil {
+			return err
+		}
+		vocabularies[v.ID] = len(a.Vocabularies)
+		a.Vocabularies = append(a.Vocabularies, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(db, `
+		SELECT d.vocabulary_id, d.part_of_speech, d.definition, COALESCE(d.example, ''), d.created_at
+		FROM vocabulary_definitions d
+		JOIN vocabularies v ON v.id = d.vocabulary_id
+		WHERE v.user_id = ?
+		ORDER BY d.id
+	`, userID, func(rows *sql.Rows) error {
+		var vocabularyID int64
+		var d ArchiveDefinition
+		if err := rows.Scan(&vocabularyID, &d.PartOfSpeech, &d.Definition, &d.Example, &d.CreatedAt); err != nil {
+			return err
+		}
+		i := vocabularies[vocabularyID]
+		a.Vocabularies[i].Definitions = append(a.Vocabularies[i].Definitions, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(db, `
+		SELECT vt.vocabulary_id, vt.tag_id
+		FROM vocabulary_tags vt
+		JOIN tags t ON t.id = vt.tag_id
+		WHERE t.user_id = ?
+		ORDER BY vt.vocabulary_id, vt.tag_id
+	`, userID, func(rows *sql.Rows) error {
+		var vocabularyID, tagID int64
+		if err := rows.Scan(&vocabularyID, &tagID); err != nil {
+			return err
+		}
+		if i, ok := vocabularies[vocabularyID]; ok {
+			a.Vocabularies[i].TagIDs = append(a.Vocabularies[i].TagIDs, tagID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(db, `
+		SELECT word_id, correct, mode, created_at FROM test_results WHERE user_id = ? ORDER BY id
+	`, userID, func(rows *sql.Rows) error {
+		var r ArchiveTestResult
+		if err := rows.Scan(&r.WordID, &r.Correct, &r.Mode, &r.CreatedAt); err != nil {
+			return err
+		}
+		a.TestResults = append(a.TestResults, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// RestoreArchive recreates an archive's records under userID in a single transaction,
+// mapping the archive's IDs to newly assigned ones. The account must not have any words or articles yet.
+func RestoreArchive(db *sql.DB, userID int64, a *Archive) (*RestoreStats, error) {
+	if a.Version < 1 || a.Version > ArchiveVersion {
+		return nil, ErrArchiveVersion
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM vocabularies WHERE user_id = ?) + (SELECT COUNT(*) FROM articles WHERE user_id = ?)
+	`, userID, userID).Scan(&existing)
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrAccountNotEmpty
+	}
+
+	if err := checkArchiveDecks(a.Decks); err != nil {
+		return nil, err
+	}
+
+	stats := &RestoreStats{}
+
+	// 先建立所有牌組，再補上層級關係，避免上層尚未建立
+	deckIDs := make(map[int64]int64)
+	for _, d := range a.Decks {
+		result, err := tx.Exec("INSERT INTO decks (user_id, name, created_at) VALUES (?, ?, ?)",
+			userID, truncate(d.Name, 100), archiveTime(d.CreatedAt))
+		if err != nil {
+			return nil, err
+		}
+		if deckIDs[d.ID], err = result.LastInsertId(); err != nil {
+			return nil, err
+		}
+		stats.Decks++
+	}
+	for _, d := range a.Decks {
+		if d.ParentID == 0 {
+			continue
+		}
+		parentID, ok := deckIDs[d.ParentID]
+		if !ok {
+			return nil, fmt.Errorf("deck %d references unknown parent %d", d.ID, d.ParentID)
+		}
+		if _, err := tx.Exec("UPDATE decks SET parent_id = ? WHERE id = ?", parentID, deckIDs[d.ID]); err != nil {
+			return nil, err
+		}
+	}
+
+	tagIDs := make(map[int64]int64)
+	for _, t := range a.Tags {
+		name := NormalizeTags([]string{t.Name})
+		if len(name) == 0 {
+			continue
+		}
+		id, err := ensureTagInTx(tx, userID, name[0])
+		if err != nil {
+			return nil, err
+		}
+		tagIDs[t.ID] = id
+		stats.Tags++
+	}
+
+	// 與 CreateArticle 相同記錄網址的雜湊，還原後再次擷取同一網址會更新原本的文章；
+	// 封存中重複的網址只有第一篇記錄雜湊，與 001 遷移的做法一致
+	articleIDs := make(map[int64]int64)
+	hashed := make(map[string]bool)
+	for _, ar := range a.Articles {
+		var hash sql.NullString
+		if ar.SourceType == "url" && ar.SourceURL != "" && !hashed[ar.SourceURL] {
+			hashed[ar.SourceURL] = true
+			hash = sql.NullString{String: sourceHash(ar.SourceURL), Valid: true}
+		}
+		result, err := tx.Exec(`
+			INSERT INTO articles (user_id, title, source_type, source_url, source_hash, content, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, userID, truncate(ar.Title, 255), ar.SourceType, ar.SourceURL, hash, ar.Content, archiveTime(ar.CreatedAt))
+		if err != nil {
+			return nil, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		articleIDs[ar.ID] = id
+		for _, ch := range ar.Chapters {
+			_, err := tx.Exec(`
+				INSERT INTO article_chapters (article_id, position, title, content)
+				VALUES (?, ?, ?, ?)
+			`, id, ch.Position, truncate(ch.Title, 255), ch.Content)
+			if err != nil {
+				return nil, err
+			}
+		}
+		stats.Articles++
+	}
+
+	vocabularyIDs := make(map[int64]int64)
+	for _, v := range a.Vocabularies {
+		if v.Status != "removed" {
+			v.Status = "active"
+		}
+		result, err := tx.Exec(`
+			INSERT INTO vocabularies (user_id, word, status, tested, article_id, context, deck_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, userID, v.Word, v.Status, v.Tested, nullInt64(articleIDs[v.ArticleID]), nullString(v.Context),
+			nullInt64(deckIDs[v.DeckID]), archiveTime(v.CreatedAt))
+		if err != nil {
+			return nil, fmt.Errorf("word %q: %w", v.Word, err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		vocabularyIDs[v.ID] = id
+
+		for _, d := range v.Definitions {
+			_, err := tx.Exec(`
+				INSERT INTO vocabulary_definitions (vocabulary_id, part_of_speech, definition, example, created_at)
+				VALUES (?, ?, ?, ?, ?)
+			`, id, d.PartOfSpeech, d.Definition, d.Example, archiveTime(d.CreatedAt))
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, tagID := range v.TagIDs {
+			newID, ok := tagIDs[tagID]
+			if !ok {
+				continue
+			}
+			if _, err := tx.Exec("INSERT IGNORE INTO vocabulary_tags (vocabulary_id, tag_id) VALUES (?, ?)", id, newID); err != nil {
+				return nil, err
+			}
+		}
+		stats.Vocabularies++
+	}
+
+	for _, r := range a.TestResults {
+		wordID, ok := vocabularyIDs[r.WordID]
+		if !ok {
+			continue // 指向不存在單字的紀錄無法還原
+		}
+		if r.Mode == "" {
+			r.Mode = "flip"
+		}
+		_, err := tx.Exec(`
+			INSERT INTO test_results (user_id, word_id, correct, mode, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, userID, wordID, r.Correct, r.Mode, archiveTime(r.CreatedAt))
+		if err != nil {
+			return nil, err
+		}
+		stats.TestResults++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stats, RebuildSchedules(db, userID)
+}
+
+// checkArchiveDecks rejects parent references that are missing or form a cycle, which would make the
+// recursive deck queries loop
+func checkArchiveDecks(decks []ArchiveDeck) error {
+	parents := make(map[int64]int64, len(decks))
+	for _, d := range decks {
+		parents[d.ID] = d.ParentID
+	}
+	for _, d := range decks {
+		seen := map[int64]bool{d.ID: true}
+		for parent := d.ParentID; parent != 0; parent = parents[parent] {
+			if _, ok := parents[parent]; !ok {
+				return fmt.Errorf("deck %d references unknown parent %d", d.ID, parent)
+			}
+			if seen[parent] {
+				return ErrDeckCycle
+			}
+			seen[parent] = true
+		}
+	}
+	return nil
+}
+
+// eachRow runs a query for the user and calls fn for every row
+func eachRow(db *sql.DB, query string, userID int64, fn func(rows *sql.Rows) error) error {
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// archiveTime keeps the original timestamp, falling back to now when the archive has none
+func archiveTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+type Article struct {
+	ID         int64
+	UserID     int64
+	Title      string
+	SourceType string // url, txt, html, epub
+	SourceURL  string
+	Content    string
+	CreatedAt  time.Time
+	Chapters   []ArticleChapter
+}
+
+type ArticleChapter struct {
+	ID        int64
+	ArticleID int64
+	Position  int
+	Title     string
+	Content   string
+}
+
+// CreateArticle stores an article in the user's library and returns its ID. Fetching a URL that is already
+// in the library refreshes that article instead, so that words saved from it stay together.
+func CreateArticle(db *sql.DB, userID int64, title, sourceURL, content string) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO articles (user_id, title, source_type, source_url, source_hash, content)
+		VALUES (?, ?, 'url', ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), title = VALUES(title), content = VALUES(content)
+	`, userID, truncate(title, 255), sourceURL, sourceHash(sourceURL), content)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// sourceHash returns the key that identifies an article fetched from sourceURL within a user's library
+func sourceHash(sourceURL string) string {
+	sum := sha256.Sum256([]byte(sourceURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateDocument stores an uploaded document and its chapters in the user's library
+func CreateDocument(db *sql.DB, userID int64, title, sourceType string, chapters []ArticleChapter) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO articles (user_id, title, source_type, content)
+		VALUES (?, ?, ?, '')
+	`, userID, truncate(title, 255), sourceType)
+	if err != nil {
+		return 0, err
+	}
+	articleID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, chapter := range chapters {
+		_, err = tx.Exec(`
+			INSERT INTO article_chapters (article_id, position, title, content)
+			VALUES (?, ?, ?, ?)
+		`, articleID, i, truncate(chapter.Title, 255), chapter.Content)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return articleID, tx.Commit()
+}
+
+// GetArticlesByUserID lists the articles in a user's library without their content
+func GetArticlesByUserID(db *sql.DB, userID int64) ([]Article, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, title, source_type, source_url, created_at
+		FROM articles
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var articles []Article
+	for rows.Next() {
+		var a Article
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Title, &a.SourceType, &a.SourceURL, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		articles = append(articles, a)
+	}
+
+	return articles, rows.Err()
+}
+
+// GetArticleByID retrieves an article owned by the user together with its chapters
+func GetArticleByID(db *sql.DB, userID, id int64) (*Article, error) {
+	var a Article
+	err := db.QueryRow(`
+		SELECT id, user_id, title, source_type, source_url, content, created_at
+		FROM articles
+		WHERE id = ? AND user_id = ?
+	`, id, userID).Scan(&a.ID, &a.UserID, &a.Title, &a.SourceType, &a.SourceURL, &a.Content, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, article_id, position, title, content
+		FROM article_chapters
+		WHERE article_id = ?
+		ORDER BY position
+	`, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chapter ArticleChapter
+		if err := rows.Scan(&chapter.ID, &chapter.ArticleID, &chapter.Position, &chapter.Title, &chapter.Content); err != nil {
+			return nil, err
+		}
+		a.Chapters = append(a.Chapters, chapter)
+	}
+
+	return &a, rows.Err()
+}
+
+// ArticleExists reports whether the article belongs to the user
+func ArticleExists(db *sql.DB, userID, id int64) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM articles WHERE id = ? AND user_id = ?", id, userID).Scan(&count)
+	return count > 0, err
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
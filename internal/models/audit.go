@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 帳號安全事件種類
+const (
+	EventLoginSucceeded = "login_succeeded"
+	EventLoginFailed    = "login_failed"
+	EventLoginThrottled = "login_throttled"
+	EventAccountLocked  = "account_locked"
+
+	EventPasswordChanged        = "password_changed"
+	EventPasswordResetRequested = "password_reset_requested"
+	EventPasswordReset          = "password_reset"
+
+	EventTwoFactorEnabled      = "two_factor_enabled"
+	EventTwoFactorDisabled     = "two_factor_disabled"
+	EventRecoveryCodeUsed      = "recovery_code_used"
+	EventRecoveryCodesReissued = "recovery_codes_reissued"
+
+	EventIdentityLinked   = "identity_linked"
+	EventIdentityUnlinked = "identity_unlinked"
+	EventUserProvisioned  = "user_provisioned"
+	EventReauthenticated  = "reauthenticated"
+
+	EventRoleChanged        = "role_changed"
+	EventAccountDisabled    = "account_disabled"
+	EventAccountEnabled     = "account_enabled"
+	EventAdminPasswordReset = "admin_password_reset"
+
+	EventAccountDeletionRequested = "account_deletion_requested"
+	EventAccountDeletionCancelled = "account_deletion_cancelled"
+	EventAccountDeleted           = "account_deleted"
+)
+
+// AuthEvent is an entry in the authentication audit log
+type AuthEvent struct {
+	ID        int64
+	UserID    int64 // 0 表示找不到對應的使用者
+	Username  string
+	Event     string
+	Detail    string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+// RecordAuthEvent appends an event to the audit log
+func RecordAuthEvent(db *sql.DB, e *AuthEvent) error {
+	_, err := db.Exec(`
+		INSERT INTO auth_audit_log (user_id, username, event, detail, ip, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, nullInt64(e.UserID), truncate(e.Username, 50), e.Event, truncate(e.Detail, 255), truncate(e.IP, 45),
+		truncate(e.UserAgent, 255), time.Now())
+	return err
+}
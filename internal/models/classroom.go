@@ -0,0 +1,468 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Class is a group of students that a teacher assigns word lists to
+type Class struct {
+	ID           int64
+	TeacherID    int64
+	TeacherName  string
+	Name         string
+	JoinCode     string
+	StudentCount int
+	CreatedAt    time.Time
+}
+
+// ClassStudent is a member of a class
+type ClassStudent struct {
+	UserID   int64
+	Username string
+	JoinedAt time.Time
+}
+
+// Assignment is a word list given to a class with a due date. The words are copied when the
+// assignment is created, so later edits to the source deck do not change it.
+type Assignment struct {
+	ID        int64
+	ClassID   int64
+	ClassName string
+	Title     string
+	DueAt     time.Time
+	WordCount int
+	CreatedAt time.Time
+	Words     []SharedDeckWord
+}
+
+// AssignmentProgress is one student's work on an assignment, or on all of a class's assignments
+type AssignmentProgress struct {
+	UserID       int64
+	Username     string
+	WordsTotal   int
+	WordsAdded   int // 已加入自己單字庫的單字
+	WordsLearned int // 加入班級後至少答對一次的單字
+	Reviews      int
+	Correct      int
+	LastReviewAt *time.Time
+}
+
+// Accuracy returns the share of correct answers, or 0 without reviews
+func (p AssignmentProgress) Accuracy() float64 {
+	if p.Reviews == 0 {
+		return 0
+	}
+	return float64(p.Correct) / float64(p.Reviews)
+}
+
+// CreateClass creates a class owned by a teacher and returns its ID
+func CreateClass(db *sql.DB, teacherID int64, name, joinCode string) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO classes (teacher_id, name, join_code) VALUES (?, ?, ?)
+	`, teacherID, name, joinCode)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetClassesByTeacher lists a teacher's classes with their student counts
+func GetClassesByTeacher(db *sql.DB, teacherID int64) ([]Class, error) {
+	rows, err := db.Query(`
+		SELECT c.id, c.teacher_id, u.username, c.name, c.join_code, c.created_at, COUNT(m.user_id)
+		FROM classes c
+		JOIN users u ON u.id = c.teacher_id
+		LEFT JOIN class_members m ON m.class_id = c.id
+		WHERE c.teacher_id = ?
+		GROUP BY c.id, c.teacher_id, u.username, c.name, c.join_code, c.created_at
+		ORDER BY c.created_at DESC
+	`, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []Class{}
+	for rows.Next() {
+		var cl Class
+		if err := rows.Scan(&cl.ID, &cl.TeacherID, &cl.TeacherName, &cl.Name, &cl.JoinCode, &cl.CreatedAt, &cl.StudentCount); err != nil {
+			return nil, err
+		}
+		classes = append(classes, cl)
+	}
+	return classes, rows.Err()
+}
+
+// GetClass retrieves a class owned by the teacher, or nil if there is none
+func GetClass(db *sql.DB, teacherID, id int64) (*Class, error) {
+	var cl Class
+	err := db.QueryRow(`
+		SELECT c.id, c.teacher_id, u.username, c.name, c.join_code, c.created_at,
+			(SELECT COUNT(*) FROM class_members m WHERE m.class_id = c.id)
+		FROM classes c
+		JOIN users u ON u.id = c.teacher_id
+		WHERE c.id = ? AND c.teacher_id = ?
+	`, id, teacherID).Scan(&cl.ID, &cl.TeacherID, &cl.TeacherName, &cl.Name, &cl.JoinCode, &cl.CreatedAt, &cl.StudentCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// GetClassesByStudent lists the classes a user has joined
+func GetClassesByStudent(db *sql.DB, userID int64) ([]Class, error) {
+	rows, err := db.Query(`
+		SELECT c.id, c.teacher_id, u.username, c.name, c.created_at
+		FROM class_members m
+		JOIN classes c ON c.id = m.class_id
+		JOIN users u ON u.id = c.teacher_id
+		WHERE m.user_id = ?
+		ORDER BY m.joined_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []Class{}
+	for rows.Next() {
+		var cl Class
+		if err := rows.Scan(&cl.ID, &cl.TeacherID, &cl.TeacherName, &cl.Name, &cl.CreatedAt); err != nil {
+			return nil, err
+		}
+		classes = append(classes, cl)
+	}
+	return classes, rows.Err()
+}
+
+// RenameClass changes the name of a teacher's class
+func RenameClass(db *sql.DB, teacherID, id int64, name string) error {
+	result, err := db.Exec("UPDATE classes SET name = ? WHERE id = ? AND teacher_id = ?", name, id, teacherID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetJoinCode replaces a class's join code; the old code stops working immediately
+func SetJoinCode(db *sql.DB, teacherID, id int64, joinCode string) error {
+	result, err := db.Exec("UPDATE classes SET join_code = ? WHERE id = ? AND teacher_id = ?", joinCode, id, teacherID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteClass removes a teacher's class with its members and assignments. Students keep the words they added.
+func DeleteClass(db *sql.DB, teacherID, id int64) error {
+	result, err := db.Exec("DELETE FROM classes WHERE id = ? AND teacher_id = ?", id, teacherID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// JoinClass adds the user to the class with the given join code and returns the class,
+// or nil if no class has that code. Joining a class twice has no effect.
+func JoinClass(db *sql.DB, userID int64, joinCode string) (*Class, error) {
+	var cl Class
+	err := db.QueryRow(`
+		SELECT c.id, c.teacher_id, u.username, c.name, c.created_at
+		FROM classes c
+		JOIN users u ON u.id = c.teacher_id
+		WHERE c.join_code = ?
+	`, joinCode).Scan(&cl.ID, &cl.TeacherID, &cl.TeacherName, &cl.Name, &cl.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		INSERT IGNORE INTO class_members (class_id, user_id, joined_at) VALUES (?, ?, ?)
+	`, cl.ID, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// LeaveClass removes the user from a class
+func LeaveClass(db *sql.DB, userID, classID int64) error {
+	result, err := db.Exec("DELETE FROM class_members WHERE class_id = ? AND user_id = ?", classID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RemoveStudent removes a student from a teacher's class
+func RemoveStudent(db *sql.DB, teacherID, classID, userID int64) error {
+	result, err := db.Exec(`
+		DELETE m FROM class_members m
+		JOIN classes c ON c.id = m.class_id
+		WHERE m.class_id = ? AND m.user_id = ? AND c.teacher_id = ?
+	`, classID, userID, teacherID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetClassStudents lists the members of a class
+func GetClassStudents(db *sql.DB, classID int64) ([]ClassStudent, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.username, m.joined_at
+		FROM class_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.class_id = ?
+		ORDER BY u.username
+	`, classID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []ClassStudent{}
+	for rows.Next() {
+		var s ClassStudent
+		if err := rows.Scan(&s.UserID, &s.Username, &s.JoinedAt); err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// CreateAssignment gives a copy of the words to a class and returns the assignment's ID
+func CreateAssignment(db *sql.DB, classID int64, title string, dueAt time.Time, words []SharedDeckWord) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO class_assignments (class_id, title, due_at) VALUES (?, ?, ?)
+	`, classID, title, dueAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, w := range words {
+		definitions := w.Definitions
+		if len(definitions) == 0 {
+			// 沒有定義的單字也要保留
+			definitions = []VocabularyDefinition{{}}
+		}
+		for _, def := range definitions {
+			_, err := tx.Exec(`
+				INSERT INTO class_assignment_words (assignment_id, word, part_of_speech, definition, example)
+				VALUES (?, ?, ?, ?, ?)
+			`, id, w.Word, def.PartOfSpeech, def.Definition, def.Example)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// GetAssignments lists a class's assignments, earliest due first
+func GetAssignments(db *sql.DB, classID int64) ([]Assignment, error) {
+	return queryAssignments(db, "a.class_id = ?", classID)
+}
+
+// GetStudentAssignments lists the assignments of every class the user has joined, earliest due first
+func GetStudentAssignments(db *sql.DB, userID int64) ([]Assignment, error) {
+	return queryAssignments(db, "a.class_id IN (SELECT class_id FROM class_members WHERE user_id = ?)", userID)
+}
+
+func queryAssignments(db *sql.DB, where string, arg int64) ([]Assignment, error) {
+	rows, err := db.Query(`
+		SELECT a.id, a.class_id, c.name, a.title, a.due_at, a.created_at,
+			(SELECT COUNT(DISTINCT w.word) FROM class_assignment_words w WHERE w.assignment_id = a.id)
+		FROM class_assignments a
+		JOIN classes c ON c.id = a.class_id
+		WHERE `+where+`
+		ORDER BY a.due_at, a.id
+	`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []Assignment{}
+	for rows.Next() {
+		var a Assignment
+		if err := rows.Scan(&a.ID, &a.ClassID, &a.ClassName, &a.Title, &a.DueAt, &a.CreatedAt, &a.WordCount); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// GetStudentAssignment retrieves an assignment with its words if the user is a member of its class, or nil otherwise
+func GetStudentAssignment(db *sql.DB, userID, id int64) (*Assignment, error) {
+	var a Assignment
+	err := db.QueryRow(`
+		SELECT a.id, a.class_id, c.name, a.title, a.due_at, a.created_at
+		FROM class_assignments a
+		JOIN classes c ON c.id = a.class_id
+		JOIN class_members m ON m.class_id = a.class_id AND m.user_id = ?
+		WHERE a.id = ?
+	`, userID, id).Scan(&a.ID, &a.ClassID, &a.ClassName, &a.Title, &a.DueAt, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT word, part_of_speech, definition, example
+		FROM class_assignment_words
+		WHERE assignment_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// 同一個單字的多個定義合併為一筆
+	index := make(map[string]int)
+	for rows.Next() {
+		var word string
+		var def VocabularyDefinition
+		var example sql.NullString
+		if err := rows.Scan(&word, &def.PartOfSpeech, &def.Definition, &example); err != nil {
+			return nil, err
+		}
+		def.Example = example.String
+		i, ok := index[word]
+		if !ok {
+			i = len(a.Words)
+			index[word] = i
+			a.Words = append(a.Words, SharedDeckWord{Word: word})
+		}
+		if def.Definition != "" {
+			a.Words[i].Definitions = append(a.Words[i].Definitions, def)
+		}
+	}
+	a.WordCount = len(a.Words)
+
+	return &a, rows.Err()
+}
+
+// DeleteAssignment removes an assignment from a teacher's class
+func DeleteAssignment(db *sql.DB, teacherID, classID, id int64) error {
+	result, err := db.Exec(`
+		DELETE a FROM class_assignments a
+		JOIN classes c ON c.id = a.class_id
+		WHERE a.id = ? AND a.class_id = ? AND c.teacher_id = ?
+	`, id, classID, teacherID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetClassProgress reports each student's progress on the words of one assignment, or of all the class's
+// assignments when assignmentID is 0. Only answers given after the student joined the class are counted.
+func GetClassProgress(db *sql.DB, classID, assignmentID int64) ([]AssignmentProgress, error) {
+	words := "SELECT w.word FROM class_assignment_words w JOIN class_assignments a ON a.id = w.assignment_id WHERE a.class_id = ?"
+	args := []interface{}{classID}
+	if assignmentID != 0 {
+		words += " AND a.id = ?"
+		args = append(args, assignmentID)
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(DISTINCT word) FROM ("+words+") aw", args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT u.id, u.username,
+			COUNT(DISTINCT v.id),
+			COUNT(DISTINCT CASE WHEN t.correct THEN v.id END),
+			COUNT(t.id),
+			COALESCE(SUM(t.correct), 0),
+			MAX(t.created_at)
+		FROM class_members m
+		JOIN users u ON u.id = m.user_id
+		LEFT JOIN vocabularies v ON v.user_id = m.user_id AND v.status = 'active' AND v.word IN (` + words + `)
+		LEFT JOIN test_results t ON t.word_id = v.id AND t.created_at >= m.joined_at
+		WHERE m.class_id = ?
+		GROUP BY u.id, u.username
+		ORDER BY u.username
+	`
+	rows, err := db.Query(query, append(args, classID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []AssignmentProgress{}
+	for rows.Next() {
+		p := AssignmentProgress{WordsTotal: total}
+		var last sql.NullTime
+		if err := rows.Scan(&p.UserID, &p.Username, &p.WordsAdded, &p.WordsLearned, &p.Reviews, &p.Correct, &last); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			p.LastReviewAt = &last.Time
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+// GetStudentProgress reports the user's own progress on the assignments of every class they joined, keyed by assignment ID
+func GetStudentProgress(db *sql.DB, userID int64) (map[int64]AssignmentProgress, error) {
+	rows, err := db.Query(`
+		SELECT a.id,
+			COUNT(DISTINCT v.id),
+			COUNT(DISTINCT CASE WHEN t.correct THEN v.id END),
+			COUNT(t.id),
+			COALESCE(SUM(t.correct), 0),
+			MAX(t.created_at)
+		FROM class_assignments a
+		JOIN class_members m ON m.class_id = a.class_id AND m.user_id = ?
+		LEFT JOIN vocabularies v ON v.user_id = m.user_id AND v.status = 'active'
+			AND v.word IN (SELECT w.word FROM class_assignment_words w WHERE w.assignment_id = a.id)
+		LEFT JOIN test_results t ON t.word_id = v.id AND t.created_at >= m.joined_at
+		GROUP BY a.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := make(map[int64]AssignmentProgress)
+	for rows.Next() {
+		var id int64
+		p := AssignmentProgress{UserID: userID}
+		var last sql.NullTime
+		if err := rows.Scan(&id, &p.WordsAdded, &p.WordsLearned, &p.Reviews, &p.Correct, &last); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			p.LastReviewAt = &last.Time
+		}
+		progress[id] = p
+	}
+	return progress, rows.Err()
+}
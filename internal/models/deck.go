@@ -0,0 +1,163 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrDeckCycle is returned when moving a deck under itself or one of its descendants
+var ErrDeckCycle = errors.New("deck cannot be nested inside itself")
+
+// Deck is a user-defined group of words; decks can be nested through ParentID
+type Deck struct {
+	ID        int64
+	UserID    int64
+	ParentID  int64 // 0 表示最上層
+	Name      string
+	WordCount int
+	CreatedAt time.Time
+	Children  []*Deck
+}
+
+// GetDecksByUserID returns all of the user's decks as a flat list ordered by name
+func GetDecksByUserID(db *sql.DB, userID int64) ([]*Deck, error) {
+	rows, err := db.Query(`
+		SELECT d.id, d.user_id, COALESCE(d.parent_id, 0), d.name, d.created_at, COUNT(v.id)
+		FROM decks d
+		LEFT JOIN vocabularies v ON v.deck_id = d.id AND v.status = 'active'
+		WHERE d.user_id = ?
+		GROUP BY d.id, d.user_id, d.parent_id, d.name, d.created_at
+		ORDER BY d.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decks []*Deck
+	for rows.Next() {
+		d := &Deck{}
+		if err := rows.Scan(&d.ID, &d.UserID, &d.ParentID, &d.Name, &d.CreatedAt, &d.WordCount); err != nil {
+			return nil, err
+		}
+		decks = append(decks, d)
+	}
+
+	return decks, rows.Err()
+}
+
+// BuildDeckTree links decks to their parents and returns the top-level decks
+func BuildDeckTree(decks []*Deck) []*Deck {
+	byID := make(map[int64]*Deck)
+	for _, d := range decks {
+		d.Children = nil
+		byID[d.ID] = d
+	}
+
+	var roots []*Deck
+	for _, d := range decks {
+		if parent, ok := byID[d.ParentID]; ok {
+			parent.Children = append(parent.Children, d)
+		} else {
+			roots = append(roots, d)
+		}
+	}
+	return roots
+}
+
+// DeckDescendants returns the deck's ID together with the IDs of all decks nested under it
+func DeckDescendants(decks []*Deck, id int64) map[int64]bool {
+	ids := map[int64]bool{id: true}
+	for changed := true; changed; {
+		changed = false
+		for _, d := range decks {
+			if !ids[d.ID] && ids[d.ParentID] {
+				ids[d.ID] = true
+				changed = true
+			}
+		}
+	}
+	return ids
+}
+
+// GetDeck retrieves one of the user's decks, or nil if it does not exist
+func GetDeck(db *sql.DB, userID, id int64) (*Deck, error) {
+	d := &Deck{}
+	err := db.QueryRow(`
+		SELECT id, user_id, COALESCE(parent_id, 0), name, created_at
+		FROM decks
+		WHERE id = ? AND user_id = ?
+	`, id, userID).Scan(&d.ID, &d.UserID, &d.ParentID, &d.Name, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CreateDeck creates a deck for the user under parentID (0 for top level)
+func CreateDeck(db *sql.DB, userID, parentID int64, name string) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO decks (user_id, parent_id, name)
+		VALUES (?, ?, ?)
+	`, userID, nullInt64(parentID), name)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdateDeck renames a deck and moves it under parentID, refusing to create cycles
+func UpdateDeck(db *sql.DB, userID, id, parentID int64, name string) error {
+	if parentID != 0 {
+		decks, err := GetDecksByUserID(db, userID)
+		if err != nil {
+			return err
+		}
+		if DeckDescendants(decks, id)[parentID] {
+			return ErrDeckCycle
+		}
+	}
+
+	result, err := db.Exec(`
+		UPDATE decks
+		SET name = ?, parent_id = ?
+		WHERE id = ? AND user_id = ?
+	`, name, nullInt64(parentID), id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		// 名稱與上層未變更時也不會有影響的列，需再確認是否存在
+		d, err := GetDeck(db, userID, id)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return sql.ErrNoRows
+		}
+	}
+	return nil
+}
+
+// DeleteDeck removes a deck and its sub-decks; their words are kept without a deck
+func DeleteDeck(db *sql.DB, userID, id int64) error {
+	result, err := db.Exec("DELETE FROM decks WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetDeck moves a vocabulary word into a deck (0 removes it from any deck)
+func (v *Vocabulary) SetDeck(db *sql.DB, deckID int64) error {
+	_, err := db.Exec(`
+		UPDATE vocabularies
+		SET deck_id = ?
+		WHERE id = ? AND user_id = ?
+	`, nullInt64(deckID), v.ID, v.UserID)
+	return err
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// DictionaryCacheEntry is a cached dictionary API response for one word
+type DictionaryCacheEntry struct {
+	Word        string
+	Definitions []map[string]string
+	NotFound    bool // 字典查無此字
+	Hits        int
+	FetchedAt   time.Time
+}
+
+// GetCachedDefinitions returns the cached entry for a word fetched after the given time, or nil if there is none;
+// hits are counted by the caller and saved in batches with AddDictionaryHits
+func GetCachedDefinitions(db *sql.DB, word string, since time.Time) (*DictionaryCacheEntry, error) {
+	e, err := scanDictionaryCacheEntry(db.QueryRow(`
+		SELECT word, definitions, not_found, hits, fetched_at
+		FROM dictionary_cache
+		WHERE word = ? AND fetched_at > ?
+	`, word, since))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// AddDictionaryHits adds counted cache hits to their words in one transaction
+func AddDictionaryHits(db *sql.DB, hits map[string]int) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("UPDATE dictionary_cache SET hits = hits + ? WHERE word = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for word, n := range hits {
+		if _, err := stmt.Exec(n, word); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CacheDefinitions stores a dictionary API response, replacing any earlier one
+func CacheDefinitions(db *sql.DB, word string, definitions []map[string]string, notFound bool) error {
+	var encoded sql.NullString
+	if !notFound {
+		b, err := json.Marshal(definitions)
+		if err != nil {
+			return err
+		}
+		encoded = sql.NullString{String: string(b), Valid: true}
+	}
+	_, err := db.Exec(`
+		INSERT INTO dictionary_cache (word, definitions, not_found, fetched_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			definitions = VALUES(definitions),
+			not_found = VALUES(not_found),
+			fetched_at = VALUES(fetched_at)
+	`, word, encoded, notFound, time.Now())
+	return err
+}
+
+// ListDictionaryCache returns cached words starting with prefix, most used first, and the total number of matches
+func ListDictionaryCache(db *sql.DB, prefix string, limit, offset int) ([]DictionaryCacheEntry, int, error) {
+	like := prefix + "%"
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM dictionary_cache WHERE word LIKE ?", like).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT word, definitions, not_found, hits, fetched_at
+		FROM dictionary_cache
+		WHERE word LIKE ?
+		ORDER BY hits DESC, word
+		LIMIT ? OFFSET ?
+	`, like, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []DictionaryCacheEntry{}
+	for rows.Next() {
+		e, err := scanDictionaryCacheEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, total, rows.Err()
+}
+
+// DeleteCachedDefinitions removes one word from the cache so that the next lookup fetches it again
+func DeleteCachedDefinitions(db *sql.DB, word string) error {
+	result, err := db.Exec("DELETE FROM dictionary_cache WHERE word = ?", word)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// PurgeDictionaryCache removes entries fetched before the given time, or every entry if it is zero
+func PurgeDictionaryCache(db *sql.DB, before time.Time) (int64, error) {
+	var result sql.Result
+	var err error
+	if before.IsZero() {
+		result, err = db.Exec("DELETE FROM dictionary_cache")
+	} else {
+		result, err = db.Exec("DELETE FROM dictionary_cache WHERE fetched_at < ?", before)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ExpireDictionaryCache removes entries fetched before the given time and not-found entries fetched before
+// notFoundBefore
+func ExpireDictionaryCache(db *sql.DB, before, notFoundBefore time.Time) (int64, error) {
+	result, err := db.Exec(
+		"DELETE FROM dictionary_cache WHERE fetched_at < ? OR (not_found AND fetched_at < ?)",
+		before, notFoundBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// TrimDictionaryCache removes the least used, oldest entries until at most max remain
+func TrimDictionaryCache(db *sql.DB, max int) (int64, error) {
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM dictionary_cache").Scan(&total); err != nil {
+		return 0, err
+	}
+	if total <= max {
+		return 0, nil
+	}
+	result, err := db.Exec("DELETE FROM dictionary_cache ORDER BY hits, fetched_at LIMIT ?", total-max)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanDictionaryCacheEntry(row interface{ Scan(...interface{}) error }) (*DictionaryCacheEntry, error) {
+	e := &DictionaryCacheEntry{}
+	var definitions sql.NullString
+	if err := row.Scan(&e.Word, &definitions, &e.NotFound, &e.Hits, &e.FetchedAt); err != nil {
+		return nil, err
+	}
+	if definitions.Valid {
+		if err := json.Unmarshal([]byte(definitions.String), &e.Definitions); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
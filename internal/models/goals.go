@@ -0,0 +1,217 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 更新連續天數時最多回溯的天數
+const streakLookbackDays = 60
+
+// DailyProgress is what a user did on one day in their own time zone
+type DailyProgress struct {
+	Day      string
+	NewWords int
+	Reviews  int
+	Minutes  int
+}
+
+// GoalsMet reports whether a day's progress reaches every goal that is set; without goals any review counts
+func (p *Preferences) GoalsMet(d DailyProgress) bool {
+	if !p.HasGoals() {
+		return d.Reviews > 0
+	}
+	return d.NewWords >= p.GoalNewWords && d.Reviews >= p.GoalReviews && d.Minutes >= p.GoalMinutes
+}
+
+// Streak is the number of consecutive days on which the user met their goals
+type Streak struct {
+	Current int
+	Longest int
+	LastDay string // 最後一次達成目標的日期，空字串表示從未達成
+}
+
+// StartOfDay returns local midnight of the day containing t
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// GetDailyProgress returns the progress on each local day from the day of from through the day of to
+func GetDailyProgress(db *sql.DB, userID int64, loc *time.Location, from, to time.Time) (map[string]*DailyProgress, error) {
+	start := StartOfDay(from, loc)
+	end := StartOfDay(to, loc).AddDate(0, 0, 1)
+
+	progress := make(map[string]*DailyProgress)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format(DayFormat)
+		progress[key] = &DailyProgress{Day: key}
+	}
+
+	// 依使用者時區將時間歸到當地日期
+	count := func(query string, add func(p *DailyProgress)) error {
+		rows, err := db.Query(query, userID, start, end)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var at time.Time
+			if err := rows.Scan(&at); err != nil {
+				return err
+			}
+			if p, ok := progress[at.In(loc).Format(DayFormat)]; ok {
+				add(p)
+			}
+		}
+		return rows.Err()
+	}
+	err := count(`SELECT created_at FROM vocabularies WHERE user_id = ? AND created_at >= ? AND created_at < ?`,
+		func(p *DailyProgress) { p.NewWords++ })
+	if err != nil {
+		return nil, err
+	}
+	err = count(`SELECT created_at FROM test_results WHERE user_id = ? AND created_at >= ? AND created_at < ?`,
+		func(p *DailyProgress) { p.Reviews++ })
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT DATE_FORMAT(day, '%Y-%m-%d'), minutes_read
+		FROM daily_activity
+		WHERE user_id = ? AND day >= ? AND day < ?
+	`, userID, start.Format(DayFormat), end.Format(DayFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var minutes int
+		if err := rows.Scan(&day, &minutes); err != nil {
+			return nil, err
+		}
+		if p, ok := progress[day]; ok {
+			p.Minutes = minutes
+		}
+	}
+	return progress, rows.Err()
+}
+
+// AddReadingMinutes adds reading time to the user's total for a local day, keeping the total at or below limit
+func AddReadingMinutes(db *sql.DB, userID int64, day string, minutes, limit int) error {
+	_, err := db.Exec(`
+		INSERT INTO daily_activity (user_id, day, minutes_read)
+		VALUES (?, ?, LEAST(?, ?))
+		ON DUPLICATE KEY UPDATE minutes_read = LEAST(minutes_read + VALUES(minutes_read), ?)
+	`, userID, day, minutes, limit, limit)
+	return err
+}
+
+// GetStreak returns the user's streak as of now without saving it, counting the days since it was last updated
+// like UpdateStreak does
+func GetStreak(db *sql.DB, p *Preferences, now time.Time) (*Streak, error) {
+	s := &Streak{}
+	var lastDay sql.NullString
+	err := db.QueryRow(`
+		SELECT current_streak, longest_streak, DATE_FORMAT(last_day, '%Y-%m-%d')
+		FROM user_streaks
+		WHERE user_id = ?
+	`, p.UserID).Scan(&s.Current, &s.Longest, &lastDay)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	s.LastDay = lastDay.String
+
+	if err := advanceStreak(db, p, s, now); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateStreak counts every day since the streak was last updated on which the goals were met, and resets
+// the streak once a whole day has passed without meeting them. Today only counts once its goals are met.
+func UpdateStreak(db *sql.DB, p *Preferences, now time.Time) (*Streak, error) {
+	if _, err := db.Exec("INSERT IGNORE INTO user_streaks (user_id) VALUES (?)", p.UserID); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s := &Streak{}
+	var lastDay sql.NullString
+	err = tx.QueryRow(`
+		SELECT current_streak, longest_streak, DATE_FORMAT(last_day, '%Y-%m-%d')
+		FROM user_streaks
+		WHERE user_id = ?
+		FOR UPDATE
+	`, p.UserID).Scan(&s.Current, &s.Longest, &lastDay)
+	if err != nil {
+		return nil, err
+	}
+	s.LastDay = lastDay.String
+
+	if err := advanceStreak(db, p, s, now); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE user_streaks
+		SET current_streak = ?, longest_streak = ?, last_day = ?
+		WHERE user_id = ?
+	`, s.Current, s.Longest, nullString(s.LastDay), p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s, tx.Commit()
+}
+
+// advanceStreak adds the days after s.LastDay through today on which the goals were met to s
+func advanceStreak(db *sql.DB, p *Preferences, s *Streak, now time.Time) error {
+	loc := p.Location()
+	today := StartOfDay(now, loc)
+
+	start := today.AddDate(0, 0, -streakLookbackDays)
+	if s.LastDay != "" {
+		last, err := time.ParseInLocation(DayFormat, s.LastDay, loc)
+		if err != nil {
+			return err
+		}
+		if next := last.AddDate(0, 0, 1); next.After(start) {
+			start = next
+		}
+	}
+
+	if !start.After(today) {
+		progress, err := GetDailyProgress(db, p.UserID, loc, start, today)
+		if err != nil {
+			return err
+		}
+		for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+			key := day.Format(DayFormat)
+			if !p.GoalsMet(*progress[key]) {
+				continue
+			}
+			if s.LastDay == day.AddDate(0, 0, -1).Format(DayFormat) {
+				s.Current++
+			} else {
+				s.Current = 1
+			}
+			s.LastDay = key
+			if s.Current > s.Longest {
+				s.Longest = s.Current
+			}
+		}
+	}
+
+	// 昨天與今天都沒有達成時中斷
+	if s.LastDay != today.Format(DayFormat) && s.LastDay != today.AddDate(0, 0, -1).Format(DayFormat) {
+		s.Current = 0
+	}
+	return nil
+}
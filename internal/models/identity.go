@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrLastIdentity is returned when unlinking the only way a user without a password can sign in
+var ErrLastIdentity = errors.New("cannot unlink the only sign-in method of an account without a password")
+
+// Identity links an account at an external OpenID Connect provider to a user
+type Identity struct {
+	ID          int64
+	UserID      int64
+	Issuer      string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// GetIdentity returns the identity with the given issuer and subject, or nil if it is not linked to anyone
+func GetIdentity(db *sql.DB, issuer, subject string) (*Identity, error) {
+	i := &Identity{}
+	var email sql.NullString
+	err := db.QueryRow(`
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = ? AND subject = ?
+	`, issuer, subject).Scan(&i.ID, &i.UserID, &i.Issuer, &i.Subject, &email, &i.CreatedAt, &i.LastLoginAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	i.Email = email.String
+	return i, nil
+}
+
+// GetIdentities returns the external identities linked to the user
+func GetIdentities(db *sql.DB, userID int64) ([]Identity, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = ?
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var i Identity
+		var email sql.NullString
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Issuer, &i.Subject, &email, &i.CreatedAt, &i.LastLoginAt); err != nil {
+			return nil, err
+		}
+		i.Email = email.String
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// LinkIdentity links an external identity to a user
+func LinkIdentity(db *sql.DB, userID int64, issuer, subject, email string) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO user_identities (user_id, issuer, subject, email, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, issuer, subject, nullString(truncate(email, 255)), now, now)
+	return err
+}
+
+// TouchIdentity records a login through the identity and the email address the provider reported
+func TouchIdentity(db *sql.DB, id int64, email string) error {
+	_, err := db.Exec(`
+		UPDATE user_identities SET last_login_at = ?, email = ? WHERE id = ?
+	`, time.Now(), nullString(truncate(email, 255)), id)
+	return err
+}
+
+// UnlinkIdentity removes one of the user's external identities. It returns ErrLastIdentity instead when the
+// account has no password and this is its only identity, since the user could no longer sign in.
+func UnlinkIdentity(db *sql.DB, userID, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 鎖住使用者列，避免同時解除兩個連結
+	var passwordSet bool
+	err = tx.QueryRow("SELECT password_set FROM users WHERE id = ? FOR UPDATE", userID).Scan(&passwordSet)
+	if err != nil {
+		return err
+	}
+	if !passwordSet {
+		var others int
+		err := tx.QueryRow("SELECT COUNT(*) FROM user_identities WHERE user_id = ? AND id <> ?", userID, id).Scan(&others)
+		if err != nil {
+			return err
+		}
+		if others == 0 {
+			return ErrLastIdentity
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM user_identities WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ProvisionUser creates an account for a first-time external login together with its identity link.
+// The username gets a numeric suffix when the preferred one is taken.
+func ProvisionUser(db *sql.DB, username, passwordHash, email, issuer, subject string) (*User, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	name := truncate(username, 40)
+	for n := 2; ; n++ {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", name).Scan(&taken); err != nil {
+			return nil, err
+		}
+		if !taken {
+			break
+		}
+		name = truncate(username, 40) + strconv.Itoa(n)
+	}
+
+	// 電子郵件已被其他帳號使用時不設定，避免違反唯一性
+	if email != "" {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", email).Scan(&taken); err != nil {
+			return nil, err
+		}
+		if taken {
+			email = ""
+		}
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO users (username, password, password_set, email, created_at) VALUES (?, ?, FALSE, ?, ?)
+	`, name, passwordHash, nullString(email), now)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO user_identities (user_id, issuer, subject, email, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, issuer, subject, nullString(truncate(email, 255)), now, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &User{ID: userID, Username: name, Password: passwordHash, Email: email, CreatedAt: now}, nil
+}
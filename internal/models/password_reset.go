@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrResetUsed is returned when a password reset token was already used, superseded or never issued
+var ErrResetUsed = errors.New("password reset token has already been used")
+
+// CreatePasswordReset records an issued reset token by the hash of its nonce
+func CreatePasswordReset(db *sql.DB, userID int64, nonceHash string, expiresAt time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO password_resets (user_id, nonce_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, nonceHash, time.Now(), expiresAt)
+	return err
+}
+
+// HasRecentPasswordReset reports whether a reset was requested for the user after the given time
+func HasRecentPasswordReset(db *sql.DB, userID int64, since time.Time) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM password_resets WHERE user_id = ? AND created_at > ?)
+	`, userID, since).Scan(&exists)
+	return exists, err
+}
+
+// ResetPassword uses up a reset token, stores the new password hash, invalidates the user's other pending
+// resets and signs out all of their sessions
+func ResetPassword(db *sql.DB, userID int64, nonceHash, hash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE password_resets
+		SET used_at = ?
+		WHERE nonce_hash = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?
+	`, now, nonceHash, userID, now)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return ErrResetUsed
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password = ?, password_set = TRUE WHERE id = ?", hash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE user_sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
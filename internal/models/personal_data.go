@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PersonalDataVersion is the format version written by ExportPersonalData
+const PersonalDataVersion = 1
+
+// PersonalData is everything stored about a user, for data access requests. Content is the same archive
+// that ExportArchive produces; Records holds the remaining tables as rows of column names to values.
+// Password hashes, token hashes and two-factor secrets are left out.
+type PersonalData struct {
+	Version    int                                 `json:"version"`
+	ExportedAt time.Time                           `json:"exported_at"`
+	Account    PersonalAccount                     `json:"account"`
+	Content    *Archive                            `json:"content"`
+	Records    map[string][]map[string]interface{} `json:"records"`
+}
+
+type PersonalAccount struct {
+	ID                  int64      `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email,omitempty"`
+	Role                string     `json:"role"`
+	CreatedAt           time.Time  `json:"created_at"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+// personalDataQueries lists what is exported besides the archive. Each query takes the user's ID as its only argument.
+var personalDataQueries = []struct {
+	name  string
+	query string
+}{
+	{"preferences", `SELECT timezone, goal_new_words, goal_reviews, goal_minutes, reminder_enabled, reminder_hour,
+		reminder_channel, reminder_target, last_reminded_on FROM user_preferences WHERE user_id = ?`},
+	{"scheduler_settings", `SELECT algorithm, request_retention, fsrs_weights, optimized_at, leitner_intervals
+		FROM scheduler_settings WHERE user_id = ?`},
+	{"vocabulary_schedules", `SELECT vocabulary_id, reps, lapses, last_review, due_at, ease_factor, interval_days,
+		stability, difficulty, box FROM vocabulary_schedules WHERE user_id = ? ORDER BY vocabulary_id`},
+	{"review_sessions", `SELECT id, mode, deck_id, tag, status, created_at, completed_at
+		FROM review_sessions WHERE user_id = ? ORDER BY id`},
+	{"review_session_cards", `SELECT c.session_id, c.vocabulary_id, c.position, c.prompt, c.answer, c.correct, c.answered_at
+		FROM review_session_cards c JOIN review_sessions s ON s.id = c.session_id
+		WHERE s.user_id = ? ORDER BY c.session_id, c.position`},
+	{"quiz_questions", `SELECT vocabulary_id, mode, correct, created_at, answered_at
+		FROM quiz_questions WHERE user_id = ? ORDER BY id`},
+	{"daily_activity", `SELECT day, minutes_read FROM daily_activity WHERE user_id = ? ORDER BY day`},
+	{"streaks", `SELECT current_streak, longest_streak, last_day FROM user_streaks WHERE user_id = ?`},
+	{"sessions", `SELECT id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at
+		FROM user_sessions WHERE user_id = ? ORDER BY id`},
+	{"auth_events", `SELECT event, detail, ip, user_agent, created_at FROM auth_audit_log WHERE user_id = ? ORDER BY id`},
+	{"password_resets", `SELECT created_at, expires_at, used_at FROM password_resets WHERE user_id = ? ORDER BY id`},
+	{"two_factor", `SELECT confirmed_at, created_at FROM user_totp WHERE user_id = ?`},
+	{"recovery_codes", `SELECT used_at, created_at FROM user_recovery_codes WHERE user_id = ? ORDER BY id`},
+	{"identities", `SELECT issuer, subject, email, created_at, last_login_at FROM user_identities WHERE user_id = ? ORDER BY id`},
+	{"api_tokens", `SELECT name, prefix, scopes, created_at, last_used_at, expires_at, revoked_at
+		FROM api_tokens WHERE user_id = ? ORDER BY id`},
+	{"classes_taught", `SELECT id, name, join_code, created_at FROM classes WHERE teacher_id = ? ORDER BY id`},
+	{"class_memberships", `SELECT c.id, c.name, m.joined_at FROM class_members m JOIN classes c ON c.id = m.class_id
+		WHERE m.user_id = ? ORDER BY m.joined_at`},
+}
+
+// ExportPersonalData collects everything stored about a user, or returns nil if the user does not exist
+func ExportPersonalData(db *sql.DB, userID int64) (*PersonalData, error) {
+	user, err := GetUserByID(db, userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	content, err := ExportArchive(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &PersonalData{
+		Version:    PersonalDataVersion,
+		ExportedAt: time.Now().UTC(),
+		Account: PersonalAccount{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Email:               user.Email,
+			Role:                user.Role,
+			CreatedAt:           user.CreatedAt,
+			DisabledAt:          user.DisabledAt,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		},
+		Content: content,
+		Records: make(map[string][]map[string]interface{}, len(personalDataQueries)),
+	}
+
+	for _, q := range personalDataQueries {
+		records := []map[string]interface{}{}
+		err := eachRow(db, q.query, userID, func(rows *sql.Rows) error {
+			record, err := scanRecord(rows)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		data.Records[q.name] = records
+	}
+
+	return data, nil
+}
+
+// scanRecord reads the current row into a map keyed by column name
+func scanRecord(rows *sql.Rows) (map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		// 文字欄位以 []byte 回傳，轉成字串以免被編碼為 base64
+		if b, ok := values[i].([]byte); ok {
+			record[column] = string(b)
+		} else {
+			record[column] = values[i]
+		}
+	}
+	return record, nil
+}
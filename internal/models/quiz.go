@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// QuizQuestionTTL is how long a question handed out by a quiz can be answered
+const QuizQuestionTTL = 24 * time.Hour
+
+// ErrQuestionAnswered is returned when answering a quiz question a second time
+var ErrQuestionAnswered = errors.New("question has already been answered")
+
+// QuizQuestion records which word and mode a quiz question was issued for, so that answers are graded
+// against the question that was actually asked
+type QuizQuestion struct {
+	ID           int64
+	UserID       int64
+	VocabularyID int64
+	Mode         string
+	CreatedAt    time.Time
+}
+
+// CreateQuizQuestions stores the questions of a new quiz and returns their IDs in the same order as wordIDs.
+// Expired questions of the user are removed at the same time.
+func CreateQuizQuestions(db *sql.DB, userID int64, mode string, wordIDs []int64) ([]int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec("DELETE FROM quiz_questions WHERE user_id = ? AND created_at < ?", userID, now.Add(-QuizQuestionTTL)); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(wordIDs))
+	for i, wordID := range wordIDs {
+		result, err := tx.Exec(`
+			INSERT INTO quiz_questions (user_id, vocabulary_id, mode, created_at)
+			VALUES (?, ?, ?, ?)
+		`, userID, wordID, mode, now)
+		if err != nil {
+			return nil, err
+		}
+		if ids[i], err = result.LastInsertId(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, tx.Commit()
+}
+
+// GetQuizQuestion returns one of the user's unexpired questions, or nil if there is none with this ID
+func GetQuizQuestion(db *sql.DB, userID, id int64) (*QuizQuestion, error) {
+	q := &QuizQuestion{}
+	err := db.QueryRow(`
+		SELECT id, user_id, vocabulary_id, mode, created_at
+		FROM quiz_questions
+		WHERE id = ? AND user_id = ? AND created_at >= ?
+	`, id, userID, time.Now().Add(-QuizQuestionTTL)).Scan(&q.ID, &q.UserID, &q.VocabularyID, &q.Mode, &q.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// AnswerQuizQuestion marks the question answered, saves the result as a test result and updates the word's
+// tested status. It returns ErrQuestionAnswered if the question already has an answer.
+func AnswerQuizQuestion(db *sql.DB, q *QuizQuestion, correct bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE quiz_questions SET correct = ?, answered_at = ?
+		WHERE id = ? AND user_id = ? AND answered_at IS NULL
+	`, correct, time.Now(), q.ID, q.UserID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return ErrQuestionAnswered
+	} else if err != nil {
+		return err
+	}
+
+	if err := recordReviewInTx(tx, q.UserID, q.VocabularyID, q.Mode, correct); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE vocabularies SET tested = ? WHERE id = ? AND user_id = ?", correct, q.VocabularyID, q.UserID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,256 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrCardAnswered is returned when answering a card that already has an answer
+var ErrCardAnswered = errors.New("card has already been answered")
+
+// ReviewSession is a server-side flashcard session; its cards are answered in order
+type ReviewSession struct {
+	ID          int64
+	UserID      int64
+	Mode        string // flip, choice, typing, cloze
+	DeckID      int64  // 0 表示不限牌組
+	Tag         string
+	Status      string // active, completed
+	CreatedAt   time.Time
+	CompletedAt time.Time
+
+	// 由卡片統計而來
+	Total    int
+	Answered int
+	Correct  int
+}
+
+// ReviewCard is one question in a review session, generated when the session is created
+type ReviewCard struct {
+	ID           int64
+	SessionID    int64
+	VocabularyID int64
+	Word         string
+	Position     int
+	Prompt       string
+	PartOfSpeech string
+	Definition   string
+	Hint         string
+	Options      []string
+	Answer       string
+	Correct      bool
+	Answered     bool
+	AnsweredAt   time.Time
+}
+
+// CreateReviewSession stores a session and its cards, returning the session ID
+func CreateReviewSession(db *sql.DB, s *ReviewSession, cards []ReviewCard) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO review_sessions (user_id, mode, deck_id, tag)
+		VALUES (?, ?, ?, ?)
+	`, s.UserID, s.Mode, nullInt64(s.DeckID), nullString(s.Tag))
+	if err != nil {
+		return 0, err
+	}
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, card := range cards {
+		var options sql.NullString
+		if len(card.Options) > 0 {
+			b, err := json.Marshal(card.Options)
+			if err != nil {
+				return 0, err
+			}
+			options = sql.NullString{String: string(b), Valid: true}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO review_session_cards (session_id, vocabulary_id, position, prompt, part_of_speech, definition, hint, options)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, sessionID, card.VocabularyID, i, card.Prompt, truncate(card.PartOfSpeech, 50), nullString(card.Definition),
+			nullString(card.Hint), options)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return sessionID, tx.Commit()
+}
+
+const reviewSessionColumns = `
+	s.id, s.user_id, s.mode, COALESCE(s.deck_id, 0), COALESCE(s.tag, ''), s.status, s.created_at, s.completed_at,
+	COUNT(c.id), COUNT(c.answered_at), COALESCE(SUM(c.correct), 0)
+`
+
+// GetReviewSession retrieves one of the user's sessions with its progress, or nil if it does not exist
+func GetReviewSession(db *sql.DB, userID, id int64) (*ReviewSession, error) {
+	return scanReviewSession(db.QueryRow(`
+		SELECT `+reviewSessionColumns+`
+		FROM review_sessions s
+		LEFT JOIN review_session_cards c ON c.session_id = s.id
+		WHERE s.id = ? AND s.user_id = ?
+		GROUP BY s.id
+	`, id, userID))
+}
+
+// GetActiveReviewSession returns the user's most recent unfinished session, or nil if there is none
+func GetActiveReviewSession(db *sql.DB, userID int64) (*ReviewSession, error) {
+	return scanReviewSession(db.QueryRow(`
+		SELECT `+reviewSessionColumns+`
+		FROM review_sessions s
+		LEFT JOIN review_session_cards c ON c.session_id = s.id
+		WHERE s.user_id = ? AND s.status = 'active'
+		GROUP BY s.id
+		ORDER BY s.id DESC
+		LIMIT 1
+	`, userID))
+}
+
+func scanReviewSession(row *sql.Row) (*ReviewSession, error) {
+	s := &ReviewSession{}
+	var completedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.Mode, &s.DeckID, &s.Tag, &s.Status, &s.CreatedAt, &completedAt,
+		&s.Total, &s.Answered, &s.Correct)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.CompletedAt = completedAt.Time
+	return s, nil
+}
+
+// GetReviewCards returns all cards of a session in order
+func GetReviewCards(db *sql.DB, sessionID int64) ([]ReviewCard, error) {
+	rows, err := db.Query(`
+		SELECT `+reviewCardColumns+`
+		FROM review_session_cards c
+		JOIN vocabularies v ON v.id = c.vocabulary_id
+		WHERE c.session_id = ?
+		ORDER BY c.position
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []ReviewCard
+	for rows.Next() {
+		card, err := scanReviewCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, *card)
+	}
+	return cards, rows.Err()
+}
+
+// NextReviewCard returns the first unanswered card of a session, or nil when all are answered
+func NextReviewCard(db *sql.DB, sessionID int64) (*ReviewCard, error) {
+	card, err := scanReviewCard(db.QueryRow(`
+		SELECT `+reviewCardColumns+`
+		FROM review_session_cards c
+		JOIN vocabularies v ON v.id = c.vocabulary_id
+		WHERE c.session_id = ? AND c.answered_at IS NULL
+		ORDER BY c.position
+		LIMIT 1
+	`, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return card, err
+}
+
+const reviewCardColumns = `
+	c.id, c.session_id, c.vocabulary_id, v.word, c.position, c.prompt, c.part_of_speech, COALESCE(c.definition, ''),
+	COALESCE(c.hint, ''), COALESCE(c.options, ''), COALESCE(c.answer, ''), COALESCE(c.correct, FALSE), c.answered_at
+`
+
+func scanReviewCard(row interface{ Scan(...interface{}) error }) (*ReviewCard, error) {
+	card := &ReviewCard{}
+	var options string
+	var answeredAt sql.NullTime
+	err := row.Scan(&card.ID, &card.SessionID, &card.VocabularyID, &card.Word, &card.Position, &card.Prompt,
+		&card.PartOfSpeech, &card.Definition, &card.Hint, &options, &card.Answer, &card.Correct, &answeredAt)
+	if err != nil {
+		return nil, err
+	}
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &card.Options); err != nil {
+			return nil, err
+		}
+	}
+	card.Answered = answeredAt.Valid
+	card.AnsweredAt = answeredAt.Time
+	return card, nil
+}
+
+// AnswerReviewCard records the graded answer for a card, saves it as a test result and
+// completes the session once every card is answered
+func AnswerReviewCard(db *sql.DB, s *ReviewSession, card *ReviewCard, answer string, correct bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE review_session_cards
+		SET answer = ?, correct = ?, answered_at = ?
+		WHERE id = ? AND session_id = ? AND answered_at IS NULL
+	`, truncate(answer, 255), correct, time.Now(), card.ID, s.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		return ErrCardAnswered
+	} else if err != nil {
+		return err
+	}
+
+	if err := recordReviewInTx(tx, s.UserID, card.VocabularyID, s.Mode, correct); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE vocabularies SET tested = ? WHERE id = ? AND user_id = ?", correct, card.VocabularyID, s.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE review_sessions
+		SET status = 'completed', completed_at = ?
+		WHERE id = ? AND NOT EXISTS (
+			SELECT 1 FROM review_session_cards WHERE session_id = ? AND answered_at IS NULL
+		)
+	`, time.Now(), s.ID, s.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CompleteReviewSession ends a session early, leaving unanswered cards unanswered
+func CompleteReviewSession(db *sql.DB, userID, id int64) error {
+	result, err := db.Exec(`
+		UPDATE review_sessions
+		SET status = 'completed', completed_at = ?
+		WHERE id = ? AND user_id = ? AND status = 'active'
+	`, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
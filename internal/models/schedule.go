@@ -0,0 +1,341 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"vocabulary/internal/scheduler"
+)
+
+// SchedulerSettings is a user's choice of review scheduling algorithm
+type SchedulerSettings struct {
+	UserID           int64
+	Algorithm        string
+	RequestRetention float64
+	Weights          []float64 // FSRS 參數，nil 表示使用預設值
+	OptimizedAt      time.Time
+	LeitnerIntervals []float64 // 每個盒子的間隔天數，nil 表示使用預設的五個盒子
+}
+
+// Scheduler returns the scheduler configured by these settings
+func (s *SchedulerSettings) Scheduler() scheduler.Scheduler {
+	return scheduler.New(scheduler.Config{
+		Algorithm:        s.Algorithm,
+		Weights:          s.Weights,
+		RequestRetention: s.RequestRetention,
+		LeitnerIntervals: s.LeitnerIntervals,
+	})
+}
+
+// WordSchedule is the scheduling state of one of the user's words
+type WordSchedule struct {
+	VocabularyID int64
+	Word         string
+	scheduler.State
+}
+
+// GetSchedulerSettings returns the user's settings, or the defaults if none are saved
+func GetSchedulerSettings(db *sql.DB, userID int64) (*SchedulerSettings, error) {
+	return getSchedulerSettings(db, userID)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getSchedulerSettings(q queryRower, userID int64) (*SchedulerSettings, error) {
+	s := &SchedulerSettings{UserID: userID}
+	var weights, intervals sql.NullString
+	var optimizedAt sql.NullTime
+	err := q.QueryRow(`
+		SELECT algorithm, request_retention, fsrs_weights, optimized_at, leitner_intervals
+		FROM scheduler_settings
+		WHERE user_id = ?
+	`, userID).Scan(&s.Algorithm, &s.RequestRetention, &weights, &optimizedAt, &intervals)
+	if err == sql.ErrNoRows {
+		s.Algorithm = scheduler.SM2
+		s.RequestRetention = scheduler.DefaultRequestRetention
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if weights.Valid && weights.String != "" {
+		if err := json.Unmarshal([]byte(weights.String), &s.Weights); err != nil {
+			return nil, err
+		}
+	}
+	if intervals.Valid && intervals.String != "" {
+		if err := json.Unmarshal([]byte(intervals.String), &s.LeitnerIntervals); err != nil {
+			return nil, err
+		}
+	}
+	s.OptimizedAt = optimizedAt.Time
+	return s, nil
+}
+
+// SaveSchedulerSettings stores the user's settings and recomputes every word's schedule with them
+func SaveSchedulerSettings(db *sql.DB, s *SchedulerSettings) error {
+	weights, err := nullJSON(s.Weights)
+	if err != nil {
+		return err
+	}
+	intervals, err := nullJSON(s.LeitnerIntervals)
+	if err != nil {
+		return err
+	}
+	var optimizedAt sql.NullTime
+	if !s.OptimizedAt.IsZero() {
+		optimizedAt = sql.NullTime{Time: s.OptimizedAt, Valid: true}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO scheduler_settings (user_id, algorithm, request_retention, fsrs_weights, optimized_at, leitner_intervals)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			algorithm = VALUES(algorithm),
+			request_retention = VALUES(request_retention),
+			fsrs_weights = VALUES(fsrs_weights),
+			optimized_at = VALUES(optimized_at),
+			leitner_intervals = VALUES(leitner_intervals)
+	`, s.UserID, s.Algorithm, s.RequestRetention, weights, optimizedAt, intervals)
+	if err != nil {
+		return err
+	}
+
+	return RebuildSchedules(db, s.UserID)
+}
+
+// nullJSON encodes a parameter list as JSON, or NULL when it is nil
+func nullJSON(values []float64) (sql.NullString, error) {
+	if values == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+// GetReviewHistories returns the user's recorded answers grouped by word, oldest first
+func GetReviewHistories(db *sql.DB, userID int64) (map[int64][]scheduler.Review, error) {
+	rows, err := db.Query(`
+		SELECT word_id, correct, created_at
+		FROM test_results
+		WHERE user_id = ?
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := make(map[int64][]scheduler.Review)
+	for rows.Next() {
+		var wordID int64
+		var r scheduler.Review
+		if err := rows.Scan(&wordID, &r.Correct, &r.At); err != nil {
+			return nil, err
+		}
+		histories[wordID] = append(histories[wordID], r)
+	}
+	return histories, rows.Err()
+}
+
+// RebuildSchedules replays the user's whole review history with their current scheduler
+func RebuildSchedules(db *sql.DB, userID int64) error {
+	settings, err := GetSchedulerSettings(db, userID)
+	if err != nil {
+		return err
+	}
+	histories, err := GetReviewHistories(db, userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM vocabulary_schedules WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	s := settings.Scheduler()
+	for wordID, reviews := range histories {
+		if err := saveScheduleInTx(tx, userID, wordID, scheduler.Replay(s, reviews)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetWordSchedules returns the schedule of every active word; words never reviewed have a zero state
+func GetWordSchedules(db *sql.DB, userID int64) ([]WordSchedule, error) {
+	rows, err := db.Query(`
+		SELECT v.id, v.word, COALESCE(s.reps, 0), COALESCE(s.lapses, 0), s.last_review, s.due_at,
+			COALESCE(s.ease_factor, 0), COALESCE(s.interval_days, 0), COALESCE(s.stability, 0), COALESCE(s.difficulty, 0),
+			COALESCE(s.box, 0)
+		FROM vocabularies v
+		LEFT JOIN vocabulary_schedules s ON s.vocabulary_id = v.id
+		WHERE v.user_id = ? AND v.status = 'active'
+		ORDER BY s.due_at IS NULL, s.due_at, v.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []WordSchedule
+	for rows.Next() {
+		var ws WordSchedule
+		var lastReview, due sql.NullTime
+		err := rows.Scan(&ws.VocabularyID, &ws.Word, &ws.Reps, &ws.Lapses, &lastReview, &due,
+			&ws.EaseFactor, &ws.Interval, &ws.Stability, &ws.Difficulty, &ws.Box)
+		if err != nil {
+			return nil, err
+		}
+		ws.LastReview = lastReview.Time
+		ws.Due = due.Time
+		schedules = append(schedules, ws)
+	}
+	return schedules, rows.Err()
+}
+
+// recordReviewInTx saves a graded answer and advances the word's schedule
+func recordReviewInTx(tx *sql.Tx, userID, wordID int64, mode string, correct bool) error {
+	// 確認單字屬於此使用者，避免寫入他人單字的排程
+	var owned int
+	err := tx.QueryRow("SELECT 1 FROM vocabularies WHERE id = ? AND user_id = ?", wordID, userID).Scan(&owned)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO test_results (user_id, word_id, correct, mode, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, wordID, correct, mode, now)
+	if err != nil {
+		return err
+	}
+
+	settings, err := getSchedulerSettings(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	var state scheduler.State
+	var lastReview, due sql.NullTime
+	err = tx.QueryRow(`
+		SELECT reps, lapses, last_review, due_at, ease_factor, interval_days, stability, difficulty, box
+		FROM vocabulary_schedules
+		WHERE vocabulary_id = ?
+	`, wordID).Scan(&state.Reps, &state.Lapses, &lastReview, &due, &state.EaseFactor, &state.Interval,
+		&state.Stability, &state.Difficulty, &state.Box)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	state.LastReview = lastReview.Time
+	state.Due = due.Time
+
+	return saveScheduleInTx(tx, userID, wordID, settings.Scheduler().Review(state, correct, now))
+}
+
+func saveScheduleInTx(tx *sql.Tx, userID, wordID int64, s scheduler.State) error {
+	_, err := tx.Exec(`
+		INSERT INTO vocabulary_schedules
+			(vocabulary_id, user_id, reps, lapses, last_review, due_at, ease_factor, interval_days, stability, difficulty, box)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			reps = VALUES(reps),
+			lapses = VALUES(lapses),
+			last_review = VALUES(last_review),
+			due_at = VALUES(due_at),
+			ease_factor = VALUES(ease_factor),
+			interval_days = VALUES(interval_days),
+			stability = VALUES(stability),
+			difficulty = VALUES(difficulty),
+			box = VALUES(box)
+	`, wordID, userID, s.Reps, s.Lapses, s.LastReview, s.Due, s.EaseFactor, s.Interval, s.Stability, s.Difficulty, s.Box)
+	return err
+}
+
+// LeitnerBox counts the active words in one Leitner box
+type LeitnerBox struct {
+	Box   int
+	Words int
+	Due   int
+}
+
+// GetLeitnerBoxes counts the user's active words per box; box 0 holds words never reviewed
+func GetLeitnerBoxes(db *sql.DB, userID int64) ([]LeitnerBox, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(s.box, 0) AS b, COUNT(*), COALESCE(SUM(s.due_at IS NULL OR s.due_at <= ?), 0)
+		FROM vocabularies v
+		LEFT JOIN vocabulary_schedules s ON s.vocabulary_id = v.id
+		WHERE v.user_id = ? AND v.status = 'active'
+		GROUP BY b
+		ORDER BY b
+	`, time.Now(), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var boxes []LeitnerBox
+	for rows.Next() {
+		var b LeitnerBox
+		if err := rows.Scan(&b.Box, &b.Words, &b.Due); err != nil {
+			return nil, err
+		}
+		boxes = append(boxes, b)
+	}
+	return boxes, rows.Err()
+}
+
+// DueSummary describes the words waiting for review
+type DueSummary struct {
+	Due   int      // 已到期的單字數
+	New   int      // 從未複習的單字數
+	Words []string // 最早到期的幾個單字
+}
+
+// GetDueSummary counts the user's due and never-reviewed words and lists up to limit of the most overdue
+func GetDueSummary(db *sql.DB, userID int64, now time.Time, limit int) (*DueSummary, error) {
+	d := &DueSummary{Words: []string{}}
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(s.due_at <= ?), 0), COALESCE(SUM(s.vocabulary_id IS NULL), 0)
+		FROM vocabularies v
+		LEFT JOIN vocabulary_schedules s ON s.vocabulary_id = v.id
+		WHERE v.user_id = ? AND v.status = 'active'
+	`, now, userID).Scan(&d.Due, &d.New)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT v.word
+		FROM vocabulary_schedules s
+		JOIN vocabularies v ON v.id = s.vocabulary_id
+		WHERE s.user_id = ? AND v.status = 'active' AND s.due_at <= ?
+		ORDER BY s.due_at
+		LIMIT ?
+	`, userID, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		d.Words = append(d.Words, word)
+	}
+	return d, rows.Err()
+}
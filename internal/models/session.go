@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSessionInvalid is returned for refresh tokens that are unknown, expired or revoked
+	ErrSessionInvalid = errors.New("session is invalid or has been revoked")
+	// ErrRefreshReused is returned when an already rotated refresh token is presented again; the session is revoked
+	ErrRefreshReused = errors.New("refresh token was reused")
+)
+
+// RefreshReuseGrace is how long a just-rotated refresh token is still accepted, so that concurrent requests
+// from the same browser do not look like token theft
+const RefreshReuseGrace = 30 * time.Second
+
+// Session is a signed-in device; its refresh token is stored only as a SHA-256 hash
+type Session struct {
+	ID          int64
+	UserID      int64
+	RefreshHash string
+	UserAgent   string
+	IP          string
+	CreatedAt   time.Time
+	LastUsedAt  time.Time
+	ExpiresAt   time.Time
+}
+
+// CreateSession stores a new session and returns its ID
+func CreateSession(db *sql.DB, s *Session) (int64, error) {
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO user_sessions (user_id, refresh_hash, user_agent, ip, created_at, last_used_at, rotated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.UserID, s.RefreshHash, truncate(s.UserAgent, 255), truncate(s.IP, 45), now, now, now, s.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// RotateSession replaces a session's refresh token. A token rotated within RefreshReuseGrace returns the
+// session without rotating again (rotated is false); an older rotated token revokes the whole session.
+func RotateSession(db *sql.DB, oldHash, newHash string, expiresAt time.Time) (s *Session, rotated bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	s = &Session{}
+	var current string
+	var rotatedAt time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, refresh_hash, user_agent, ip, created_at, last_used_at, rotated_at, expires_at, revoked_at
+		FROM user_sessions
+		WHERE refresh_hash = ? OR previous_hash = ?
+		FOR UPDATE
+	`, oldHash, oldHash).Scan(&s.ID, &s.UserID, &current, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt,
+		&rotatedAt, &s.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, ErrSessionInvalid
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	if revokedAt.Valid || !s.ExpiresAt.After(now) {
+		return nil, false, ErrSessionInvalid
+	}
+
+	if current != oldHash {
+		if now.Sub(rotatedAt) <= RefreshReuseGrace {
+			return s, false, nil
+		}
+		// 舊的 refresh token 再次出現，視為遭竊並撤銷整個工作階段
+		if _, err := tx.Exec("UPDATE user_sessions SET revoked_at = ? WHERE id = ?", now, s.ID); err != nil {
+			return nil, false, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, false, err
+		}
+		return nil, false, ErrRefreshReused
+	}
+
+	_, err = tx.Exec(`
+		UPDATE user_sessions
+		SET previous_hash = refresh_hash, refresh_hash = ?, last_used_at = ?, rotated_at = ?, expires_at = ?
+		WHERE id = ?
+	`, newHash, now, now, expiresAt, s.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	s.RefreshHash = newHash
+	s.LastUsedAt = now
+	s.ExpiresAt = expiresAt
+	return s, true, tx.Commit()
+}
+
+// IsSessionActive reports whether the user's session exists and has been neither revoked nor expired
+func IsSessionActive(db *sql.DB, userID, sessionID int64) (bool, error) {
+	var active bool
+	err := db.QueryRow(`
+		SELECT revoked_at IS NULL AND expires_at > ?
+		FROM user_sessions
+		WHERE id = ? AND user_id = ?
+	`, time.Now(), sessionID, userID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return active, err
+}
+
+// GetActiveSessions lists the user's signed-in devices, most recently used first
+func GetActiveSessions(db *sql.DB, userID int64) ([]Session, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
+		FROM user_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY last_used_at DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends one of the user's sessions
+func RevokeSession(db *sql.DB, userID, sessionID int64) error {
+	result, err := db.Exec(`
+		UPDATE user_sessions
+		SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now(), sessionID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RevokeAllSessions ends every session of the user except keepID (0 keeps none) and returns how many were ended
+func RevokeAllSessions(db *sql.DB, userID, keepID int64) (int64, error) {
+	result, err := db.Exec(`
+		UPDATE user_sessions
+		SET revoked_at = ?
+		WHERE user_id = ? AND id <> ? AND revoked_at IS NULL
+	`, time.Now(), userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RevokeSessionByRefresh ends the session a refresh token belongs to, if any
+func RevokeSessionByRefresh(db *sql.DB, refreshHash string) error {
+	_, err := db.Exec(`
+		UPDATE user_sessions
+		SET revoked_at = ?
+		WHERE (refresh_hash = ? OR previous_hash = ?) AND revoked_at IS NULL
+	`, time.Now(), refreshHash, refreshHash)
+	return err
+}
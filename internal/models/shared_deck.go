@@ -0,0 +1,171 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SharedDeck is a public word list curated by administrators, e.g. "GRE 1000"
+type SharedDeck struct {
+	ID          int64
+	Name        string
+	Description string
+	WordCount   int
+	CreatedAt   time.Time
+	Words       []SharedDeckWord
+}
+
+type SharedDeckWord struct {
+	Word        string
+	Definitions []VocabularyDefinition
+}
+
+// GetSharedDecks lists all public decks with their word counts
+func GetSharedDecks(db *sql.DB) ([]SharedDeck, error) {
+	rows, err := db.Query(`
+		SELECT d.id, d.name, d.description, d.created_at, COUNT(DISTINCT w.word)
+		FROM shared_decks d
+		LEFT JOIN shared_deck_words w ON w.deck_id = d.id
+		GROUP BY d.id, d.name, d.description, d.created_at
+		ORDER BY d.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decks []SharedDeck
+	for rows.Next() {
+		var d SharedDeck
+		if err := rows.Scan(&d.ID, &d.Name, &d.Description, &d.CreatedAt, &d.WordCount); err != nil {
+			return nil, err
+		}
+		decks = append(decks, d)
+	}
+
+	return decks, rows.Err()
+}
+
+// GetSharedDeck retrieves a deck and its words, or nil if it does not exist
+func GetSharedDeck(db *sql.DB, id int64) (*SharedDeck, error) {
+	var d SharedDeck
+	err := db.QueryRow(`
+		SELECT id, name, description, created_at
+		FROM shared_decks
+		WHERE id = ?
+	`, id).Scan(&d.ID, &d.Name, &d.Description, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT word, part_of_speech, definition, example
+		FROM shared_deck_words
+		WHERE deck_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// 同一個單字的多個定義合併為一筆
+	index := make(map[string]int)
+	for rows.Next() {
+		var word string
+		var def VocabularyDefinition
+		if err := rows.Scan(&word, &def.PartOfSpeech, &def.Definition, &def.Example); err != nil {
+			return nil, err
+		}
+		i, ok := index[word]
+		if !ok {
+			i = len(d.Words)
+			index[word] = i
+			d.Words = append(d.Words, SharedDeckWord{Word: word})
+		}
+		d.Words[i].Definitions = append(d.Words[i].Definitions, def)
+	}
+	d.WordCount = len(d.Words)
+
+	return &d, rows.Err()
+}
+
+// CreateSharedDeck creates a public deck with its words and returns its ID
+func CreateSharedDeck(db *sql.DB, name, description string, words []SharedDeckWord) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO shared_decks (name, description) VALUES (?, ?)`, name, description)
+	if err != nil {
+		return 0, err
+	}
+	deckID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertSharedDeckWords(tx, deckID, words); err != nil {
+		return 0, err
+	}
+
+	return deckID, tx.Commit()
+}
+
+// UpdateSharedDeck replaces a deck's name, description and words
+func UpdateSharedDeck(db *sql.DB, id int64, name, description string, words []SharedDeckWord) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE shared_decks SET name = ?, description = ? WHERE id = ?`, name, description, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM shared_decks WHERE id = ?", id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return sql.ErrNoRows
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM shared_deck_words WHERE deck_id = ?", id); err != nil {
+		return err
+	}
+	if err := insertSharedDeckWords(tx, id, words); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSharedDeck removes a deck and its words
+func DeleteSharedDeck(db *sql.DB, id int64) error {
+	_, err := db.Exec("DELETE FROM shared_decks WHERE id = ?", id)
+	return err
+}
+
+func insertSharedDeckWords(tx *sql.Tx, deckID int64, words []SharedDeckWord) error {
+	for _, w := range words {
+		for _, def := range w.Definitions {
+			_, err := tx.Exec(`
+				INSERT INTO shared_deck_words (deck_id, word, part_of_speech, definition, example)
+				VALUES (?, ?, ?, ?, ?)
+			`, deckID, w.Word, def.PartOfSpeech, def.Definition, def.Example)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
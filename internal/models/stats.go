@@ -0,0 +1,244 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// DayFormat is the layout of calendar dates used for daily statistics and goals
+const DayFormat = "2006-01-02"
+
+// DailyCount is a per-day aggregate; Correct is only set for reviews
+type DailyCount struct {
+	Date    string
+	Count   int
+	Correct int
+}
+
+// RetentionPoint is the share of correct answers given a number of days after the previous review
+type RetentionPoint struct {
+	Days    int
+	Reviews int
+	Correct int
+}
+
+// MasteryLevels counts active words by how long their current review interval is
+type MasteryLevels struct {
+	New      int // 從未複習
+	Learning int // 間隔少於 7 天
+	Young    int // 間隔 7–20 天
+	Mature   int // 間隔 21 天以上
+}
+
+// WordAccuracy is a word's answer record across all reviews
+type WordAccuracy struct {
+	VocabularyID int64
+	Word         string
+	Reviews      int
+	Correct      int
+}
+
+// 回溯保留率曲線的最長天數，更久的間隔併入最後一點
+const maxRetentionDays = 60
+
+// GetWordsAddedPerDay counts the words added on each local day since the given time
+func GetWordsAddedPerDay(db *sql.DB, userID int64, since time.Time, loc *time.Location) ([]DailyCount, error) {
+	return queryDailyCounts(db, loc, `
+		SELECT created_at, 0
+		FROM vocabularies
+		WHERE user_id = ? AND created_at >= ?
+	`, userID, since)
+}
+
+// GetReviewsPerDay counts the reviews and correct answers on each local day since the given time
+func GetReviewsPerDay(db *sql.DB, userID int64, since time.Time, loc *time.Location) ([]DailyCount, error) {
+	return queryDailyCounts(db, loc, `
+		SELECT created_at, correct
+		FROM test_results
+		WHERE user_id = ? AND created_at >= ?
+	`, userID, since)
+}
+
+// GetDueForecast counts the reviews falling due on each local day until the given time; overdue words count
+// towards today
+func GetDueForecast(db *sql.DB, userID int64, now, until time.Time, loc *time.Location) ([]DailyCount, error) {
+	counts, err := queryDailyCounts(db, loc, `
+		SELECT s.due_at, 0
+		FROM vocabulary_schedules s
+		JOIN vocabularies v ON v.id = s.vocabulary_id
+		WHERE s.user_id = ? AND v.status = 'active' AND s.due_at < ?
+	`, userID, until)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已過期的日期併入今天
+	today := StartOfDay(now, loc).Format(DayFormat)
+	forecast := []DailyCount{{Date: today}}
+	for _, dc := range counts {
+		if dc.Date <= today {
+			forecast[0].Count += dc.Count
+		} else {
+			forecast = append(forecast, dc)
+		}
+	}
+	if forecast[0].Count == 0 {
+		forecast = forecast[1:]
+	}
+	return forecast, nil
+}
+
+// queryDailyCounts groups rows of (time, correct) by their day in loc, in date order
+func queryDailyCounts(db *sql.DB, loc *time.Location, query string, args ...interface{}) ([]DailyCount, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byDay := make(map[string]*DailyCount)
+	for rows.Next() {
+		var at time.Time
+		var correct bool
+		if err := rows.Scan(&at, &correct); err != nil {
+			return nil, err
+		}
+		day := at.In(loc).Format(DayFormat)
+		dc, ok := byDay[day]
+		if !ok {
+			dc = &DailyCount{Date: day}
+			byDay[day] = dc
+		}
+		dc.Count++
+		if correct {
+			dc.Correct++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make([]DailyCount, 0, len(byDay))
+	for _, dc := range byDay {
+		counts = append(counts, *dc)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Date < counts[j].Date })
+	return counts, nil
+}
+
+// GetRetentionCurve groups every review after a word's first by the whole days since its previous review
+func GetRetentionCurve(db *sql.DB, userID int64) ([]RetentionPoint, error) {
+	rows, err := db.Query(`
+		SELECT LEAST(gap, ?) AS days, COUNT(*), COALESCE(SUM(correct), 0)
+		FROM (
+			SELECT correct, DATEDIFF(created_at, LAG(created_at) OVER (PARTITION BY word_id ORDER BY created_at, id)) AS gap
+			FROM test_results
+			WHERE user_id = ?
+		) r
+		WHERE gap IS NOT NULL
+		GROUP BY days
+		ORDER BY days
+	`, maxRetentionDays, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []RetentionPoint{}
+	for rows.Next() {
+		var p RetentionPoint
+		if err := rows.Scan(&p.Days, &p.Reviews, &p.Correct); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetReviewStreak counts the consecutive local days with at least one review, ending today, or yesterday if
+// there are none yet today. Reviews are read newest first and only until the first missed day.
+func GetReviewStreak(db *sql.DB, userID int64, now time.Time, loc *time.Location) (int, error) {
+	rows, err := db.Query(`
+		SELECT created_at
+		FROM test_results
+		WHERE user_id = ? AND created_at < ?
+		ORDER BY created_at DESC
+	`, userID, now)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	today := StartOfDay(now, loc)
+	// 下一個應有複習的日期；今天還沒複習時從昨天開始算
+	next, streak := today, 0
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return 0, err
+		}
+		day := StartOfDay(at, loc)
+		if day.Equal(next) {
+			streak++
+			next = day.AddDate(0, 0, -1)
+			continue
+		}
+		if streak == 0 && day.Equal(today.AddDate(0, 0, -1)) {
+			streak = 1
+			next = day.AddDate(0, 0, -1)
+			continue
+		}
+		if day.Before(next) {
+			break
+		}
+	}
+	return streak, rows.Err()
+}
+
+// GetMasteryLevels counts the user's active words per mastery level
+func GetMasteryLevels(db *sql.DB, userID int64) (*MasteryLevels, error) {
+	m := &MasteryLevels{}
+	err := db.QueryRow(`
+		SELECT
+			COALESCE(SUM(s.due_at IS NULL), 0),
+			COALESCE(SUM(DATEDIFF(s.due_at, s.last_review) < 7), 0),
+			COALESCE(SUM(DATEDIFF(s.due_at, s.last_review) BETWEEN 7 AND 20), 0),
+			COALESCE(SUM(DATEDIFF(s.due_at, s.last_review) >= 21), 0)
+		FROM vocabularies v
+		LEFT JOIN vocabulary_schedules s ON s.vocabulary_id = v.id
+		WHERE v.user_id = ? AND v.status = 'active'
+	`, userID).Scan(&m.New, &m.Learning, &m.Young, &m.Mature)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetHardestWords returns the active words with the lowest accuracy among those reviewed at least minReviews times
+func GetHardestWords(db *sql.DB, userID int64, minReviews, limit int) ([]WordAccuracy, error) {
+	rows, err := db.Query(`
+		SELECT v.id, v.word, COUNT(*) AS reviews, COALESCE(SUM(t.correct), 0) AS correct
+		FROM test_results t
+		JOIN vocabularies v ON v.id = t.word_id
+		WHERE t.user_id = ? AND v.status = 'active'
+		GROUP BY v.id, v.word
+		HAVING reviews >= ?
+		ORDER BY correct / reviews, reviews DESC
+		LIMIT ?
+	`, userID, minReviews, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []WordAccuracy{}
+	for rows.Next() {
+		var w WordAccuracy
+		if err := rows.Scan(&w.VocabularyID, &w.Word, &w.Reviews, &w.Correct); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, rows.Err()
+}
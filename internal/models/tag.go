@@ -0,0 +1,206 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrTagExists is returned when renaming a tag to the name of another of the user's tags
+var ErrTagExists = errors.New("tag already exists")
+
+type Tag struct {
+	ID        int64
+	UserID    int64
+	Name      string
+	WordCount int
+	CreatedAt time.Time
+}
+
+// GetTagsByUserID lists a user's tags with the number of active words using each
+func GetTagsByUserID(db *sql.DB, userID int64) ([]Tag, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.user_id, t.name, t.created_at, COUNT(v.id)
+		FROM tags t
+		LEFT JOIN vocabulary_tags vt ON vt.tag_id = t.id
+		LEFT JOIN vocabularies v ON v.id = vt.vocabulary_id AND v.status = 'active'
+		WHERE t.user_id = ?
+		GROUP BY t.id, t.user_id, t.name, t.created_at
+		ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.CreatedAt, &t.WordCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// CreateTag creates a tag for the user, returning the existing ID if the name is taken
+func CreateTag(db *sql.DB, userID int64, name string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := ensureTagInTx(tx, userID, name)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// RenameTag renames one of the user's tags
+func RenameTag(db *sql.DB, userID, id int64, name string) error {
+	result, err := db.Exec("UPDATE tags SET name = ? WHERE id = ? AND user_id = ?", name, id, userID)
+	if isDuplicateEntry(err) {
+		return ErrTagExists
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// 名稱沒有變化時 MySQL 回報 0 筆，需確認標籤是否存在
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM tags WHERE id = ? AND user_id = ?)", id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteTag removes one of the user's tags from all words
+func DeleteTag(db *sql.DB, userID, id int64) error {
+	result, err := db.Exec("DELETE FROM tags WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetTags replaces the tags on a vocabulary word, creating tags that do not exist yet
+func (v *Vocabulary) SetTags(db *sql.DB, names []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setTagsInTx(tx, v.UserID, v.ID, names); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setTagsInTx(tx *sql.Tx, userID, vocabularyID int64, names []string) error {
+	if _, err := tx.Exec("DELETE FROM vocabulary_tags WHERE vocabulary_id = ?", vocabularyID); err != nil {
+		return err
+	}
+
+	for _, name := range NormalizeTags(names) {
+		tagID, err := ensureTagInTx(tx, userID, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT IGNORE INTO vocabulary_tags (vocabulary_id, tag_id)
+			VALUES (?, ?)
+		`, vocabularyID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureTagInTx(tx *sql.Tx, userID int64, name string) (int64, error) {
+	_, err := tx.Exec(`
+		INSERT IGNORE INTO tags (user_id, name)
+		VALUES (?, ?)
+	`, userID, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM tags WHERE user_id = ? AND name = ?", userID, name).Scan(&id)
+	return id, err
+}
+
+// getTagsByVocabulary returns the tag names of every word owned by the user, keyed by vocabulary ID
+func getTagsByVocabulary(db *sql.DB, userID int64) (map[int64][]string, error) {
+	rows, err := db.Query(`
+		SELECT vt.vocabulary_id, t.name
+		FROM vocabulary_tags vt
+		JOIN tags t ON t.id = vt.tag_id
+		WHERE t.user_id = ?
+		ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var vocabularyID int64
+		var name string
+		if err := rows.Scan(&vocabularyID, &name); err != nil {
+			return nil, err
+		}
+		tags[vocabularyID] = append(tags[vocabularyID], name)
+	}
+
+	return tags, rows.Err()
+}
+
+// NormalizeTags trims tag names and drops empty and duplicate names
+func NormalizeTags(names []string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, truncate(name, 50))
+	}
+	return tags
+}
+
+// requireAffected returns sql.ErrNoRows when an update or delete matched nothing
+func requireAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// isDuplicateEntry reports whether an insert or update violated a unique key
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 // ER_DUP_ENTRY
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TOTP is a user's authenticator secret; it only protects logins once Enabled
+type TOTP struct {
+	UserID       int64
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64 // 最後一次成功使用的時間區段，防止同一組驗證碼被重複使用
+	CreatedAt    time.Time
+}
+
+// GetTOTP returns the user's TOTP settings, or nil if they never started enrolment
+func GetTOTP(db *sql.DB, userID int64) (*TOTP, error) {
+	t := &TOTP{}
+	var confirmedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = ?
+	`, userID).Scan(&t.UserID, &t.Secret, &confirmedAt, &t.LastUsedStep, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Enabled = confirmedAt.Valid
+	return t, nil
+}
+
+// HasTOTPEnabled reports whether the user's logins require a second step
+func HasTOTPEnabled(db *sql.DB, userID int64) (bool, error) {
+	t, err := GetTOTP(db, userID)
+	if err != nil {
+		return false, err
+	}
+	return t != nil && t.Enabled, nil
+}
+
+// SavePendingTOTP stores a new, unconfirmed secret; it does nothing if TOTP is already enabled
+func SavePendingTOTP(db *sql.DB, userID int64, secret string) error {
+	result, err := db.Exec(`
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			secret = IF(confirmed_at IS NULL, VALUES(secret), secret),
+			created_at = IF(confirmed_at IS NULL, VALUES(created_at), created_at)
+	`, userID, secret, time.Now())
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// EnableTOTP confirms the pending secret after the first valid code and replaces the recovery codes
+func EnableTOTP(db *sql.DB, userID, step int64, codeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE user_totp
+		SET confirmed_at = ?, last_used_step = ?
+		WHERE user_id = ? AND confirmed_at IS NULL
+	`, time.Now(), step, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodesInTx(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP removes the user's secret and recovery codes
+func DisableTOTP(db *sql.DB, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records a successful code; it returns false if that step or a later one was already used
+func UseTOTPStep(db *sql.DB, userID, step int64) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_totp
+		SET last_used_step = ?
+		WHERE user_id = ? AND confirmed_at IS NOT NULL AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UseRecoveryCode marks an unused recovery code as used; it returns false if there is no such code
+func UseRecoveryCode(db *sql.DB, userID int64, codeHash string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_recovery_codes
+		SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new ones
+func ReplaceRecoveryCodes(db *sql.DB, userID int64, codeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodesInTx(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodesInTx(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(`
+			INSERT INTO user_recovery_codes (user_id, code_hash, created_at)
+			VALUES (?, ?, ?)
+		`, userID, hash, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many of the user's recovery codes are still unused
+func CountRecoveryCodes(db *sql.DB, userID int64) (int, error) {
+	var n int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&n)
+	return n, err
+}
@@ -3,42 +3,342 @@ package models
 import (
 	"database/sql"
 	"log"
+	"strconv"
 	"time"
 )
 
+// 使用者角色
+const (
+	RoleUser    = "user"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
+
+// Roles lists every role a user can have
+var Roles = []string{RoleUser, RoleTeacher, RoleAdmin}
+
 type User struct {
-	ID        int64
-	Username  string
-	Password  string
-	CreatedAt time.Time
+	ID       int64
+	Username string
+	Password string
+	// PasswordSet is false for accounts created by an external login until the user chooses a password
+	PasswordSet bool
+	Email       string // 選填，用於重設密碼
+	Role        string
+	DisabledAt  *time.Time // 不為 nil 表示帳號已被管理員停用
+	// DeletionScheduledAt is when the account will be permanently deleted, or nil if no deletion was requested
+	DeletionScheduledAt *time.Time
+	CreatedAt           time.Time
 }
 
-func CreateUser(db *sql.DB, username, password string) error {
-	query := `INSERT INTO users (username, password, created_at) VALUES (?, ?, ?)`
-	_, err := db.Exec(query, username, password, time.Now())
+// HasRole reports whether the user has one of the given roles; admins have every role
+func (u *User) HasRole(roles ...string) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, r := range roles {
+		if u.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+const userColumns = `
+	id, username, password, password_set, COALESCE(email, ''), role, disabled_at, deletion_scheduled_at, created_at
+`
+
+func CreateUser(db *sql.DB, username, password, email string) error {
+	query := `INSERT INTO users (username, password, email, created_at) VALUES (?, ?, ?, ?)`
+	_, err := db.Exec(query, username, password, nullString(email), time.Now())
 	return err
 }
 
 func GetUserByUsername(db *sql.DB, username string) (*User, error) {
-	user := &User{}
-	query := `SELECT id, username, password, created_at FROM users WHERE username = ?`
-	err := db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt)
+	user, err := scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, username))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Println("⚠️ User not found:", username)
-			return nil, nil // 返回 nil 而非錯誤
-		}
 		log.Println("❌ Database error:", err)
 		return nil, err
 	}
+	if user == nil {
+		log.Println("⚠️ User not found:", username)
+		return nil, nil // 返回 nil 而非錯誤
+	}
 
 	log.Println("✅ Found user:", user.Username)
-	log.Println("🔒 Hashed password from DB:", user.Password)
 	return user, nil
 }
 
+// GetUserByID returns the user with the given ID, or nil if there is none
+func GetUserByID(db *sql.DB, id int64) (*User, error) {
+	return scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+// GetUserByEmail returns the user with the given email address, or nil if there is none
+func GetUserByEmail(db *sql.DB, email string) (*User, error) {
+	return scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+}
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*User, error) {
+	user := &User{}
+	var disabledAt, deletionScheduledAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.PasswordSet, &user.Email, &user.Role, &disabledAt,
+		&deletionScheduledAt, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+	return user, nil
+}
+
+// ChangePassword stores a new password hash and signs out every other session (keepSessionID 0 keeps none)
+func (u *User) ChangePassword(db *sql.DB, hash string, keepSessionID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET password = ?, password_set = TRUE WHERE id = ?", hash, u.ID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE user_sessions
+		SET revoked_at = ?
+		WHERE user_id = ? AND id <> ? AND revoked_at IS NULL
+	`, time.Now(), u.ID, keepSessionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetEmail changes the user's email address; an empty address removes it
+func (u *User) SetEmail(db *sql.DB, email string) error {
+	_, err := db.Exec("UPDATE users SET email = ? WHERE id = ?", nullString(email), u.ID)
+	return err
+}
+
 func (u *User) SaveTestResult(db *sql.DB, wordID string, correct bool) error {
-	query := `INSERT INTO test_results (user_id, word_id, correct, created_at) VALUES (?, ?, ?, ?)`
-	_, err := db.Exec(query, u.ID, wordID, correct, time.Now())
+	id, err := strconv.ParseInt(wordID, 10, 64)
+	if err != nil {
+		return err
+	}
+	return u.SaveReviewResult(db, id, "flip", correct)
+}
+
+// SaveReviewResult records a graded answer for one of the user's words in the given quiz mode
+// and advances the word's review schedule
+func (u *User) SaveReviewResult(db *sql.DB, wordID int64, mode string, correct bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordReviewInTx(tx, u.ID, wordID, mode, correct); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Preferences holds a user's timezone, daily goals and reminder settings
+type Preferences struct {
+	UserID   int64
+	Timezone string // IANA 時區名稱，決定每日目標與連續天數的日期界線
+
+	// 每日目標，0 表示不設定
+	GoalNewWords int
+	GoalReviews  int
+	GoalMinutes  int
+
+	ReminderEnabled bool
+	ReminderHour    int    // 使用者時區的整點，0–23
+	ReminderChannel string // email、webhook
+	ReminderTarget  string // 電子郵件地址或 webhook URL
+	LastRemindedOn  string // YYYY-MM-DD，空字串表示從未提醒
+
+	// 最近一次以驗證碼確認的目標，見 ReminderDestination
+	ReminderVerifiedTarget string
+}
+
+// DefaultReminderHour is when reminders go out for users who have not picked a time
+const DefaultReminderHour = 19
+
+// Location returns the user's time zone, falling back to UTC for unknown names
+func (p *Preferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ReminderDestination identifies a reminder target together with its channel
+func ReminderDestination(channel, target string) string {
+	return channel + ":" + target
+}
+
+// ReminderVerified reports whether the current reminder target has been confirmed by its owner
+func (p *Preferences) ReminderVerified() bool {
+	return p.ReminderTarget != "" && p.ReminderVerifiedTarget == ReminderDestination(p.ReminderChannel, p.ReminderTarget)
+}
+
+// HasGoals reports whether any daily goal is set
+func (p *Preferences) HasGoals() bool {
+	return p.GoalNewWords > 0 || p.GoalReviews > 0 || p.GoalMinutes > 0
+}
+
+const preferencesColumns = `
+	user_id, timezone, goal_new_words, goal_reviews, goal_minutes,
+	reminder_enabled, reminder_hour, reminder_channel, reminder_target, last_reminded_on, reminder_verified_target
+`
+
+// GetPreferences returns the user's preferences, or the defaults if none are saved
+func (u *User) GetPreferences(db *sql.DB) (*Preferences, error) {
+	p, err := scanPreferences(db.QueryRow(`SELECT `+preferencesColumns+` FROM user_preferences WHERE user_id = ?`, u.ID))
+	if err == sql.ErrNoRows {
+		return &Preferences{
+			UserID:          u.ID,
+			Timezone:        "UTC",
+			ReminderHour:    DefaultReminderHour,
+			ReminderChannel: "email",
+		}, nil
+	}
+	return p, err
+}
+
+// SavePreferences stores the user's preferences; the last reminder date and the confirmed target are left untouched
+func (u *User) SavePreferences(db *sql.DB, p *Preferences) error {
+	_, err := db.Exec(`
+		INSERT INTO user_preferences
+			(user_id, timezone, goal_new_words, goal_reviews, goal_minutes,
+			reminder_enabled, reminder_hour, reminder_channel, reminder_target)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			timezone = VALUES(timezone),
+			goal_new_words = VALUES(goal_new_words),
+			goal_reviews = VALUES(goal_reviews),
+			goal_minutes = VALUES(goal_minutes),
+			reminder_enabled = VALUES(reminder_enabled),
+			reminder_hour = VALUES(reminder_hour),
+			reminder_channel = VALUES(reminder_channel),
+			reminder_target = VALUES(reminder_target)
+	`, u.ID, p.Timezone, p.GoalNewWords, p.GoalReviews, p.GoalMinutes,
+		p.ReminderEnabled, p.ReminderHour, p.ReminderChannel, p.ReminderTarget)
+	return err
+}
+
+// GetReminderPreferences returns the preferences of every user with reminders enabled and a confirmed target
+func GetReminderPreferences(db *sql.DB) ([]Preferences, error) {
+	rows, err := db.Query(`
+		SELECT ` + preferencesColumns + ` FROM user_preferences
+		WHERE reminder_enabled = TRUE
+			AND reminder_target <> ''
+			AND reminder_verified_target = CONCAT(reminder_channel, ':', reminder_target)
+			AND user_id IN (SELECT id FROM users WHERE disabled_at IS NULL AND deletion_scheduled_at IS NULL)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefs []Preferences
+	for rows.Next() {
+		p, err := scanPreferences(rows)
+		if err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, *p)
+	}
+	return prefs, rows.Err()
+}
+
+// ClaimReminder records day as the local date of the user's last reminder and reports whether it was not
+// already recorded, so that only one sender delivers each day's reminder
+func ClaimReminder(db *sql.DB, userID int64, day string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_preferences SET last_reminded_on = ?
+		WHERE user_id = ? AND (last_reminded_on IS NULL OR last_reminded_on <> ?)
+	`, day, userID, day)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseReminder undoes a claim for day when the reminder could not be sent, restoring the previous date
+func ReleaseReminder(db *sql.DB, userID int64, day, previous string) error {
+	_, err := db.Exec(`
+		UPDATE user_preferences SET last_reminded_on = ?
+		WHERE user_id = ? AND last_reminded_on = ?
+	`, nullString(previous), userID, day)
 	return err
 }
+
+// ClaimReminderConfirmation stores the hash of a confirmation code sent to destination, unless a code was sent
+// after since; it reports whether the code was stored and may be sent
+func ClaimReminderConfirmation(db *sql.DB, userID int64, destination, codeHash string, now, since time.Time) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_preferences
+		SET reminder_code_hash = ?, reminder_code_target = ?, reminder_code_sent_at = ?
+		WHERE user_id = ? AND (reminder_code_sent_at IS NULL OR reminder_code_sent_at <= ?)
+	`, codeHash, destination, now, userID, since)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ConfirmReminderTarget marks the destination a code was sent to as confirmed if the code matches and was
+// sent after since; each code works once
+func ConfirmReminderTarget(db *sql.DB, userID int64, codeHash string, since time.Time) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_preferences
+		SET reminder_verified_target = reminder_code_target, reminder_code_hash = NULL
+		WHERE user_id = ? AND reminder_code_hash = ? AND reminder_code_sent_at > ?
+	`, userID, codeHash, since)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimTestReminder records a test reminder unless one was sent after since, and reports whether it may be sent
+func ClaimTestReminder(db *sql.DB, userID int64, now, since time.Time) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_preferences SET reminder_test_sent_at = ?
+		WHERE user_id = ? AND (reminder_test_sent_at IS NULL OR reminder_test_sent_at <= ?)
+	`, now, userID, since)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func scanPreferences(row interface{ Scan(...interface{}) error }) (*Preferences, error) {
+	p := &Preferences{}
+	var lastReminded sql.NullTime
+	err := row.Scan(&p.UserID, &p.Timezone, &p.GoalNewWords, &p.GoalReviews, &p.GoalMinutes,
+		&p.ReminderEnabled, &p.ReminderHour, &p.ReminderChannel, &p.ReminderTarget, &lastReminded,
+		&p.ReminderVerifiedTarget)
+	if err != nil {
+		return nil, err
+	}
+	if lastReminded.Valid {
+		p.LastRemindedOn = lastReminded.Time.Format(DayFormat)
+	}
+	return p, nil
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 	"time"
+	"vocabulary/internal/wordlist"
 )
 
 type Vocabulary struct {
@@ -11,8 +13,17 @@ type Vocabulary struct {
 	Word        string
 	Status      string
 	Tested      bool
+	ArticleID   int64  // 來源文章，0 表示沒有
+	Context     string // 單字在來源文章中的句子
+	DeckID      int64  // 所屬的牌組，0 表示沒有
+	Tags        []string
 	CreatedAt   time.Time
 	Definitions []VocabularyDefinition
+
+	// 由內建單字表推算，不存於資料庫
+	FrequencyRank int
+	CEFRLevel     string
+	WordLists     []string
 }
 
 type VocabularyDefinition struct {
@@ -29,6 +40,7 @@ type TestResult struct {
 	UserID    int64
 	WordID    int64
 	Correct   bool
+	Mode      string // flip, choice, typing, cloze
 	CreatedAt time.Time
 }
 
@@ -36,7 +48,7 @@ type TestResult struct {
 func (v *Vocabulary) Get(db *sql.DB) error {
 	// Get vocabulary word
 	query := `
-		SELECT id, user_id, word, status, tested, created_at
+		SELECT id, user_id, word, status, tested, COALESCE(article_id, 0), COALESCE(context, ''), COALESCE(deck_id, 0), created_at
 		FROM vocabularies
 		WHERE id = ?
 	`
@@ -46,11 +58,18 @@ func (v *Vocabulary) Get(db *sql.DB) error {
 		&v.Word,
 		&v.Status,
 		&v.Tested,
+		&v.ArticleID,
+		&v.Context,
+		&v.DeckID,
 		&v.CreatedAt,
 	)
 	if err != nil {
 		return err
 	}
+	v.annotate()
+	if err := v.loadTags(db); err != nil {
+		return err
+	}
 
 	// Get definitions
 	query = `
@@ -138,7 +157,7 @@ func (v *Vocabulary) Remove(db *sql.DB) error {
 func GetByUserID(db *sql.DB, userID int64) ([]Vocabulary, error) {
 	// 先查詢所有單字
 	rows, err := db.Query(`
-		SELECT id, user_id, word, status, tested, created_at 
+		SELECT id, user_id, word, status, tested, COALESCE(article_id, 0), COALESCE(context, ''), COALESCE(deck_id, 0), created_at 
 		FROM vocabularies 
 		WHERE user_id = ? AND status = 'active' 
 		ORDER BY created_at DESC
@@ -151,15 +170,24 @@ func GetByUserID(db *sql.DB, userID int64) ([]Vocabulary, error) {
 	var vocabularies []Vocabulary
 	for rows.Next() {
 		var v Vocabulary
-		err := rows.Scan(&v.ID, &v.UserID, &v.Word, &v.Status, &v.Tested, &v.CreatedAt)
+		err := rows.Scan(&v.ID, &v.UserID, &v.Word, &v.Status, &v.Tested, &v.ArticleID, &v.Context, &v.DeckID, &v.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
+		v.annotate()
 		vocabularies = append(vocabularies, v)
 	}
 
+	// 查詢所有單字的標籤
+	tags, err := getTagsByVocabulary(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 查詢每個單字的定義
 	for i := range vocabularies {
+		vocabularies[i].Tags = tags[vocabularies[i].ID]
+
 		rows, err := db.Query(`
 			SELECT id, vocabulary_id, part_of_speech, definition, example, created_at 
 			FROM vocabulary_definitions 
@@ -189,10 +217,10 @@ func GetByWord(db *sql.DB, userID int64, word string) (*Vocabulary, error) {
 	// 先查詢主表
 	var v Vocabulary
 	err := db.QueryRow(`
-		SELECT id, user_id, word, status, tested, created_at 
+		SELECT id, user_id, word, status, tested, COALESCE(article_id, 0), COALESCE(context, ''), COALESCE(deck_id, 0), created_at 
 		FROM vocabularies 
 		WHERE user_id = ? AND word = ? AND status = 'active'
-	`, userID, word).Scan(&v.ID, &v.UserID, &v.Word, &v.Status, &v.Tested, &v.CreatedAt)
+	`, userID, word).Scan(&v.ID, &v.UserID, &v.Word, &v.Status, &v.Tested, &v.ArticleID, &v.Context, &v.DeckID, &v.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -200,6 +228,10 @@ func GetByWord(db *sql.DB, userID int64, word string) (*Vocabulary, error) {
 	if err != nil {
 		return nil, err
 	}
+	v.annotate()
+	if err := v.loadTags(db); err != nil {
+		return nil, err
+	}
 
 	// 查詢定義
 	rows, err := db.Query(`
@@ -227,30 +259,63 @@ func GetByWord(db *sql.DB, userID int64, word string) (*Vocabulary, error) {
 
 // Create creates a new vocabulary word with its definitions
 func Create(db *sql.DB, userID int64, word string, definitions []VocabularyDefinition) error {
+	return CreateVocabulary(db, userID, Vocabulary{Word: word, Definitions: definitions})
+}
+
+// CreateVocabulary creates a new vocabulary word together with its source, deck and tags
+func CreateVocabulary(db *sql.DB, userID int64, v Vocabulary) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// 插入或更新主表
+	if err := createInTx(tx, userID, v); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateBatch creates several vocabulary words in a single transaction
+func CreateBatch(db *sql.DB, userID int64, vocabularies []Vocabulary) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, v := range vocabularies {
+		if err := createInTx(tx, userID, v); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func createInTx(tx *sql.Tx, userID int64, v Vocabulary) error {
+	// 插入或更新主表，僅在有提供來源時覆寫來源資訊
 	result, err := tx.Exec(`
-		INSERT INTO vocabularies (user_id, word, status) 
-		VALUES (?, ?, 'active')
+		INSERT INTO vocabularies (user_id, word, status, article_id, context, deck_id) 
+		VALUES (?, ?, 'active', ?, ?, ?)
 		ON DUPLICATE KEY UPDATE 
-			status = 'active'
-	`, userID, word)
+			status = 'active',
+			article_id = COALESCE(VALUES(article_id), article_id),
+			context = COALESCE(VALUES(context), context),
+			deck_id = COALESCE(VALUES(deck_id), deck_id)
+	`, userID, v.Word, nullInt64(v.ArticleID), nullString(v.Context), nullInt64(v.DeckID))
 	if err != nil {
 		return err
 	}
 
 	// 獲取vocabulary_id
 	var vocabularyID int64
-	if id, err := result.LastInsertId(); err == nil {
+	if id, err := result.LastInsertId(); err == nil && id != 0 {
 		vocabularyID = id
 	} else {
 		// 如果是更新現有記錄，需要查詢ID
-		err = tx.QueryRow("SELECT id FROM vocabularies WHERE user_id = ? AND word = ?", userID, word).Scan(&vocabularyID)
+		err = tx.QueryRow("SELECT id FROM vocabularies WHERE user_id = ? AND word = ?", userID, v.Word).Scan(&vocabularyID)
 		if err != nil {
 			return err
 		}
@@ -263,7 +328,7 @@ func Create(db *sql.DB, userID int64, word string, definitions []VocabularyDefin
 	}
 
 	// 插入新的定義
-	for _, def := range definitions {
+	for _, def := range v.Definitions {
 		_, err = tx.Exec(`
 			INSERT INTO vocabulary_definitions (vocabulary_id, part_of_speech, definition, example) 
 			VALUES (?, ?, ?, ?)
@@ -273,7 +338,97 @@ func Create(db *sql.DB, userID int64, word string, definitions []VocabularyDefin
 		}
 	}
 
-	return tx.Commit()
+	// 僅在有指定標籤時覆寫
+	if len(v.Tags) > 0 {
+		if err := setTagsInTx(tx, userID, vocabularyID, v.Tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EachVocabulary streams a user's active words with their definitions and tags in creation order,
+// calling fn once per word without loading the whole vocabulary into memory
+func EachVocabulary(db *sql.DB, userID int64, fn func(v Vocabulary) error) error {
+	tags, err := getTagsByVocabulary(db, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		SELECT v.id, v.user_id, v.word, v.status, v.tested, COALESCE(v.article_id, 0), COALESCE(v.context, ''),
+			COALESCE(v.deck_id, 0), v.created_at,
+			COALESCE(d.id, 0), COALESCE(d.part_of_speech, ''), COALESCE(d.definition, ''), COALESCE(d.example, ''), d.created_at
+		FROM vocabularies v
+		LEFT JOIN vocabulary_definitions d ON d.vocabulary_id = v.id
+		WHERE v.user_id = ? AND v.status = 'active'
+		ORDER BY v.id, d.id
+	`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *Vocabulary
+	for rows.Next() {
+		var v Vocabulary
+		var def VocabularyDefinition
+		var defCreatedAt sql.NullTime
+		err := rows.Scan(&v.ID, &v.UserID, &v.Word, &v.Status, &v.Tested, &v.ArticleID, &v.Context, &v.DeckID, &v.CreatedAt,
+			&def.ID, &def.PartOfSpeech, &def.Definition, &def.Example, &defCreatedAt)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != v.ID {
+			if current != nil {
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+			v.annotate()
+			v.Tags = tags[v.ID]
+			current = &v
+		}
+		if def.ID != 0 {
+			def.VocabularyID = v.ID
+			def.CreatedAt = defCreatedAt.Time
+			current.Definitions = append(current.Definitions, def)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// GetWordSet returns the set of active words in a user's vocabulary
+func GetWordSet(db *sql.DB, userID int64) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT word 
+		FROM vocabularies 
+		WHERE user_id = ? AND status = 'active'
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make(map[string]bool)
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words[strings.ToLower(word)] = true
+	}
+
+	return words, rows.Err()
 }
 
 // UpdateTestedStatus updates the tested status of a vocabulary word
@@ -285,3 +440,46 @@ func (v *Vocabulary) UpdateTestedStatus(db *sql.DB, tested bool) error {
 	`, tested, v.ID, v.UserID)
 	return err
 }
+
+// loadTags loads the names of the tags on this vocabulary word
+func (v *Vocabulary) loadTags(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT t.name
+		FROM vocabulary_tags vt
+		JOIN tags t ON t.id = vt.tag_id
+		WHERE vt.vocabulary_id = ?
+		ORDER BY t.name
+	`, v.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	v.Tags = nil
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		v.Tags = append(v.Tags, name)
+	}
+	return rows.Err()
+}
+
+// annotate fills in the frequency rank, CEFR level and word lists from the bundled word lists
+func (v *Vocabulary) annotate() {
+	entry := wordlist.Lookup(v.Word)
+	v.FrequencyRank = entry.Rank
+	v.CEFRLevel = entry.Level
+	v.WordLists = entry.Lists
+}
+
+// nullInt64 converts a zero ID into a NULL column value
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+// nullString converts an empty string into a NULL column value
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,92 @@
+// Package notify delivers messages to users through pluggable channels such as email and webhooks.
+package notify
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Message is a notification; Data is sent as-is by channels that carry structured payloads
+type Message struct {
+	Subject string      `json:"subject"`
+	Text    string      `json:"text"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Notifier sends a message to a recipient whose address format depends on the channel
+type Notifier interface {
+	// Validate reports whether to is a usable address for this channel
+	Validate(to string) error
+	Send(to string, m Message) error
+}
+
+// ErrUnknownChannel is returned for channels that have no registered notifier
+var ErrUnknownChannel = errors.New("notification channel is not configured")
+
+var (
+	mu        sync.RWMutex
+	notifiers = make(map[string]Notifier)
+)
+
+// Register makes a notifier available under a channel name, replacing any previous one
+func Register(channel string, n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifiers[channel] = n
+}
+
+// Get returns the notifier registered for a channel
+func Get(channel string) (Notifier, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	n, ok := notifiers[channel]
+	if !ok {
+		return nil, ErrUnknownChannel
+	}
+	return n, nil
+}
+
+// Channels lists the registered channel names
+func Channels() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	channels := make([]string, 0, len(notifiers))
+	for channel := range notifiers {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Send delivers a message through the named channel
+func Send(channel, to string, m Message) error {
+	n, err := Get(channel)
+	if err != nil {
+		return err
+	}
+	return n.Send(to, m)
+}
+
+// ConfigureFromEnv registers the email channel when SMTP_ADDR is set and always registers the webhook channel
+//
+// SMTP_ADDR、SMTP_FROM、SMTP_USERNAME、SMTP_PASSWORD 設定郵件；WEBHOOK_SECRET 用於簽署 webhook，
+// WEBHOOK_ALLOW_PRIVATE=true 允許送往內部網路位址（僅供本機測試）
+func ConfigureFromEnv() {
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = "vocabulary@localhost"
+		}
+		Register("email", &SMTPNotifier{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		})
+	}
+	Register("webhook", NewWebhookNotifier(os.Getenv("WEBHOOK_SECRET"),
+		strings.EqualFold(os.Getenv("WEBHOOK_ALLOW_PRIVATE"), "true")))
+}
@@ -0,0 +1,471 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID     = "vocabulary"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost/auth/oidc/callback"
+)
+
+// mockIdP is a minimal OpenID Connect provider serving discovery, JWKS, authorization and token endpoints
+type mockIdP struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+	kid string
+
+	// issuer 預設為伺服器網址；discoveryIssuer 不為空時 discovery 文件改回報此值
+	discoveryIssuer string
+	// claims 在簽發前修改 ID token 的內容
+	claims func(c jwt.MapClaims)
+	// sign 不為 nil 時取代預設的 RS256 簽章
+	sign func(c jwt.MapClaims) string
+
+	mu        sync.Mutex
+	codes     map[string]authRequest
+	discovery int
+	jwks      int
+}
+
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{t: t, key: key, kid: "key-1", codes: make(map[string]authRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	mux.HandleFunc("/authorize", idp.handleAuthorize)
+	mux.HandleFunc("/token", idp.handleToken)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) provider() *Provider {
+	return New(Config{
+		Issuer:       idp.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+}
+
+func (idp *mockIdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.discovery++
+	idp.mu.Unlock()
+
+	issuer := idp.URL
+	if idp.discoveryIssuer != "" {
+		issuer = idp.discoveryIssuer
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": idp.URL + "/authorize",
+		"token_endpoint":         idp.URL + "/token",
+		"jwks_uri":               idp.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.jwks++
+	idp.mu.Unlock()
+
+	pub := idp.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": idp.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// handleAuthorize 立即「登入」並導回 redirect_uri，記錄 PKCE challenge 與 nonce 供換取 token 時使用
+func (idp *mockIdP) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := "code-" + q.Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = authRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	idp.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := url.Values{"code": {code}, "state": {q.Get("state")}}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *mockIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	req, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != testRedirectURL {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	if challenge(r.PostForm.Get("code_verifier")) != req.challenge {
+		http.Error(w, `{"error":"invalid_grant","error_description":"PKCE verification failed"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                idp.URL,
+		"sub":                "user-123",
+		"aud":                testClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              req.nonce,
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+	var idToken string
+	if idp.sign != nil {
+		idToken = idp.sign(claims)
+	} else {
+		idToken = idp.signRS256(claims, idp.key, idp.kid)
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (idp *mockIdP) signRS256(claims jwt.MapClaims, key *rsa.PrivateKey, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return signed
+}
+
+// login runs the browser part of the flow and returns the authorization code for the given nonce and verifier
+func login(t *testing.T, p *Provider, state, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %s", resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	return location.Query().Get("code")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != idp.URL+"/authorize" {
+		t.Errorf("endpoint = %q, want the discovered authorization endpoint", got)
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid profile email",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        challenge("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := q.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	// 驗證碼本身不可出現在網址中
+	if strings.Contains(authURL, "verifier-1") {
+		t.Error("authorization URL contains the PKCE verifier")
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636 附錄 B 的範例
+	got := challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("challenge = %q, want %q", got, want)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.claims = func(c jwt.MapClaims) {
+		// 部分身分提供者以字串表示 email_verified
+		c["email_verified"] = "true"
+	}
+	p := idp.provider()
+
+	code := login(t, p, "state-1", "nonce-1", "verifier-1")
+	claims, err := p.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Claims{
+		Subject:           "user-123",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice",
+	}
+	if *claims != want {
+		t.Errorf("claims = %+v, want %+v", *claims, want)
+	}
+
+	// 第二次登入沿用快取的 discovery 文件與金鑰
+	code = login(t, p, "state-2", "nonce-2", "verifier-2")
+	if _, err := p.Exchange(context.Background(), code, "verifier-2", "nonce-2"); err != nil {
+		t.Fatalf("second Exchange: %v", err)
+	}
+	if idp.discovery != 1 || idp.jwks != 1 {
+		t.Errorf("fetched discovery %d and JWKS %d times, want 1 each", idp.discovery, idp.jwks)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// setup 修改模擬的身分提供者
+		setup func(idp *mockIdP)
+		// verifier 與 nonce 為換取 token 時使用的值，空字串表示與登入時相同
+		verifier string
+		nonce    string
+	}{
+		{
+			name:  "wrong nonce",
+			nonce: "other-nonce",
+		},
+		{
+			name: "missing nonce",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(c jwt.MapClaims) { delete(c, "nonce") }
+			},
+		},
+		{
+			name: "wrong audience",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(c jwt.MapClaims) { c["aud"] = "another-client" }
+			},
+		},
+		{
+			name: "wrong issuer",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }
+			},
+		},
+		{
+			name: "expired",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }
+			},
+		},
+		{
+			name: "no expiry",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(c jwt.MapClaims) { delete(c, "exp") }
+			},
+		},
+		{
+			name: "no subject",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(c jwt.MapClaims) { delete(c, "sub") }
+			},
+		},
+		{
+			name: "signed by an unknown key",
+			setup: func(idp *mockIdP) {
+				idp.sign = func(c jwt.MapClaims) string { return idp.signRS256(c, otherKey, idp.kid) }
+			},
+		},
+		{
+			name: "unknown key ID",
+			setup: func(idp *mockIdP) {
+				idp.sign = func(c jwt.MapClaims) string { return idp.signRS256(c, idp.key, "key-2") }
+			},
+		},
+		{
+			name: "HMAC signed with the client secret",
+			setup: func(idp *mockIdP) {
+				idp.sign = func(c jwt.MapClaims) string {
+					signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(testClientSecret))
+					return signed
+				}
+			},
+		},
+		{
+			name: "unsigned",
+			setup: func(idp *mockIdP) {
+				idp.sign = func(c jwt.MapClaims) string {
+					signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+					return signed
+				}
+			},
+		},
+		{
+			name:     "wrong PKCE verifier",
+			verifier: "other-verifier",
+		},
+		{
+			name: "discovery for another issuer",
+			setup: func(idp *mockIdP) {
+				idp.discoveryIssuer = "https://evil.example.com"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			if tt.setup != nil {
+				tt.setup(idp)
+			}
+			p := idp.provider()
+
+			// discovery 失敗時無法產生授權網址，也就無法完成登入
+			authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+			if tt.name == "discovery for another issuer" {
+				if err == nil {
+					t.Fatalf("AuthCodeURL accepted a discovery document for another issuer: %s", authURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+
+			code := login(t, p, "state", "nonce", "verifier")
+			verifier, nonce := tt.verifier, tt.nonce
+			if verifier == "" {
+				verifier = "verifier"
+			}
+			if nonce == "" {
+				nonce = "nonce"
+			}
+			if claims, err := p.Exchange(context.Background(), code, verifier, nonce); err == nil {
+				t.Errorf("Exchange accepted the token: %+v", claims)
+			}
+		})
+	}
+}
+
+func TestExchangeUnknownCode(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	if _, err := p.Exchange(context.Background(), "not-a-code", "verifier", "nonce"); err == nil {
+		t.Error("Exchange accepted a code the provider never issued")
+	}
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+
+	tests := []struct {
+		name    string
+		key     jwk
+		wantErr bool
+	}{
+		{"EC P-256", jwk{Kty: "EC", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)}, false},
+		{"EC point not on curve", jwk{Kty: "EC", Crv: "P-256", X: encode(ecKey.X), Y: encode(big.NewInt(1))}, true},
+		{"unsupported curve", jwk{Kty: "EC", Crv: "secp256k1", X: encode(ecKey.X), Y: encode(ecKey.Y)}, true},
+		{"unsupported key type", jwk{Kty: "oct"}, true},
+		{"bad base64", jwk{Kty: "RSA", N: "!!", E: "AQAB"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := tt.key.publicKey()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("publicKey error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !ecKey.PublicKey.Equal(pub) {
+				t.Errorf("publicKey = %v, want the generated key", pub)
+			}
+		})
+	}
+}
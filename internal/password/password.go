@@ -0,0 +1,82 @@
+// Package password enforces the password policy: a minimum length, bcrypt's input limit, and a check
+// against a bundled list of passwords known from breaches.
+package password
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// MinLength follows NIST SP 800-63B, which favours length over composition rules
+	MinLength = 8
+	// MaxBytes is the longest input bcrypt hashes; anything longer would be silently truncated
+	MaxBytes = 72
+)
+
+var (
+	ErrTooShort   = errors.New("password must be at least 8 characters")
+	ErrTooLong    = errors.New("password must be at most 72 bytes")
+	ErrSameAsUser = errors.New("password must not be the same as the username")
+	ErrBreached   = errors.New("password appears in a list of breached passwords; please choose another")
+)
+
+var (
+	mu       sync.RWMutex
+	breached map[string]bool
+)
+
+// LoadBreached reads a list of breached passwords, one per line; lines starting with # are comments
+func LoadBreached(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	list := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	breached = list
+	mu.Unlock()
+	return nil
+}
+
+// IsBreached reports whether the password is on the breached list, ignoring case
+func IsBreached(password string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return breached[strings.ToLower(password)]
+}
+
+// Validate checks a new password against the policy
+func Validate(password, username string) error {
+	if utf8.RuneCountInString(password) < MinLength {
+		return ErrTooShort
+	}
+	if len(password) > MaxBytes {
+		return ErrTooLong
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return ErrSameAsUser
+	}
+	if IsBreached(password) {
+		return ErrBreached
+	}
+	return nil
+}
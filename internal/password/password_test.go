@@ -0,0 +1,69 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadTestList(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadBreached(path); err != nil {
+		t.Fatalf("LoadBreached: %v", err)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		breached = nil
+		mu.Unlock()
+	})
+}
+
+func TestValidate(t *testing.T) {
+	loadTestList(t, "# common passwords\nPassword123\n\n  letmein!  \n")
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     error
+	}{
+		{"acceptable", "correct horse battery", "alice", nil},
+		{"exactly minimum length", "abcdefgh", "alice", nil},
+		{"too short", "abcdefg", "alice", ErrTooShort},
+		// 長度以字元計算，而非位元組
+		{"multibyte characters counted as one", "密碼密碼密碼密", "alice", ErrTooShort},
+		{"multibyte long enough", "密碼密碼密碼密碼", "alice", nil},
+		{"exactly bcrypt limit", strings.Repeat("a", MaxBytes), "alice", nil},
+		{"over bcrypt limit", strings.Repeat("a", MaxBytes+1), "alice", ErrTooLong},
+		{"over bcrypt limit in bytes", strings.Repeat("密", 25), "alice", ErrTooLong},
+		{"same as username", "AliceSmith", "alicesmith", ErrSameAsUser},
+		{"no username to compare", "alicesmith", "", nil},
+		{"breached", "password123", "alice", ErrBreached},
+		{"breached with surrounding spaces in list", "LETMEIN!", "alice", ErrBreached},
+		{"comment lines are not passwords", "# common passwords", "alice", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(tt.password, tt.username); got != tt.want {
+				t.Errorf("Validate(%q, %q) = %v, want %v", tt.password, tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBreachedMissingFile(t *testing.T) {
+	loadTestList(t, "password123\n")
+	if err := LoadBreached(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("LoadBreached succeeded for a missing file")
+	}
+	// 讀取失敗時保留原本的清單
+	if !IsBreached("password123") {
+		t.Error("previous list was discarded")
+	}
+}
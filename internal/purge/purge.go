@@ -0,0 +1,41 @@
+// Package purge permanently deletes accounts whose deletion grace period has ended.
+package purge
+
+import (
+	"database/sql"
+	"log"
+	"time"
+	"vocabulary/internal/models"
+)
+
+// Start deletes due accounts every interval until the process exits
+func Start(db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			RunOnce(db, now)
+		}
+	}()
+}
+
+// RunOnce deletes every account whose scheduled deletion time has passed; each account is deleted in its
+// own transaction so that one failure does not hold up the others
+func RunOnce(db *sql.DB, now time.Time) {
+	ids, err := models.GetDueDeletions(db, now)
+	if err != nil {
+		log.Println("Error fetching accounts due for deletion:", err)
+		return
+	}
+
+	for _, id := range ids {
+		deleted, err := models.DeleteAccount(db, id, now)
+		if err != nil {
+			log.Printf("Error deleting account %d: %v", id, err)
+			continue
+		}
+		if deleted {
+			log.Printf("Deleted account %d", id)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Database is a Limiter backed by the login_throttle table, so that every instance sees the same failures
+type Database struct {
+	db     *sql.DB
+	prefix string // 區分不同用途的限制器，如 "ip:"、"user:"
+	policy Policy
+}
+
+// NewDatabase returns a database-backed limiter applying p to keys namespaced by prefix
+func NewDatabase(db *sql.DB, prefix string, p Policy) *Database {
+	return &Database{db: db, prefix: prefix, policy: p}
+}
+
+func (d *Database) Attempt(key string, now time.Time) (time.Duration, Status, error) {
+	// 先確保資料列存在，再以 FOR UPDATE 鎖定，讓同一個鍵的嘗試依序判斷與計數
+	_, err := d.db.Exec(`
+		INSERT IGNORE INTO login_throttle (throttle_key, failures, last_failure)
+		VALUES (?, 0, ?)
+	`, d.prefix+key, now)
+	if err != nil {
+		return 0, Status{}, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, Status{}, err
+	}
+	defer tx.Rollback()
+
+	var failures int
+	var lastFailure, blockedUntil sql.NullTime
+	err = tx.QueryRow(`
+		SELECT failures, last_failure, blocked_until
+		FROM login_throttle
+		WHERE throttle_key = ?
+		FOR UPDATE
+	`, d.prefix+key).Scan(&failures, &lastFailure, &blockedUntil)
+	if err != nil {
+		return 0, Status{}, err
+	}
+	if wait := remaining(blockedUntil.Time, now); wait > 0 {
+		return wait, Status{Failures: failures, BlockedUntil: blockedUntil.Time}, nil
+	}
+
+	s := d.policy.next(failures, lastFailure.Time, now)
+	_, err = tx.Exec(`
+		UPDATE login_throttle
+		SET failures = ?, last_failure = ?, blocked_until = ?
+		WHERE throttle_key = ?
+	`, s.Failures, now, nullTime(s.BlockedUntil), d.prefix+key)
+	if err != nil {
+		return 0, Status{}, err
+	}
+
+	// 順便清除過期的紀錄，避免資料表無限成長
+	_, err = tx.Exec(`
+		DELETE FROM login_throttle
+		WHERE throttle_key LIKE ? AND last_failure < ? AND (blocked_until IS NULL OR blocked_until < ?)
+		LIMIT 100
+	`, d.prefix+"%", now.Add(-d.policy.Window), now)
+	if err != nil {
+		return 0, Status{}, err
+	}
+	return 0, s, tx.Commit()
+}
+
+func (d *Database) Succeed(key string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var failures int
+	var blockedUntil sql.NullTime
+	err = tx.QueryRow(`
+		SELECT failures, blocked_until
+		FROM login_throttle
+		WHERE throttle_key = ?
+		FOR UPDATE
+	`, d.prefix+key).Scan(&failures, &blockedUntil)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	failures, until := d.policy.succeed(failures, blockedUntil.Time)
+	_, err = tx.Exec(`
+		UPDATE login_throttle SET failures = ?, blocked_until = ? WHERE throttle_key = ?
+	`, failures, nullTime(until), d.prefix+key)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) Reset(key string) error {
+	_, err := d.db.Exec("DELETE FROM login_throttle WHERE throttle_key = ?", d.prefix+key)
+	return err
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
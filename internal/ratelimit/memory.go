@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// 記錄數超過此值時清除過期的項目
+const memoryCleanupThreshold = 10000
+
+type memoryEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Memory is a Limiter for a single instance; state is lost on restart
+type Memory struct {
+	policy  Policy
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// NewMemory returns an in-memory limiter applying p
+func NewMemory(p Policy) *Memory {
+	return &Memory{policy: p, entries: make(map[string]*memoryEntry)}
+}
+
+func (m *Memory) Attempt(key string, now time.Time) (time.Duration, Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if ok {
+		if wait := remaining(e.blockedUntil, now); wait > 0 {
+			return wait, Status{Failures: e.failures, BlockedUntil: e.blockedUntil}, nil
+		}
+	} else {
+		if len(m.entries) >= memoryCleanupThreshold {
+			for k, e := range m.entries {
+				if m.policy.expired(e.lastFailure, e.blockedUntil, now) {
+					delete(m.entries, k)
+				}
+			}
+		}
+		e = &memoryEntry{}
+		m.entries[key] = e
+	}
+
+	s := m.policy.next(e.failures, e.lastFailure, now)
+	e.failures = s.Failures
+	e.lastFailure = now
+	e.blockedUntil = s.BlockedUntil
+	return 0, s, nil
+}
+
+func (m *Memory) Succeed(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		e.failures, e.blockedUntil = m.policy.succeed(e.failures, e.blockedUntil)
+	}
+	return nil
+}
+
+func (m *Memory) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
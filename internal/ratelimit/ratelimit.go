@@ -0,0 +1,91 @@
+// Package ratelimit throttles repeated failures, such as wrong passwords, with exponential backoff and
+// temporary lockout.
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"time"
+)
+
+// Policy decides how long a key is blocked after a number of consecutive failures
+type Policy struct {
+	FreeAttempts    int           // 不延遲的失敗次數
+	BaseDelay       time.Duration // 超過後第一次的等待時間，之後每次加倍
+	MaxDelay        time.Duration
+	LockoutAfter    int // 達到此失敗次數即鎖定
+	LockoutDuration time.Duration
+	Window          time.Duration // 超過此時間沒有失敗則重新計算
+}
+
+// Status is the throttling state of a key after a failure
+type Status struct {
+	Failures     int
+	BlockedUntil time.Time
+	Locked       bool // 達到鎖定門檻，而非只是延遲
+}
+
+// Limiter counts failures per key and blocks keys that fail too often. An attempt is counted as a failure in
+// the same step that allows it, so that concurrent attempts cannot all pass before any of them is recorded;
+// attempts that turn out to succeed are taken back with Succeed or Reset.
+type Limiter interface {
+	// Attempt returns how long the key must still wait without counting anything, or, if it may try now,
+	// zero after counting the attempt as a failure, together with the key's new status
+	Attempt(key string, now time.Time) (time.Duration, Status, error)
+	// Succeed takes back the failure counted for an attempt that succeeded
+	Succeed(key string) error
+	// Reset forgets the key's failures, typically after a success
+	Reset(key string) error
+}
+
+// HashKey returns the hex SHA-256 of a key, so that keys derived from user input have a fixed length
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// next applies a failure to the previous state
+func (p Policy) next(failures int, lastFailure time.Time, now time.Time) Status {
+	if !lastFailure.IsZero() && now.Sub(lastFailure) > p.Window {
+		failures = 0
+	}
+	failures++
+
+	s := Status{Failures: failures}
+	switch {
+	case p.LockoutAfter > 0 && failures >= p.LockoutAfter:
+		s.Locked = true
+		s.BlockedUntil = now.Add(p.LockoutDuration)
+	case failures > p.FreeAttempts:
+		delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(failures-p.FreeAttempts-1)))
+		if delay > p.MaxDelay || delay <= 0 {
+			delay = p.MaxDelay
+		}
+		s.BlockedUntil = now.Add(delay)
+	}
+	return s
+}
+
+// succeed takes back one failure; a delay caused only by the failure taken back is lifted, a lockout is kept
+func (p Policy) succeed(failures int, blockedUntil time.Time) (int, time.Time) {
+	if failures > 0 {
+		failures--
+	}
+	if failures <= p.FreeAttempts {
+		blockedUntil = time.Time{}
+	}
+	return failures, blockedUntil
+}
+
+// expired reports whether a key's record can be forgotten
+func (p Policy) expired(lastFailure, blockedUntil, now time.Time) bool {
+	return now.After(blockedUntil) && now.Sub(lastFailure) > p.Window
+}
+
+func remaining(blockedUntil, now time.Time) time.Duration {
+	if blockedUntil.After(now) {
+		return blockedUntil.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+func TestPolicyNext(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		failures     int
+		lastFailure  time.Time
+		wantFailures int
+		wantDelay    time.Duration
+		wantLocked   bool
+	}{
+		{"first failure", 0, time.Time{}, 1, 0, false},
+		{"last free attempt", 2, now, 3, 0, false},
+		{"first delay", 3, now, 4, time.Second, false},
+		{"delay doubles", 4, now, 5, 2 * time.Second, false},
+		{"delay capped", 8, now, 9, 30 * time.Second, false},
+		{"locked out", 9, now, 10, 15 * time.Minute, true},
+		{"stays locked", 12, now, 13, 15 * time.Minute, true},
+		{"window passed", 9, now.Add(-2 * time.Hour), 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testPolicy.next(tt.failures, tt.lastFailure, now)
+			if s.Failures != tt.wantFailures {
+				t.Errorf("Failures = %d, want %d", s.Failures, tt.wantFailures)
+			}
+			if got := remaining(s.BlockedUntil, now); got != tt.wantDelay {
+				t.Errorf("delay = %v, want %v", got, tt.wantDelay)
+			}
+			if s.Locked != tt.wantLocked {
+				t.Errorf("Locked = %v, want %v", s.Locked, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestPolicyNextHugeDelay(t *testing.T) {
+	p := Policy{FreeAttempts: 0, BaseDelay: time.Second, MaxDelay: time.Hour, Window: time.Hour}
+	now := time.Now()
+	// 指數溢位時仍以上限計
+	if got := remaining(p.next(500, now, now).BlockedUntil, now); got != time.Hour {
+		t.Errorf("delay = %v, want %v", got, time.Hour)
+	}
+}
+
+func TestPolicySucceed(t *testing.T) {
+	now := time.Now()
+	blocked := now.Add(time.Minute)
+
+	tests := []struct {
+		name         string
+		failures     int
+		blockedUntil time.Time
+		wantFailures int
+		wantBlocked  bool
+	}{
+		{"nothing counted", 0, time.Time{}, 0, false},
+		{"free attempt taken back", 2, time.Time{}, 1, false},
+		{"delay from this attempt lifted", 4, blocked, 3, false},
+		{"delay from earlier failures kept", 6, blocked, 5, true},
+		{"lockout kept", 10, blocked, 9, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures, until := testPolicy.succeed(tt.failures, tt.blockedUntil)
+			if failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", failures, tt.wantFailures)
+			}
+			if !until.IsZero() != tt.wantBlocked {
+				t.Errorf("blockedUntil = %v, want blocked %v", until, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestPolicyExpired(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		lastFailure  time.Time
+		blockedUntil time.Time
+		want         bool
+	}{
+		{"recent failure", now.Add(-time.Minute), time.Time{}, false},
+		{"old failure", now.Add(-2 * time.Hour), time.Time{}, true},
+		{"old failure still blocked", now.Add(-2 * time.Hour), now.Add(time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testPolicy.expired(tt.lastFailure, tt.blockedUntil, now); got != tt.want {
+				t.Errorf("expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryAttempt(t *testing.T) {
+	m := NewMemory(testPolicy)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= testPolicy.FreeAttempts+1; i++ {
+		wait, s, err := m.Attempt("alice", now)
+		if err != nil || wait != 0 {
+			t.Fatalf("attempt %d: wait = %v, err = %v, want allowed", i, wait, err)
+		}
+		if s.Failures != i {
+			t.Fatalf("attempt %d: Failures = %d", i, s.Failures)
+		}
+	}
+
+	// 第四次失敗後需等待一秒，等待期間的嘗試不計入
+	for i := 0; i < 3; i++ {
+		wait, s, err := m.Attempt("alice", now.Add(500*time.Millisecond))
+		if err != nil || wait != 500*time.Millisecond {
+			t.Fatalf("blocked attempt: wait = %v, err = %v, want 500ms", wait, err)
+		}
+		if s.Failures != testPolicy.FreeAttempts+1 {
+			t.Fatalf("blocked attempt counted: Failures = %d", s.Failures)
+		}
+	}
+	if wait, _, _ := m.Attempt("bob", now); wait != 0 {
+		t.Errorf("other key blocked for %v", wait)
+	}
+
+	wait, s, _ := m.Attempt("alice", now.Add(time.Second))
+	if wait != 0 || s.Failures != testPolicy.FreeAttempts+2 {
+		t.Errorf("after delay: wait = %v, Failures = %d", wait, s.Failures)
+	}
+
+	if err := m.Reset("alice"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if wait, s, _ := m.Attempt("alice", now.Add(time.Second)); wait != 0 || s.Failures != 1 {
+		t.Errorf("after reset: wait = %v, Failures = %d", wait, s.Failures)
+	}
+}
+
+func TestMemorySucceed(t *testing.T) {
+	m := NewMemory(testPolicy)
+	now := time.Now()
+
+	// 成功的嘗試撤回後，不佔用免等待的次數
+	for i := 0; i < 2*testPolicy.FreeAttempts; i++ {
+		wait, _, _ := m.Attempt("10.0.0.1", now)
+		if wait != 0 {
+			t.Fatalf("attempt %d blocked for %v", i+1, wait)
+		}
+		if err := m.Succeed("10.0.0.1"); err != nil {
+			t.Fatalf("Succeed: %v", err)
+		}
+	}
+	if err := m.Succeed("unknown"); err != nil {
+		t.Errorf("Succeed for an unknown key: %v", err)
+	}
+}
+
+func TestMemoryConcurrentAttempts(t *testing.T) {
+	m := NewMemory(testPolicy)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if wait, _, _ := m.Attempt("alice", now); wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 同時送出的嘗試中，只有免等待的次數加上觸發延遲的那一次能通過
+	if want := testPolicy.FreeAttempts + 1; allowed != want {
+		t.Errorf("allowed %d concurrent attempts, want %d", allowed, want)
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	long := make([]byte, 1000)
+	for i := range long {
+		long[i] = 'a'
+	}
+
+	tests := []string{"", "alice", string(long)}
+	for _, key := range tests {
+		if got := HashKey(key); len(got) != 64 {
+			t.Errorf("HashKey(%.10q) has length %d, want 64", key, len(got))
+		}
+	}
+	if HashKey("alice") == HashKey("Alice") {
+		t.Error("HashKey does not distinguish keys")
+	}
+}
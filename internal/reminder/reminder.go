@@ -0,0 +1,162 @@
+// Package reminder sends each user a daily digest of due cards and goal progress through their chosen notifier.
+package reminder
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"vocabulary/internal/models"
+	"vocabulary/internal/notify"
+)
+
+// 摘要中最多列出的到期單字
+const digestWords = 10
+
+// Digest is the structured content of a reminder, sent as the data of webhook payloads
+type Digest struct {
+	Date     string         `json:"date"`
+	Due      int            `json:"due"`
+	New      int            `json:"new"`
+	Words    []string       `json:"words"`
+	Progress map[string]int `json:"progress"`
+	Goals    map[string]int `json:"goals"`
+	GoalsMet bool           `json:"goals_met"`
+	Streak   int            `json:"streak"`
+}
+
+// Start checks for reminders to send every interval until the process exits
+func Start(db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			RunOnce(db, now)
+		}
+	}()
+}
+
+// RunOnce sends the day's reminder to every user whose reminder hour has passed in their time zone and who
+// has not been reminded today; users with nothing due and their goals met are skipped
+func RunOnce(db *sql.DB, now time.Time) {
+	prefs, err := models.GetReminderPreferences(db)
+	if err != nil {
+		log.Println("Error fetching reminder preferences:", err)
+		return
+	}
+
+	for i := range prefs {
+		p := &prefs[i]
+		local := now.In(p.Location())
+		today := local.Format(models.DayFormat)
+		if local.Hour() < p.ReminderHour || p.LastRemindedOn == today {
+			continue
+		}
+
+		// 先認領今天的提醒，多個執行個體同時執行時只有一個會寄出
+		claimed, err := models.ClaimReminder(db, p.UserID, today)
+		if err != nil {
+			log.Printf("Error claiming reminder for user %d: %v", p.UserID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		// 無法寄出時釋放認領，下次檢查時重試
+		release := func() {
+			if err := models.ReleaseReminder(db, p.UserID, today, p.LastRemindedOn); err != nil {
+				log.Printf("Error releasing reminder for user %d: %v", p.UserID, err)
+			}
+		}
+
+		digest, err := BuildDigest(db, p, now)
+		if err != nil {
+			log.Printf("Error building reminder for user %d: %v", p.UserID, err)
+			release()
+			continue
+		}
+		if digest.Due == 0 && digest.GoalsMet {
+			continue
+		}
+		if err := Send(p, digest); err != nil {
+			log.Printf("Error sending reminder to user %d: %v", p.UserID, err)
+			release()
+		}
+	}
+}
+
+// BuildDigest gathers the user's due words, today's goal progress and streak
+func BuildDigest(db *sql.DB, p *models.Preferences, now time.Time) (*Digest, error) {
+	due, err := models.GetDueSummary(db, p.UserID, now, digestWords)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := models.GetDailyProgress(db, p.UserID, p.Location(), now, now)
+	if err != nil {
+		return nil, err
+	}
+	streak, err := models.UpdateStreak(db, p, now)
+	if err != nil {
+		return nil, err
+	}
+
+	today := progress[now.In(p.Location()).Format(models.DayFormat)]
+	return &Digest{
+		Date:  today.Day,
+		Due:   due.Due,
+		New:   due.New,
+		Words: due.Words,
+		Progress: map[string]int{
+			"new_words": today.NewWords,
+			"reviews":   today.Reviews,
+			"minutes":   today.Minutes,
+		},
+		Goals: map[string]int{
+			"new_words": p.GoalNewWords,
+			"reviews":   p.GoalReviews,
+			"minutes":   p.GoalMinutes,
+		},
+		GoalsMet: p.GoalsMet(*today),
+		Streak:   streak.Current,
+	}, nil
+}
+
+// Send delivers a digest through the user's reminder channel
+func Send(p *models.Preferences, d *Digest) error {
+	return notify.Send(p.ReminderChannel, p.ReminderTarget, notify.Message{
+		Subject: fmt.Sprintf("%d words to review today", d.Due),
+		Text:    formatDigest(p, d),
+		Data:    d,
+	})
+}
+
+func formatDigest(p *models.Preferences, d *Digest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You have %d words due for review", d.Due)
+	if d.New > 0 {
+		fmt.Fprintf(&b, " and %d new words waiting", d.New)
+	}
+	b.WriteString(".\n")
+	if len(d.Words) > 0 {
+		fmt.Fprintf(&b, "\nDue now: %s\n", strings.Join(d.Words, ", "))
+	}
+
+	if p.HasGoals() {
+		b.WriteString("\nToday's goals:\n")
+		if p.GoalNewWords > 0 {
+			fmt.Fprintf(&b, "- New words: %d / %d\n", d.Progress["new_words"], p.GoalNewWords)
+		}
+		if p.GoalReviews > 0 {
+			fmt.Fprintf(&b, "- Reviews: %d / %d\n", d.Progress["reviews"], p.GoalReviews)
+		}
+		if p.GoalMinutes > 0 {
+			fmt.Fprintf(&b, "- Minutes read: %d / %d\n", d.Progress["minutes"], p.GoalMinutes)
+		}
+	}
+	if d.Streak > 0 {
+		fmt.Fprintf(&b, "\nCurrent streak: %d days. Keep it going!\n", d.Streak)
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+// FSRS 的遺忘曲線常數（FSRS-4.5）
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+)
+
+// 答對以 Good 評分，答錯以 Again 評分
+const (
+	ratingAgain = 1
+	ratingGood  = 3
+)
+
+// DefaultWeights are the published FSRS-4.5 default parameters
+var DefaultWeights = []float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474,
+	0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+// weightBounds keeps optimized parameters within the ranges used by the reference implementation
+var weightBounds = [][2]float64{
+	{0.1, 100}, {0.1, 100}, {0.1, 100}, {0.1, 100}, {1, 10}, {0.1, 5}, {0.1, 5}, {0, 0.75}, {0, 4.5},
+	{0, 0.8}, {0.01, 3.5}, {0.1, 5}, {0.01, 0.25}, {0.01, 0.9}, {0, 4}, {0, 1}, {1, 6},
+}
+
+// DefaultRequestRetention is the recall probability FSRS aims for when a word becomes due
+const DefaultRequestRetention = 0.9
+
+// FSRSScheduler implements the Free Spaced Repetition Scheduler (FSRS-4.5)
+type FSRSScheduler struct {
+	W                []float64
+	RequestRetention float64
+}
+
+// NewFSRS returns an FSRS scheduler, using the defaults for missing or invalid parameters
+func NewFSRS(weights []float64, requestRetention float64) FSRSScheduler {
+	if len(weights) != len(DefaultWeights) {
+		weights = DefaultWeights
+	}
+	if requestRetention <= 0 || requestRetention >= 1 {
+		requestRetention = DefaultRequestRetention
+	}
+	return FSRSScheduler{W: weights, RequestRetention: requestRetention}
+}
+
+func (f FSRSScheduler) Review(s State, correct bool, now time.Time) State {
+	rating := ratingAgain
+	if correct {
+		rating = ratingGood
+	}
+
+	if s.Stability == 0 {
+		s.Stability = f.initialStability(rating)
+		s.Difficulty = f.initialDifficulty(rating)
+	} else {
+		r := retrievability(elapsedDays(s, now), s.Stability)
+		if correct {
+			s.Stability = f.recallStability(s.Difficulty, s.Stability, r, rating)
+		} else {
+			s.Stability = f.forgetStability(s.Difficulty, s.Stability, r)
+		}
+		s.Difficulty = f.nextDifficulty(s.Difficulty, rating)
+	}
+
+	if correct {
+		s.Reps++
+	} else {
+		s.Lapses++
+	}
+	s.LastReview = now
+	s.Due = addDays(now, f.nextInterval(s.Stability))
+	return s
+}
+
+func (f FSRSScheduler) Retrievability(s State, now time.Time) float64 {
+	if s.LastReview.IsZero() || s.Stability == 0 {
+		return 0
+	}
+	return retrievability(elapsedDays(s, now), s.Stability)
+}
+
+// retrievability is the probability of recall t days after a review with stability s
+func retrievability(t, s float64) float64 {
+	return math.Pow(1+fsrsFactor*t/s, fsrsDecay)
+}
+
+func (f FSRSScheduler) nextInterval(stability float64) float64 {
+	interval := stability / fsrsFactor * (math.Pow(f.RequestRetention, 1/fsrsDecay) - 1)
+	return clamp(math.Round(interval), 1, maxIntervalDays)
+}
+
+func (f FSRSScheduler) initialStability(rating int) float64 {
+	return math.Max(f.W[rating-1], 0.1)
+}
+
+func (f FSRSScheduler) initialDifficulty(rating int) float64 {
+	return clamp(f.W[4]-float64(rating-3)*f.W[5], 1, 10)
+}
+
+func (f FSRSScheduler) nextDifficulty(d float64, rating int) float64 {
+	next := d - f.W[6]*float64(rating-3)
+	// 向初始難度回歸，避免難度持續累積
+	return clamp(f.W[7]*f.initialDifficulty(ratingGood)+(1-f.W[7])*next, 1, 10)
+}
+
+func (f FSRSScheduler) recallStability(d, s, r float64, rating int) float64 {
+	hardPenalty, easyBonus := 1.0, 1.0
+	if rating == 2 {
+		hardPenalty = f.W[15]
+	}
+	if rating == 4 {
+		easyBonus = f.W[16]
+	}
+	return s * (1 + math.Exp(f.W[8])*(11-d)*math.Pow(s, -f.W[9])*(math.Exp((1-r)*f.W[10])-1)*hardPenalty*easyBonus)
+}
+
+func (f FSRSScheduler) forgetStability(d, s, r float64) float64 {
+	next := f.W[11] * math.Pow(d, -f.W[12]) * (math.Pow(s+1, f.W[13]) - 1) * math.Exp((1-r)*f.W[14])
+	return math.Max(0.1, math.Min(next, s))
+}
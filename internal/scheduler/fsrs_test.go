@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewFSRSDefaults(t *testing.T) {
+	tests := []struct {
+		name          string
+		weights       []float64
+		retention     float64
+		wantRetention float64
+	}{
+		{"defaults for nil", nil, 0, DefaultRequestRetention},
+		{"wrong weight count", []float64{1, 2, 3}, 0.85, 0.85},
+		{"retention of one", DefaultWeights, 1, DefaultRequestRetention},
+		{"negative retention", DefaultWeights, -0.5, DefaultRequestRetention},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFSRS(tt.weights, tt.retention)
+			if !reflect.DeepEqual(f.W, DefaultWeights) {
+				t.Errorf("W = %v, want DefaultWeights", f.W)
+			}
+			if f.RequestRetention != tt.wantRetention {
+				t.Errorf("RequestRetention = %v, want %v", f.RequestRetention, tt.wantRetention)
+			}
+		})
+	}
+}
+
+func TestFSRSFirstReview(t *testing.T) {
+	f := NewFSRS(nil, 0)
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		correct        bool
+		wantStability  float64
+		wantDifficulty float64
+		wantDueDays    int
+	}{
+		{"good", true, DefaultWeights[2], DefaultWeights[4], 4},
+		{"again", false, DefaultWeights[0], DefaultWeights[4] + 2*DefaultWeights[5], 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := f.Review(State{}, tt.correct, now)
+			if math.Abs(s.Stability-tt.wantStability) > 1e-9 {
+				t.Errorf("Stability = %v, want %v", s.Stability, tt.wantStability)
+			}
+			if math.Abs(s.Difficulty-tt.wantDifficulty) > 1e-9 {
+				t.Errorf("Difficulty = %v, want %v", s.Difficulty, tt.wantDifficulty)
+			}
+			if want := now.AddDate(0, 0, tt.wantDueDays); !s.Due.Equal(want) {
+				t.Errorf("Due = %v, want %v", s.Due, want)
+			}
+		})
+	}
+}
+
+func TestFSRSLaterReviews(t *testing.T) {
+	f := NewFSRS(nil, 0)
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	first := f.Review(State{}, true, start)
+
+	recalled := f.Review(first, true, first.Due)
+	if recalled.Stability <= first.Stability {
+		t.Errorf("stability after recall = %v, want more than %v", recalled.Stability, first.Stability)
+	}
+	if recalled.Reps != 2 || recalled.Lapses != 0 {
+		t.Errorf("Reps, Lapses = %d, %d, want 2, 0", recalled.Reps, recalled.Lapses)
+	}
+
+	forgotten := f.Review(first, false, first.Due)
+	if forgotten.Stability >= first.Stability {
+		t.Errorf("stability after lapse = %v, want less than %v", forgotten.Stability, first.Stability)
+	}
+	if forgotten.Difficulty <= first.Difficulty {
+		t.Errorf("difficulty after lapse = %v, want more than %v", forgotten.Difficulty, first.Difficulty)
+	}
+	if forgotten.Lapses != 1 {
+		t.Errorf("Lapses = %d, want 1", forgotten.Lapses)
+	}
+
+	// 難度無論答錯幾次都維持在 1–10
+	s := first
+	for i := 0; i < 50; i++ {
+		s = f.Review(s, false, s.Due)
+	}
+	if s.Difficulty < 1 || s.Difficulty > 10 {
+		t.Errorf("Difficulty = %v, want within [1, 10]", s.Difficulty)
+	}
+}
+
+func TestFSRSRetrievability(t *testing.T) {
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := State{LastReview: last, Stability: 10}
+
+	tests := []struct {
+		name string
+		s    State
+		now  time.Time
+		want float64
+	}{
+		{"never reviewed", State{}, last, 0},
+		{"just reviewed", s, last, 1},
+		// 經過的天數等於穩定度時，記憶率恰為 90%
+		{"after stability days", s, last.AddDate(0, 0, 10), 0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewFSRS(nil, 0).Retrievability(tt.s, tt.now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Retrievability = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFSRSNextInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention float64
+		stability float64
+		want      float64
+	}{
+		{"90% retention equals stability", 0.9, 10, 10},
+		{"higher retention is shorter", 0.95, 10, 5},
+		{"at least one day", 0.9, 0.1, 1},
+		{"capped", 0.9, 1e9, maxIntervalDays},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewFSRS(nil, tt.retention).nextInterval(tt.stability); got != tt.want {
+				t.Errorf("nextInterval(%v) = %v, want %v", tt.stability, got, tt.want)
+			}
+		})
+	}
+}
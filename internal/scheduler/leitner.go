@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// 盒子數量上下限
+const (
+	MinLeitnerBoxes = 2
+	MaxLeitnerBoxes = 10
+)
+
+// DefaultLeitnerIntervals are the review intervals in days of the classic five boxes
+var DefaultLeitnerIntervals = []float64{1, 2, 4, 8, 16}
+
+// ErrInvalidLeitnerIntervals is returned for interval lists that cannot describe a set of boxes
+var ErrInvalidLeitnerIntervals = errors.New("leitner intervals must list 2 to 10 non-decreasing whole numbers of days")
+
+// LeitnerScheduler moves a word up one box on every correct answer and back to the first box on
+// every incorrect one; each box has its own fixed review interval
+type LeitnerScheduler struct {
+	Intervals []float64 // 第 i 個盒子的間隔天數
+}
+
+// NewLeitner returns a Leitner scheduler, using the default boxes for missing or invalid intervals
+func NewLeitner(intervals []float64) LeitnerScheduler {
+	if ValidateLeitnerIntervals(intervals) != nil {
+		intervals = DefaultLeitnerIntervals
+	}
+	return LeitnerScheduler{Intervals: intervals}
+}
+
+// ValidateLeitnerIntervals checks that intervals describe between MinLeitnerBoxes and MaxLeitnerBoxes
+// boxes with non-decreasing whole-day intervals of at most a hundred years
+func ValidateLeitnerIntervals(intervals []float64) error {
+	if len(intervals) < MinLeitnerBoxes || len(intervals) > MaxLeitnerBoxes {
+		return ErrInvalidLeitnerIntervals
+	}
+	for i, days := range intervals {
+		if days < 1 || days > maxIntervalDays || days != math.Trunc(days) {
+			return ErrInvalidLeitnerIntervals
+		}
+		if i > 0 && days < intervals[i-1] {
+			return ErrInvalidLeitnerIntervals
+		}
+	}
+	return nil
+}
+
+func (l LeitnerScheduler) Review(s State, correct bool, now time.Time) State {
+	// 新單字視為在第一個盒子
+	if s.Box < 1 {
+		s.Box = 1
+	}
+	if correct {
+		if s.Box < len(l.Intervals) {
+			s.Box++
+		}
+		s.Reps++
+	} else {
+		s.Box = 1
+		s.Lapses++
+	}
+
+	s.Interval = l.Intervals[s.Box-1]
+	s.LastReview = now
+	s.Due = addDays(now, s.Interval)
+	return s
+}
+
+// Retrievability assumes, like SM-2, that 90% of words are still recalled when their box comes due
+func (LeitnerScheduler) Retrievability(s State, now time.Time) float64 {
+	if s.LastReview.IsZero() || s.Interval == 0 {
+		return 0
+	}
+	return math.Pow(0.9, elapsedDays(s, now)/s.Interval)
+}
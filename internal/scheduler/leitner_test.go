@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateLeitnerIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []float64
+		wantErr   bool
+	}{
+		{"defaults", DefaultLeitnerIntervals, false},
+		{"minimum boxes", []float64{1, 3}, false},
+		{"maximum boxes", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false},
+		{"equal neighbours allowed", []float64{1, 1, 2}, false},
+		{"longest interval", []float64{1, maxIntervalDays}, false},
+		{"nil", nil, true},
+		{"one box", []float64{1}, true},
+		{"too many boxes", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, true},
+		{"decreasing", []float64{1, 4, 2}, true},
+		{"zero days", []float64{0, 1}, true},
+		{"fractional days", []float64{1, 2.5}, true},
+		{"too long", []float64{1, maxIntervalDays + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLeitnerIntervals(tt.intervals)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("ValidateLeitnerIntervals(%v) = %v, wantErr %v", tt.intervals, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidLeitnerIntervals) {
+				t.Errorf("error = %v, want ErrInvalidLeitnerIntervals", err)
+			}
+		})
+	}
+}
+
+func TestNewLeitnerFallsBackToDefaults(t *testing.T) {
+	l := NewLeitner([]float64{3, 1})
+	if len(l.Intervals) != len(DefaultLeitnerIntervals) {
+		t.Errorf("Intervals = %v, want %v", l.Intervals, DefaultLeitnerIntervals)
+	}
+}
+
+func TestLeitnerReview(t *testing.T) {
+	l := NewLeitner([]float64{1, 3, 7})
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		answers    []bool
+		wantBox    int
+		wantDays   float64
+		wantLapses int
+	}{
+		{"new word answered correctly", []bool{true}, 2, 3, 0},
+		{"new word answered incorrectly", []bool{false}, 1, 1, 1},
+		{"climbs one box per answer", []bool{true, true}, 3, 7, 0},
+		{"stays in the last box", []bool{true, true, true, true}, 3, 7, 0},
+		{"back to the first box on a lapse", []bool{true, true, false}, 1, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s State
+			now := start
+			for _, correct := range tt.answers {
+				s = l.Review(s, correct, now)
+				now = s.Due
+			}
+			if s.Box != tt.wantBox {
+				t.Errorf("Box = %d, want %d", s.Box, tt.wantBox)
+			}
+			if s.Interval != tt.wantDays {
+				t.Errorf("Interval = %v, want %v", s.Interval, tt.wantDays)
+			}
+			if s.Lapses != tt.wantLapses {
+				t.Errorf("Lapses = %d, want %d", s.Lapses, tt.wantLapses)
+			}
+			if want := addDays(s.LastReview, s.Interval); !s.Due.Equal(want) {
+				t.Errorf("Due = %v, want %v", s.Due, want)
+			}
+		})
+	}
+}
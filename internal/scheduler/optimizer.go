@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// MinOptimizeReviews is the number of scored reviews needed before fitting parameters is worthwhile
+const MinOptimizeReviews = 50
+
+const (
+	optimizeIterations = 150
+	optimizeStep       = 0.02
+	gradientDelta      = 1e-4
+	// 單次最佳化最多使用的複習紀錄數，避免執行時間過長
+	maxOptimizeReviews = 50000
+)
+
+// ErrNotEnoughReviews is returned when the history is too short to optimize on
+var ErrNotEnoughReviews = errors.New("not enough review history to optimize")
+
+// OptimizeResult describes the fitted FSRS parameters
+type OptimizeResult struct {
+	Weights     []float64 `json:"weights"`
+	Reviews     int       `json:"reviews"`
+	InitialLoss float64   `json:"initial_loss"`
+	FinalLoss   float64   `json:"final_loss"`
+}
+
+// Optimize fits FSRS parameters to per-word review histories by minimizing the log loss of
+// predicted retrievability against the recorded outcomes, starting from the default parameters.
+// Histories should be in a stable order, as only the first maxOptimizeReviews reviews are used; days are
+// counted in loc. It stops with the context's error when ctx is cancelled.
+func Optimize(ctx context.Context, histories [][]Review, loc *time.Location) (*OptimizeResult, error) {
+	histories = dailyReviews(histories, loc)
+	_, n := logLoss(DefaultWeights, histories)
+	if n < MinOptimizeReviews {
+		return nil, ErrNotEnoughReviews
+	}
+
+	w := append([]float64{}, DefaultWeights...)
+	initial, _ := logLoss(w, histories)
+	best, bestLoss := append([]float64{}, w...), initial
+
+	// Adam，以數值微分估計梯度，步長依參數大小縮放
+	m := make([]float64, len(w))
+	v := make([]float64, len(w))
+	const beta1, beta2, epsilon = 0.9, 0.999, 1e-8
+	for iter := 1; iter <= optimizeIterations; iter++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		loss, _ := logLoss(w, histories)
+		grad := make([]float64, len(w))
+		for i := range w {
+			orig := w[i]
+			w[i] = orig + gradientDelta
+			shifted, _ := logLoss(w, histories)
+			w[i] = orig
+			grad[i] = (shifted - loss) / gradientDelta
+		}
+
+		for i := range w {
+			m[i] = beta1*m[i] + (1-beta1)*grad[i]
+			v[i] = beta2*v[i] + (1-beta2)*grad[i]*grad[i]
+			mHat := m[i] / (1 - math.Pow(beta1, float64(iter)))
+			vHat := v[i] / (1 - math.Pow(beta2, float64(iter)))
+			scale := math.Max(math.Abs(w[i]), 0.05)
+			w[i] = clamp(w[i]-optimizeStep*scale*mHat/(math.Sqrt(vHat)+epsilon), weightBounds[i][0], weightBounds[i][1])
+		}
+
+		if loss, _ := logLoss(w, histories); loss < bestLoss {
+			bestLoss = loss
+			copy(best, w)
+		}
+	}
+
+	return &OptimizeResult{Weights: best, Reviews: n, InitialLoss: initial, FinalLoss: bestLoss}, nil
+}
+
+// logLoss returns the mean binary cross-entropy of FSRS predictions and the number of predictions made;
+// a word's first review has nothing to predict and only initializes its state
+func logLoss(w []float64, histories [][]Review) (float64, int) {
+	f := FSRSScheduler{W: w, RequestRetention: DefaultRequestRetention}
+	var total float64
+	var n int
+	for _, reviews := range histories {
+		var state State
+		for i, r := range reviews {
+			if i > 0 {
+				p := clamp(retrievability(elapsedDays(state, r.At), state.Stability), 1e-4, 1-1e-4)
+				if r.Correct {
+					total -= math.Log(p)
+				} else {
+					total -= math.Log(1 - p)
+				}
+				n++
+			}
+			state = f.Review(state, r.Correct, r.At)
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return total / float64(n), n
+}
+
+// dailyReviews keeps only the first review of each word per day in loc, as same-day repeats say little
+// about long-term memory, and caps the total number of reviews
+func dailyReviews(histories [][]Review, loc *time.Location) [][]Review {
+	var result [][]Review
+	total := 0
+	for _, reviews := range histories {
+		var kept []Review
+		for _, r := range reviews {
+			if len(kept) > 0 {
+				at, last := r.At.In(loc), kept[len(kept)-1].At.In(loc)
+				if at.Year() == last.Year() && at.YearDay() == last.YearDay() {
+					continue
+				}
+			}
+			kept = append(kept, r)
+		}
+		if len(kept) < 2 {
+			continue
+		}
+		if total+len(kept) > maxOptimizeReviews {
+			break
+		}
+		total += len(kept)
+		result = append(result, kept)
+	}
+	return result
+}
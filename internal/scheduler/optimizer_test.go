@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDailyReviews(t *testing.T) {
+	taipei := time.FixedZone("UTC+8", 8*60*60)
+	at := func(day, hour, minute int) Review {
+		return Review{At: time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC), Correct: true}
+	}
+
+	tests := []struct {
+		name      string
+		histories [][]Review
+		loc       *time.Location
+		want      [][]Review
+	}{
+		{
+			name:      "same-day repeats dropped",
+			histories: [][]Review{{at(1, 9, 0), at(1, 10, 0), at(2, 9, 0)}},
+			loc:       time.UTC,
+			want:      [][]Review{{at(1, 9, 0), at(2, 9, 0)}},
+		},
+		{
+			name:      "days split at UTC midnight",
+			histories: [][]Review{{at(1, 23, 30), at(2, 0, 30)}},
+			loc:       time.UTC,
+			want:      [][]Review{{at(1, 23, 30), at(2, 0, 30)}},
+		},
+		{
+			// 在 UTC+8 兩次複習都落在 1 月 2 日早上
+			name:      "days counted in the user's time zone",
+			histories: [][]Review{{at(1, 23, 30), at(2, 0, 30), at(3, 0, 30)}},
+			loc:       taipei,
+			want:      [][]Review{{at(1, 23, 30), at(3, 0, 30)}},
+		},
+		{
+			name:      "words with one day of reviews skipped",
+			histories: [][]Review{{at(1, 9, 0), at(1, 10, 0)}, {at(1, 9, 0), at(2, 9, 0)}},
+			loc:       time.UTC,
+			want:      [][]Review{{at(1, 9, 0), at(2, 9, 0)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dailyReviews(tt.histories, tt.loc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dailyReviews = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDailyReviewsCap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	word := func(id int) []Review {
+		reviews := make([]Review, 1000)
+		for i := range reviews {
+			reviews[i] = Review{At: start.AddDate(0, 0, i), Correct: id%2 == 0}
+		}
+		return reviews
+	}
+	var histories [][]Review
+	for id := 0; id < maxOptimizeReviews/1000+5; id++ {
+		histories = append(histories, word(id))
+	}
+
+	got := dailyReviews(histories, time.UTC)
+	if len(got) != maxOptimizeReviews/1000 {
+		t.Fatalf("kept %d words, want %d", len(got), maxOptimizeReviews/1000)
+	}
+	// 超過上限時保留排在前面的單字，結果不受執行順序影響
+	for i, reviews := range got {
+		if !reflect.DeepEqual(reviews, histories[i]) {
+			t.Fatalf("word %d is not the %dth input word", i, i)
+		}
+	}
+}
+
+// syntheticHistories returns words reviewed every few days, forgetting the ones reviewed after long gaps
+func syntheticHistories(words int) [][]Review {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	histories := make([][]Review, words)
+	for w := range histories {
+		at := start
+		for i := 0; i < 6; i++ {
+			gap := 1 + (w+i)%7
+			at = at.AddDate(0, 0, gap)
+			histories[w] = append(histories[w], Review{At: at, Correct: gap < 5})
+		}
+	}
+	return histories
+}
+
+func TestOptimize(t *testing.T) {
+	histories := syntheticHistories(20)
+
+	first, err := Optimize(context.Background(), histories, time.UTC)
+	if err != nil {
+		t.Fatalf("Optimize: %v", err)
+	}
+	if first.Reviews != 100 {
+		t.Errorf("Reviews = %d, want 100", first.Reviews)
+	}
+	if first.FinalLoss > first.InitialLoss {
+		t.Errorf("FinalLoss = %v, want at most InitialLoss %v", first.FinalLoss, first.InitialLoss)
+	}
+	for i, w := range first.Weights {
+		if w < weightBounds[i][0] || w > weightBounds[i][1] {
+			t.Errorf("weight %d = %v, outside %v", i, w, weightBounds[i])
+		}
+	}
+
+	second, err := Optimize(context.Background(), histories, time.UTC)
+	if err != nil {
+		t.Fatalf("Optimize: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Optimize is not deterministic: %v, then %v", first.Weights, second.Weights)
+	}
+}
+
+func TestOptimizeErrors(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		histories [][]Review
+		want      error
+	}{
+		{"no history", context.Background(), nil, ErrNotEnoughReviews},
+		{"too few reviews", context.Background(), syntheticHistories(5), ErrNotEnoughReviews},
+		{"cancelled", cancelled, syntheticHistories(20), context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Optimize(tt.ctx, tt.histories, time.UTC); !errors.Is(err, tt.want) {
+				t.Errorf("Optimize error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
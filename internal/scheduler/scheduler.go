@@ -0,0 +1,105 @@
+// Package scheduler computes review intervals for vocabulary words from their review history.
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+// 排程演算法名稱，存於 scheduler_settings.algorithm
+const (
+	SM2     = "sm2"
+	FSRS    = "fsrs"
+	Leitner = "leitner"
+)
+
+// Algorithms lists the supported algorithm names
+var Algorithms = []string{SM2, FSRS, Leitner}
+
+// maxIntervalDays caps every interval at about a hundred years
+const maxIntervalDays = 36500
+
+// Review is one graded answer for a word
+type Review struct {
+	At      time.Time
+	Correct bool
+}
+
+// State is the scheduling state of a single word. Each algorithm only uses its own fields.
+type State struct {
+	Reps       int
+	Lapses     int
+	LastReview time.Time
+	Due        time.Time
+
+	// SM-2
+	EaseFactor float64
+	Interval   float64 // 天
+
+	// FSRS
+	Stability  float64 // 天
+	Difficulty float64 // 1–10
+
+	// Leitner，0 表示尚未進入任何盒子
+	Box int
+}
+
+// Scheduler updates a word's state after a review and predicts how likely it is to be recalled
+type Scheduler interface {
+	Review(s State, correct bool, now time.Time) State
+	Retrievability(s State, now time.Time) float64
+}
+
+// Config holds the parameters of every algorithm; each scheduler reads only its own
+type Config struct {
+	Algorithm        string
+	Weights          []float64
+	RequestRetention float64
+	LeitnerIntervals []float64
+}
+
+// New returns the scheduler for the configured algorithm; unknown names fall back to SM-2
+func New(cfg Config) Scheduler {
+	switch cfg.Algorithm {
+	case FSRS:
+		return NewFSRS(cfg.Weights, cfg.RequestRetention)
+	case Leitner:
+		return NewLeitner(cfg.LeitnerIntervals)
+	}
+	return SM2Scheduler{}
+}
+
+// Valid reports whether algorithm is a supported algorithm name
+func Valid(algorithm string) bool {
+	for _, a := range Algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay runs a word's reviews through the scheduler in order and returns the resulting state
+func Replay(s Scheduler, reviews []Review) State {
+	var state State
+	for _, r := range reviews {
+		state = s.Review(state, r.Correct, r.At)
+	}
+	return state
+}
+
+// elapsedDays returns the days between the last review and now, never negative
+func elapsedDays(s State, now time.Time) float64 {
+	if s.LastReview.IsZero() {
+		return 0
+	}
+	return math.Max(0, now.Sub(s.LastReview).Hours()/24)
+}
+
+func addDays(t time.Time, days float64) time.Time {
+	return t.Add(time.Duration(days * 24 * float64(time.Hour)))
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	sm2InitialEase = 2.5
+	sm2MinEase     = 1.3
+	// 以 SM-2 的 0–5 分計：答對視為 4，答錯視為 1
+	sm2CorrectQuality   = 4
+	sm2IncorrectQuality = 1
+)
+
+// SM2Scheduler is the classic SuperMemo 2 algorithm with pass/fail grading
+type SM2Scheduler struct{}
+
+func (SM2Scheduler) Review(s State, correct bool, now time.Time) State {
+	if s.EaseFactor == 0 {
+		s.EaseFactor = sm2InitialEase
+	}
+
+	q := float64(sm2IncorrectQuality)
+	if correct {
+		q = sm2CorrectQuality
+	}
+
+	if correct {
+		switch s.Reps {
+		case 0:
+			s.Interval = 1
+		case 1:
+			s.Interval = 6
+		default:
+			s.Interval = math.Round(s.Interval * s.EaseFactor)
+		}
+		s.Reps++
+	} else {
+		s.Reps = 0
+		s.Interval = 1
+		s.Lapses++
+	}
+	s.Interval = math.Min(s.Interval, maxIntervalDays)
+
+	s.EaseFactor = math.Max(sm2MinEase, s.EaseFactor+0.1-(5-q)*(0.08+(5-q)*0.02))
+	s.LastReview = now
+	s.Due = addDays(now, s.Interval)
+	return s
+}
+
+// Retrievability assumes the interval was chosen so that 90% of words are still recalled when due
+func (SM2Scheduler) Retrievability(s State, now time.Time) float64 {
+	if s.LastReview.IsZero() || s.Interval == 0 {
+		return 0
+	}
+	return math.Pow(0.9, elapsedDays(s, now)/s.Interval)
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSM2Review(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		answers      []bool
+		wantInterval float64
+		wantEase     float64
+		wantReps     int
+		wantLapses   int
+	}{
+		{"first correct", []bool{true}, 1, 2.5, 1, 0},
+		{"second correct", []bool{true, true}, 6, 2.5, 2, 0},
+		{"third correct multiplies by ease", []bool{true, true, true}, 15, 2.5, 3, 0},
+		{"lapse resets interval", []bool{true, true, false}, 1, 1.96, 0, 1},
+		{"ease never drops below minimum", []bool{false, false, false}, 1, sm2MinEase, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s State
+			now := start
+			for _, correct := range tt.answers {
+				s = SM2Scheduler{}.Review(s, correct, now)
+				now = s.Due
+			}
+			if s.Interval != tt.wantInterval {
+				t.Errorf("Interval = %v, want %v", s.Interval, tt.wantInterval)
+			}
+			if math.Abs(s.EaseFactor-tt.wantEase) > 1e-9 {
+				t.Errorf("EaseFactor = %v, want %v", s.EaseFactor, tt.wantEase)
+			}
+			if s.Reps != tt.wantReps || s.Lapses != tt.wantLapses {
+				t.Errorf("Reps, Lapses = %d, %d, want %d, %d", s.Reps, s.Lapses, tt.wantReps, tt.wantLapses)
+			}
+			if want := addDays(s.LastReview, s.Interval); !s.Due.Equal(want) {
+				t.Errorf("Due = %v, want %v", s.Due, want)
+			}
+		})
+	}
+}
+
+func TestSM2IntervalCap(t *testing.T) {
+	s := State{Reps: 5, Interval: maxIntervalDays, EaseFactor: 2.5}
+	s = SM2Scheduler{}.Review(s, true, time.Now())
+	if s.Interval != maxIntervalDays {
+		t.Errorf("Interval = %v, want %v", s.Interval, maxIntervalDays)
+	}
+}
+
+func TestSM2Retrievability(t *testing.T) {
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := State{LastReview: last, Interval: 10}
+
+	tests := []struct {
+		name string
+		s    State
+		now  time.Time
+		want float64
+	}{
+		{"never reviewed", State{}, last, 0},
+		{"just reviewed", s, last, 1},
+		{"when due", s, last.AddDate(0, 0, 10), 0.9},
+		{"twice the interval", s, last.AddDate(0, 0, 20), 0.81},
+		{"clock before last review", s, last.AddDate(0, 0, -1), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (SM2Scheduler{}).Retrievability(tt.s, tt.now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Retrievability = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package wordlist
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CEFR levels from easiest to hardest
+var Levels = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+
+// levelBands are the highest frequency ranks estimated at each CEFR level, used for words that cefr.tsv does
+// not list; the bands follow the usual vocabulary sizes for each level
+var levelBands = []int{1000, 2000, 4000, 8000, 14000, 20000}
+
+type Entry struct {
+	Rank  int      // 頻率排名，0 表示不在頻率表中
+	Level string   // CEFR 等級，空字串表示未知
+	Lists []string // 所屬的單字表，例如 awl
+}
+
+var (
+	mu      sync.RWMutex
+	entries = map[string]*Entry{}
+)
+
+// Load reads the bundled word lists from dir:
+//   - frequency.txt: one word per line, most frequent first
+//   - cefr.tsv: word and CEFR level separated by a tab; other ranked words get a level estimated from their rank
+//   - lists/*.txt: named word lists, one word per line
+func Load(dir string) error {
+	loaded := map[string]*Entry{}
+	get := func(word string) *Entry {
+		e, ok := loaded[word]
+		if !ok {
+			e = &Entry{}
+			loaded[word] = e
+		}
+		return e
+	}
+
+	rank := 0
+	err := readLines(filepath.Join(dir, "frequency.txt"), func(line string) {
+		e := get(line)
+		if e.Rank == 0 {
+			rank++
+			e.Rank = rank
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = readLines(filepath.Join(dir, "cefr.tsv"), func(line string) {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return
+		}
+		level := strings.ToUpper(strings.TrimSpace(fields[1]))
+		if LevelIndex(level) < 0 {
+			return
+		}
+		get(strings.TrimSpace(fields[0])).Level = level
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range loaded {
+		if e.Level == "" {
+			e.Level = estimateLevel(e.Rank)
+		}
+	}
+
+	lists, err := filepath.Glob(filepath.Join(dir, "lists", "*.txt"))
+	if err != nil {
+		return err
+	}
+	for _, path := range lists {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		err := readLines(path, func(line string) {
+			e := get(line)
+			e.Lists = append(e.Lists, name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	entries = loaded
+	mu.Unlock()
+	return nil
+}
+
+// Lookup returns the entry for a word, falling back to simple inflection stripping
+func Lookup(word string) Entry {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, candidate := range Lemmas(word) {
+		if e, ok := entries[candidate]; ok {
+			return *e
+		}
+	}
+	return Entry{}
+}
+
+// Lemmas returns the lowercase word followed by its likely base forms
+func Lemmas(word string) []string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	lemmas := []string{word}
+	add := func(suffix, replacement string, minLen int) {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= minLen {
+			lemmas = append(lemmas, strings.TrimSuffix(word, suffix)+replacement)
+		}
+	}
+
+	add("ies", "y", 2)
+	add("es", "", 3)
+	add("s", "", 3)
+	add("ied", "y", 2)
+	add("ed", "", 3)
+	add("ed", "e", 2)
+	add("ing", "", 3)
+	add("ing", "e", 2)
+	add("ly", "", 3)
+	return lemmas
+}
+
+// estimateLevel returns the CEFR level band of a frequency rank, or an empty string if the word is unranked
+// or rarer than the last band
+func estimateLevel(rank int) string {
+	if rank == 0 {
+		return ""
+	}
+	for i, max := range levelBands {
+		if rank <= max {
+			return Levels[i]
+		}
+	}
+	return ""
+}
+
+// LevelIndex returns the position of a CEFR level in Levels, or -1 if unknown
+func LevelIndex(level string) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// ListNames returns the names of the loaded word lists
+func ListNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	seen := map[string]bool{}
+	var names []string
+	for _, e := range entries {
+		for _, name := range e.Lists {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readLines(path string, fn func(line string)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fn(strings.ToLower(line))
+	}
+	return scanner.Err()
+}
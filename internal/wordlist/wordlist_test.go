@@ -0,0 +1,76 @@
+package wordlist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateLevel(t *testing.T) {
+	tests := []struct {
+		rank int
+		want string
+	}{
+		{0, ""},
+		{1, "A1"},
+		{1000, "A1"},
+		{1001, "A2"},
+		{4000, "B1"},
+		{8000, "B2"},
+		{14000, "C1"},
+		{20000, "C2"},
+		{20001, ""},
+	}
+	for _, tt := range tests {
+		if got := estimateLevel(tt.rank); got != tt.want {
+			t.Errorf("estimateLevel(%d) = %q, want %q", tt.rank, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("frequency.txt", "# comment\nthe\nrun\nThe\nanalyse\n")
+	write("cefr.tsv", "run\tb2\nanalyse\tX9\n")
+	write("lists/awl.txt", "analyse\n")
+	if err := Load(dir); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		entries = map[string]*Entry{}
+		mu.Unlock()
+	})
+
+	tests := []struct {
+		word      string
+		wantRank  int
+		wantLevel string
+		wantLists int
+	}{
+		// 重複的單字保留第一次出現的排名
+		{"the", 1, "A1", 0},
+		// cefr.tsv 的等級優先於估計值
+		{"runs", 2, "B2", 0},
+		// 無效的等級改用排名估計
+		{"analyse", 3, "A1", 1},
+		{"unknown", 0, "", 0},
+	}
+	for _, tt := range tests {
+		e := Lookup(tt.word)
+		if e.Rank != tt.wantRank || e.Level != tt.wantLevel || len(e.Lists) != tt.wantLists {
+			t.Errorf("Lookup(%q) = %+v, want rank %d, level %q and %d lists",
+				tt.word, e, tt.wantRank, tt.wantLevel, tt.wantLists)
+		}
+	}
+}
@@ -5,7 +5,49 @@ CREATE TABLE IF NOT EXISTS users (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
     username VARCHAR(50) NOT NULL UNIQUE,
     password VARCHAR(255) NOT NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    -- 由外部登入自動建立的帳號只有隨機的佔位密碼
+    password_set BOOLEAN NOT NULL DEFAULT TRUE,
+    email VARCHAR(255) NULL UNIQUE,
+    role ENUM('user', 'teacher', 'admin') NOT NULL DEFAULT 'user',
+    disabled_at DATETIME NULL,
+    -- 使用者要求刪除帳號後，到這個時間才會永久刪除資料
+    deletion_scheduled_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_users_deletion (deletion_scheduled_at)
+);
+-- 文章庫
+CREATE TABLE IF NOT EXISTS articles (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    source_type ENUM('url', 'txt', 'html', 'epub') NOT NULL DEFAULT 'url',
+    source_url VARCHAR(2048) NOT NULL DEFAULT '',
+    -- source_url 的 SHA-256，網址太長無法直接建立唯一索引；上傳的文件為 NULL
+    source_hash CHAR(64) NULL,
+    content MEDIUMTEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY unique_user_source (user_id, source_hash)
+);
+-- 文章章節（上傳的文件）
+CREATE TABLE IF NOT EXISTS article_chapters (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    article_id BIGINT NOT NULL,
+    position INT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    content MEDIUMTEXT NOT NULL,
+    FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_article_position (article_id, position)
+);
+-- 牌組（可巢狀）
+CREATE TABLE IF NOT EXISTS decks (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    parent_id BIGINT NULL,
+    name VARCHAR(100) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (parent_id) REFERENCES decks(id) ON DELETE CASCADE
 );
 -- 單字
 CREATE TABLE IF NOT EXISTS vocabularies (
@@ -14,8 +56,13 @@ CREATE TABLE IF NOT EXISTS vocabularies (
     word VARCHAR(100) NOT NULL,
     status ENUM('active', 'removed') NOT NULL DEFAULT 'active',
     tested BOOLEAN NOT NULL DEFAULT FALSE,
+    article_id BIGINT NULL,
+    context TEXT NULL,
+    deck_id BIGINT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE SET NULL,
+    FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE SET NULL,
     UNIQUE KEY unique_user_word (user_id, word)
 );
 -- 單字定義
@@ -28,13 +75,304 @@ CREATE TABLE IF NOT EXISTS vocabulary_definitions (
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (vocabulary_id) REFERENCES vocabularies(id) ON DELETE CASCADE
 );
+-- 標籤
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY unique_user_tag (user_id, name)
+);
+-- 單字與標籤的多對多關聯
+CREATE TABLE IF NOT EXISTS vocabulary_tags (
+    vocabulary_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    PRIMARY KEY (vocabulary_id, tag_id),
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+-- 公開單字表（由管理員維護）
+CREATE TABLE IF NOT EXISTS shared_decks (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+-- 公開單字表中的單字，每個定義一列
+CREATE TABLE IF NOT EXISTS shared_deck_words (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    deck_id BIGINT NOT NULL,
+    word VARCHAR(100) NOT NULL,
+    part_of_speech VARCHAR(50) NOT NULL,
+    definition TEXT NOT NULL,
+    example TEXT,
+    FOREIGN KEY (deck_id) REFERENCES shared_decks(id) ON DELETE CASCADE,
+    INDEX idx_deck_word (deck_id, word)
+);
 -- 測試結果
 CREATE TABLE IF NOT EXISTS test_results (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
     user_id BIGINT NOT NULL,
     word_id BIGINT NOT NULL,
     correct BOOLEAN NOT NULL,
+    mode ENUM('flip', 'choice', 'typing', 'cloze') NOT NULL DEFAULT 'flip',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
-    FOREIGN KEY (word_id) REFERENCES vocabularies(id)
+    FOREIGN KEY (word_id) REFERENCES vocabularies(id),
+    INDEX idx_user_created (user_id, created_at)
+);
+-- 測驗發出的題目，作答時依此批改，每題只能作答一次
+CREATE TABLE IF NOT EXISTS quiz_questions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    vocabulary_id BIGINT NOT NULL,
+    mode ENUM('choice', 'typing', 'cloze') NOT NULL,
+    correct BOOLEAN NULL,
+    created_at DATETIME NOT NULL,
+    answered_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies(id) ON DELETE CASCADE,
+    INDEX idx_quiz_user_created (user_id, created_at)
+);
+-- 複習工作階段
+CREATE TABLE IF NOT EXISTS review_sessions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    mode ENUM('flip', 'choice', 'typing', 'cloze') NOT NULL DEFAULT 'flip',
+    deck_id BIGINT NULL,
+    tag VARCHAR(50) NULL,
+    status ENUM('active', 'completed') NOT NULL DEFAULT 'active',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE SET NULL,
+    INDEX idx_user_status (user_id, status)
+);
+-- 工作階段中的卡片，依 position 順序作答
+CREATE TABLE IF NOT EXISTS review_session_cards (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    session_id BIGINT NOT NULL,
+    vocabulary_id BIGINT NOT NULL,
+    position INT NOT NULL,
+    prompt TEXT NOT NULL,
+    part_of_speech VARCHAR(50) NOT NULL DEFAULT '',
+    definition TEXT NULL,
+    hint VARCHAR(255) NULL,
+    options TEXT NULL,
+    answer VARCHAR(255) NULL,
+    correct BOOLEAN NULL,
+    answered_at DATETIME NULL,
+    FOREIGN KEY (session_id) REFERENCES review_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_session_position (session_id, position)
+);
+-- 使用者的複習排程設定
+CREATE TABLE IF NOT EXISTS scheduler_settings (
+    user_id BIGINT PRIMARY KEY,
+    algorithm ENUM('sm2', 'fsrs', 'leitner') NOT NULL DEFAULT 'sm2',
+    request_retention DOUBLE NOT NULL DEFAULT 0.9,
+    fsrs_weights TEXT NULL,
+    optimized_at DATETIME NULL,
+    leitner_intervals TEXT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+-- 每個單字的排程狀態，可由 test_results 重新計算
+CREATE TABLE IF NOT EXISTS vocabulary_schedules (
+    vocabulary_id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    reps INT NOT NULL DEFAULT 0,
+    lapses INT NOT NULL DEFAULT 0,
+    last_review DATETIME NULL,
+    due_at DATETIME NULL,
+    ease_factor DOUBLE NOT NULL DEFAULT 0,
+    interval_days DOUBLE NOT NULL DEFAULT 0,
+    stability DOUBLE NOT NULL DEFAULT 0,
+    difficulty DOUBLE NOT NULL DEFAULT 0,
+    box INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    INDEX idx_user_due (user_id, due_at)
+);
+-- 使用者偏好：時區、每日目標與提醒
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id BIGINT PRIMARY KEY,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    goal_new_words INT NOT NULL DEFAULT 0,
+    goal_reviews INT NOT NULL DEFAULT 0,
+    goal_minutes INT NOT NULL DEFAULT 0,
+    reminder_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    reminder_hour TINYINT NOT NULL DEFAULT 19,
+    reminder_channel VARCHAR(20) NOT NULL DEFAULT 'email',
+    reminder_target VARCHAR(2048) NOT NULL DEFAULT '',
+    -- 提醒只寄往已確認的目標，格式為 "管道:目標"
+    reminder_verified_target VARCHAR(2100) NOT NULL DEFAULT '',
+    reminder_code_hash CHAR(64) NULL,
+    reminder_code_target VARCHAR(2100) NOT NULL DEFAULT '',
+    reminder_code_sent_at DATETIME NULL,
+    reminder_test_sent_at DATETIME NULL,
+    last_reminded_on DATE NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+-- 每日閱讀時間，day 為使用者時區的日期
+CREATE TABLE IF NOT EXISTS daily_activity (
+    user_id BIGINT NOT NULL,
+    day DATE NOT NULL,
+    minutes_read INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+-- 連續達成每日目標的天數
+CREATE TABLE IF NOT EXISTS user_streaks (
+    user_id BIGINT PRIMARY KEY,
+    current_streak INT NOT NULL DEFAULT 0,
+    longest_streak INT NOT NULL DEFAULT 0,
+    last_day DATE NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+-- 登入工作階段（每個裝置一筆），refresh token 只存雜湊
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    refresh_hash CHAR(64) NOT NULL,
+    previous_hash CHAR(64) NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME NOT NULL,
+    rotated_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY unique_refresh_hash (refresh_hash),
+    INDEX idx_previous_hash (previous_hash),
+    INDEX idx_user_sessions (user_id, revoked_at)
+);
+-- 登入失敗次數，供多台伺服器共用的登入限制
+CREATE TABLE IF NOT EXISTS login_throttle (
+    throttle_key VARCHAR(191) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure DATETIME NOT NULL,
+    blocked_until DATETIME NULL
+);
+-- 帳號安全相關事件（登入失敗、鎖定等）
+CREATE TABLE IF NOT EXISTS auth_audit_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NULL,
+    username VARCHAR(50) NOT NULL DEFAULT '',
+    event VARCHAR(32) NOT NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_audit_user (user_id, created_at),
+    INDEX idx_audit_event (event, created_at)
+);
+-- 重設密碼的 token，只存 nonce 的雜湊，使用後即失效
+CREATE TABLE IF NOT EXISTS password_resets (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    nonce_hash CHAR(64) NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    INDEX idx_reset_user (user_id, created_at)
+);
+-- TOTP 兩步驟驗證，confirmed_at 為 NULL 表示尚未完成設定
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id BIGINT PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at DATETIME NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- 兩步驟驗證的備用碼，只存雜湊，每組只能使用一次
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    INDEX idx_recovery_user (user_id, code_hash)
+);
+-- 外部 OpenID Connect 身分與使用者的對應
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NULL,
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY uk_identity (issuer, subject),
+    INDEX idx_identity_user (user_id)
+);
+-- 個人 API token，只存雜湊；scopes 以逗號分隔
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    expires_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    INDEX idx_api_token_user (user_id)
+);
+-- 字典 API 查詢結果的快取；not_found 表示查無此字，避免重複查詢
+CREATE TABLE IF NOT EXISTS dictionary_cache (
+    word VARCHAR(100) PRIMARY KEY,
+    definitions JSON NULL,
+    not_found BOOLEAN NOT NULL DEFAULT FALSE,
+    hits INT NOT NULL DEFAULT 0,
+    fetched_at DATETIME NOT NULL,
+    INDEX idx_dictionary_cache_fetched (fetched_at)
+);
+-- 班級（由老師建立，學生以加入代碼加入）
+CREATE TABLE IF NOT EXISTS classes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    teacher_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    join_code VARCHAR(16) NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (teacher_id) REFERENCES users(id)
+);
+-- 班級成員
+CREATE TABLE IF NOT EXISTS class_members (
+    class_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    joined_at DATETIME NOT NULL,
+    PRIMARY KEY (class_id, user_id),
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    INDEX idx_class_member_user (user_id)
+);
+-- 指派給班級的單字表
+CREATE TABLE IF NOT EXISTS class_assignments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    class_id BIGINT NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    due_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE
+);
+-- 指派時複製的單字，每個定義一列
+CREATE TABLE IF NOT EXISTS class_assignment_words (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    assignment_id BIGINT NOT NULL,
+    word VARCHAR(100) NOT NULL,
+    part_of_speech VARCHAR(50) NOT NULL,
+    definition TEXT NOT NULL,
+    example TEXT,
+    FOREIGN KEY (assignment_id) REFERENCES class_assignments(id) ON DELETE CASCADE,
+    INDEX idx_assignment_word (assignment_id, word)
 );
@@ -0,0 +1,10 @@
+-- 重複擷取同一網址時更新原本的文章，而不是新增一篇
+ALTER TABLE articles ADD COLUMN source_hash CHAR(64) NULL AFTER source_url;
+-- 既有的重複文章只保留最早的一篇作為對應，其餘保持原狀
+UPDATE articles a
+JOIN (
+    SELECT MIN(id) AS id FROM articles WHERE source_url <> '' GROUP BY user_id, source_url
+) oldest ON oldest.id = a.id
+SET a.source_hash = SHA2(a.source_url, 256)
+WHERE a.source_hash IS NULL;
+ALTER TABLE articles ADD UNIQUE KEY unique_user_source (user_id, source_hash);
@@ -0,0 +1,6 @@
+-- 文章庫可存放上傳的文件，並記錄單字是從哪篇文章加入的
+ALTER TABLE articles ADD COLUMN source_type ENUM('url', 'txt', 'html', 'epub') NOT NULL DEFAULT 'url' AFTER title;
+ALTER TABLE vocabularies
+    ADD COLUMN article_id BIGINT NULL AFTER tested,
+    ADD COLUMN context TEXT NULL AFTER article_id,
+    ADD FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE SET NULL;
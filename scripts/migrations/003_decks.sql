@@ -0,0 +1,4 @@
+-- 單字可放入牌組
+ALTER TABLE vocabularies
+    ADD COLUMN deck_id BIGINT NULL AFTER context,
+    ADD FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE SET NULL;
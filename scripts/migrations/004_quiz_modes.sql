@@ -0,0 +1,2 @@
+-- 測驗結果記錄作答模式
+ALTER TABLE test_results ADD COLUMN mode ENUM('flip', 'choice', 'typing', 'cloze') NOT NULL DEFAULT 'flip' AFTER correct;
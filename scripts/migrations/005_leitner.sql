@@ -0,0 +1,4 @@
+-- Leitner 盒子排程：演算法選項、每個盒子的間隔與單字所在的盒子
+ALTER TABLE scheduler_settings MODIFY COLUMN algorithm ENUM('sm2', 'fsrs', 'leitner') NOT NULL DEFAULT 'sm2';
+ALTER TABLE scheduler_settings ADD COLUMN leitner_intervals TEXT NULL AFTER optimized_at;
+ALTER TABLE vocabulary_schedules ADD COLUMN box INT NOT NULL DEFAULT 0 AFTER difficulty;
@@ -0,0 +1,2 @@
+-- 學習統計依時間查詢複習紀錄
+ALTER TABLE test_results ADD INDEX idx_user_created (user_id, created_at);
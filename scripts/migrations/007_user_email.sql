@@ -0,0 +1,2 @@
+-- 使用者的電子郵件，用於重設密碼
+ALTER TABLE users ADD COLUMN email VARCHAR(255) NULL UNIQUE AFTER password;
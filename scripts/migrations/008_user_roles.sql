@@ -0,0 +1,3 @@
+-- 使用者角色與停用帳號；既有使用者皆為一般使用者，管理員由 BOOTSTRAP_ADMIN 或升級後第一次啟動時的 ADMIN_USER_IDS 指定
+ALTER TABLE users ADD COLUMN role ENUM('user', 'teacher', 'admin') NOT NULL DEFAULT 'user' AFTER email;
+ALTER TABLE users ADD COLUMN disabled_at DATETIME NULL AFTER role;
@@ -0,0 +1,3 @@
+-- 自助刪除帳號：排定永久刪除的時間，背景工作依此查詢到期的帳號
+ALTER TABLE users ADD COLUMN deletion_scheduled_at DATETIME NULL AFTER disabled_at;
+ALTER TABLE users ADD INDEX idx_users_deletion (deletion_scheduled_at);
@@ -0,0 +1,6 @@
+-- 提醒只寄往已確認的地址或 webhook：確認過的目標、待確認的驗證碼，以及寄送驗證碼與測試提醒的時間
+ALTER TABLE user_preferences ADD COLUMN reminder_verified_target VARCHAR(2100) NOT NULL DEFAULT '' AFTER reminder_target;
+ALTER TABLE user_preferences ADD COLUMN reminder_code_hash CHAR(64) NULL AFTER reminder_verified_target;
+ALTER TABLE user_preferences ADD COLUMN reminder_code_target VARCHAR(2100) NOT NULL DEFAULT '' AFTER reminder_code_hash;
+ALTER TABLE user_preferences ADD COLUMN reminder_code_sent_at DATETIME NULL AFTER reminder_code_target;
+ALTER TABLE user_preferences ADD COLUMN reminder_test_sent_at DATETIME NULL AFTER reminder_code_sent_at;
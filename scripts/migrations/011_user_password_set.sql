@@ -0,0 +1,6 @@
+-- 由外部登入自動建立的帳號只有隨機的佔位密碼，需改以身分提供者重新驗證
+ALTER TABLE users ADD COLUMN password_set BOOLEAN NOT NULL DEFAULT TRUE AFTER password;
+-- 自動建立帳號時，帳號與外部身分的建立時間相同
+UPDATE users u
+JOIN user_identities i ON i.user_id = u.id AND i.created_at = u.created_at
+SET u.password_set = FALSE;
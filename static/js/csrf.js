@@ -0,0 +1,35 @@
+// 將 CSRF token 帶入同源的 fetch 請求與所有 POST 表單
+(function () {
+    function csrfToken() {
+        var match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    var originalFetch = window.fetch;
+    window.fetch = function (input, init) {
+        init = init || {};
+        var method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        var url = new URL(input instanceof Request ? input.url : input, window.location.href);
+        if (url.origin === window.location.origin && ['GET', 'HEAD', 'OPTIONS'].indexOf(method) === -1) {
+            var headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            headers.set('X-CSRF-Token', csrfToken());
+            init.headers = headers;
+        }
+        return originalFetch.call(this, input, init);
+    };
+
+    document.addEventListener('submit', function (event) {
+        var form = event.target;
+        if (!form || (form.method || '').toUpperCase() !== 'POST') {
+            return;
+        }
+        var field = form.querySelector('input[name="csrf_token"]');
+        if (!field) {
+            field = document.createElement('input');
+            field.type = 'hidden';
+            field.name = 'csrf_token';
+            form.appendChild(field);
+        }
+        field.value = csrfToken();
+    }, true);
+})();